@@ -4,6 +4,7 @@ package api
 const (
 	ApplicationFormUrlencodedValue = "application/x-www-form-urlencoded"
 	ApplicationJson                = "application/json"
+	ApplicationJwt                 = "application/jwt"
 )
 
 //Config parameters constants.
@@ -23,6 +24,26 @@ const (
 	ApplicationType              = "APPLICATION_TYPE"
 	RedirectUrl                  = "REDIRECT_URL"
 	AuthenticationToken          = "AUTHENTICATION_TOKEN"
+	RequestObjectSigningAlg      = "REQUEST_OBJECT_SIGNING_ALG"
+	TlsCaCertPem                 = "TLS_CA_CERT_PEM"
+	TlsClientCertPem             = "TLS_CLIENT_CERT_PEM"
+	TlsClientKeyPem              = "TLS_CLIENT_KEY_PEM"
+	TlsMinVersion                = "TLS_MIN_VERSION"
+	TlsCipherSuites              = "TLS_CIPHER_SUITES"
+	TlsPinnedCertSha256          = "TLS_PINNED_CERT_SHA256"
+	HttpConnectTimeout           = "HTTP_CONNECT_TIMEOUT"
+	HttpReadTimeout              = "HTTP_READ_TIMEOUT"
+	HttpMaxRetries               = "HTTP_MAX_RETRIES"
+	CircuitFailureThreshold      = "CIRCUIT_FAILURE_THRESHOLD"
+	CircuitOpenTimeout           = "CIRCUIT_OPEN_TIMEOUT"
+	MaxConcurrentRequests        = "MAX_CONCURRENT_REQUESTS"
+	WellKnownUrl                 = "WELL_KNOWN_URL"
+	EndpointJwks                 = "ENDPOINT_JWKS"
+	TokenEndpointAuthMethods     = "TOKEN_ENDPOINT_AUTH_METHODS_SUPPORTED"
+	ClientSecret                 = "CLIENT_SECRET"
+	RegistrationAccessToken      = "REGISTRATION_ACCESS_TOKEN"
+	RegistrationClientUri        = "REGISTRATION_CLIENT_URI"
+	TlsClientAuthSubjectDn       = "TLS_CLIENT_AUTH_SUBJECT_DN"
 )
 
 //Http header constants.
@@ -71,6 +92,7 @@ const (
 	ContentLocation               = "Content-Location"
 	ContentDisposition            = "Content-Disposition"
 	ContentRange                  = "Content-Range"
+	Deprecation                   = "Deprecation"
 	ETag                          = "ETag"
 	Expires                       = "Expires"
 	LastModified                  = "Last-Modified"
@@ -90,7 +112,13 @@ const (
 
 	// Non-Standard
 	XFapiFinancialId       = "x-fapi-financial-id"
+	XFapiInteractionId     = "x-fapi-interaction-id"
+	XFapiAuthDate          = "x-fapi-auth-date"
+	XFapiCustomerIpAddress = "x-fapi-customer-ip-address"
 	XIdempotencyKey        = "x-idempotency-key"
+	XSyncDateTime          = "X-Sync-Date-Time"
+	XStale                 = "X-Stale"
+	XSyncing               = "X-Syncing"
 	XFrameOptions          = "X-Frame-Options"
 	XXSSProtection         = "X-XSS-Protection"
 	ContentSecurityPolicy  = "Content-Security-Policy"
@@ -122,6 +150,13 @@ const (
 	State         = "state"
 )
 
+//client authentication constants.
+const (
+	TlsClientAuth                = "tls_client_auth"
+	PrivateKeyJwt                = "private_key_jwt"
+	ClientAssertionTypeJwtBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
 //outh2 constants
 const (
 	CodeIdToken  = "code id_token"
@@ -148,3 +183,12 @@ const (
 	InternalSignKey = "internal_sign_key"
 	ObSignKey       = "ob_sign_key"
 )
+
+//echo context key constants.
+const (
+	//JwtClaims holds the jwt.MapClaims of the verified internal token.
+	JwtClaims = "jwt_claims"
+)
+
+//BookingDateTimeLayout is the format of the fromBookingDateTime and toBookingDateTime parameters, which have no time zone.
+const BookingDateTimeLayout = "2006-01-02T15:04:05"
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//ErrorCode is the Open Banking code of an error, e.g. UK.OBIE.Field.Invalid
+type ErrorCode string
+
+const (
+	FieldInvalid                 ErrorCode = "UK.OBIE.Field.Invalid"
+	FieldInvalidDate             ErrorCode = "UK.OBIE.Field.InvalidDate"
+	FieldMissing                 ErrorCode = "UK.OBIE.Field.Missing"
+	HeaderInvalid                ErrorCode = "UK.OBIE.Header.Invalid"
+	ResourceNotFound             ErrorCode = "UK.OBIE.Resource.NotFound"
+	ResourceInvalidConsentStatus ErrorCode = "UK.OBIE.Resource.InvalidConsentStatus"
+	RulesDuplicateReference      ErrorCode = "UK.OBIE.Rules.DuplicateReference"
+	UnexpectedError              ErrorCode = "UK.OBIE.UnexpectedError"
+)
+
+//ErrorDetail is an item of the Errors of OBErrorResponse1. Path is the invalid field or header of the request.
+type ErrorDetail struct {
+	ErrorCode ErrorCode `json:"ErrorCode"`
+	Message   string    `json:"Message"`
+	Path      string    `json:"Path,omitempty"`
+	Url       string    `json:"Url,omitempty"`
+}
+
+//ErrorResponse is the OBErrorResponse1 body of the failed requests. Id is the request id.
+type ErrorResponse struct {
+	Code    string        `json:"Code"`
+	Id      string        `json:"Id,omitempty"`
+	Message string        `json:"Message"`
+	Errors  []ErrorDetail `json:"Errors"`
+}
+
+//Error is an error which can be sent to the caller. Only Status, Message and Errors are sent.
+//The cause is logged, so the internal errors e.g. sql errors and aspsp responses don't leak.
+type Error struct {
+	Status  int
+	Message string
+	Errors  []ErrorDetail
+	cause   error
+	parent  *Error
+}
+
+//ErrorConverter is implemented by the typed errors of the services, e.g. the validation errors, which are sent as an *Error.
+type ErrorConverter interface {
+	ApiError() *Error
+}
+
+//RetryAfterError is implemented by the errors which are sent with the Retry-After header, e.g. 503 and 429.
+type RetryAfterError interface {
+	RetryAfterSeconds() string
+}
+
+var (
+	ErrUnauthorized   = NewError(http.StatusUnauthorized, HeaderInvalid, "unauthorized request")
+	ErrNotFound       = NewError(http.StatusNotFound, ResourceNotFound, "resource not found")
+	ErrUnavailable    = NewError(http.StatusServiceUnavailable, UnexpectedError, "aspsp is unavailable. try again later")
+	ErrAspspRejected  = NewError(http.StatusBadGateway, UnexpectedError, "aspsp rejected the request")
+	ErrInternalServer = NewError(http.StatusInternalServerError, UnexpectedError, "an unexpected error has occurred")
+)
+
+func NewError(status int, code ErrorCode, message string) *Error {
+	return &Error{
+		Status:  status,
+		Message: message,
+		Errors:  []ErrorDetail{{ErrorCode: code, Message: message}},
+	}
+}
+
+//NewFieldError returns the 400 error of an invalid field, header or parameter of the request.
+func NewFieldError(code ErrorCode, path, message string) *Error {
+	e := NewError(http.StatusBadRequest, code, message)
+	e.Errors[0].Path = path
+
+	return e
+}
+
+//NewAspspError returns the error of an unexpected aspsp response. The errors of an OBErrorResponse1 body are passed
+//through. The aspsp's 400, 404 and 409 are kept since they're caused by the request, the others are sent as 502.
+func NewAspspError(statusCode int, body string) *Error {
+	e := ErrAspspRejected.Wrap(&AspspError{StatusCode: statusCode})
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusConflict:
+		e.Status = statusCode
+	}
+
+	var obError ErrorResponse
+	if err := json.Unmarshal([]byte(body), &obError); err == nil && len(obError.Errors) > 0 {
+		e.Errors = obError.Errors
+	}
+
+	return e
+}
+
+//AsError returns the *Error which err is sent as. The errors which aren't known are sent as ErrInternalServer.
+func AsError(err error) *Error {
+	var e *Error
+	var converter ErrorConverter
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.As(err, &converter):
+		return converter.ApiError()
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound.Wrap(err)
+	default:
+		return ErrInternalServer.Wrap(err)
+	}
+}
+
+//AspspError is the cause of NewAspspError. The body isn't kept, since it may have tokens.
+type AspspError struct {
+	StatusCode int
+}
+
+func (a *AspspError) Error() string {
+	return fmt.Sprintf("aspsp returned %v", a.StatusCode)
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+//Is reports whether e is target or is wrapped from target, so errors.Is works with the sentinel errors.
+func (e *Error) Is(target error) bool {
+	for parent := e.parent; parent != nil; parent = parent.parent {
+		if parent == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Wrap returns a copy of e with the cause. The copy still matches e with errors.Is.
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.Errors = append([]ErrorDetail(nil), e.Errors...)
+	wrapped.cause = cause
+	wrapped.parent = e
+
+	return &wrapped
+}
+
+//WithDetails returns a copy of e which has details as the Errors.
+func (e *Error) WithDetails(details ...ErrorDetail) *Error {
+	wrapped := e.Wrap(e.cause)
+	wrapped.Errors = details
+
+	return wrapped
+}
+
+//Response returns the OBErrorResponse1 body of e, e.g. {"Code":"400 BadRequest","Id":"...","Message":"...","Errors":[...]}
+func (e *Error) Response(id string) *ErrorResponse {
+	return &ErrorResponse{
+		Code:    fmt.Sprintf("%d %v", e.Status, strings.ReplaceAll(http.StatusText(e.Status), " ", "")),
+		Id:      id,
+		Message: e.Message,
+		Errors:  e.Errors,
+	}
+}
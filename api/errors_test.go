@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestError_Wrap(t *testing.T) {
+	sentinel := NewError(http.StatusNotFound, ResourceNotFound, "couldn't find the consent")
+	cause := errors.New("sql: no rows in result set")
+	wrapped := sentinel.Wrap(cause)
+
+	if !errors.Is(wrapped, sentinel) || !errors.Is(wrapped, cause) {
+		t.Errorf("Wrap() doesn't match the sentinel and the cause")
+	}
+	if errors.Is(wrapped, ErrNotFound) {
+		t.Errorf("Wrap() matches an unrelated sentinel")
+	}
+	if wrapped.Error() != "couldn't find the consent: sql: no rows in result set" {
+		t.Errorf("Error() = %v", wrapped.Error())
+	}
+	if got := wrapped.Response("rid"); got.Message != "couldn't find the consent" || got.Code != "404 NotFound" {
+		t.Errorf("Response() = %+v, the cause shouldn't be sent", got)
+	}
+}
+
+func TestNewAspspError(t *testing.T) {
+	obError := `{"Code":"400 BadRequest","Id":"1","Message":"invalid","Errors":[{"ErrorCode":"UK.OBIE.Field.Invalid","Message":"invalid date","Path":"Data.ExpirationDateTime"}]}`
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantStatus int
+		wantErrors []ErrorDetail
+	}{
+		{"ob_error_passthrough", http.StatusBadRequest, obError, http.StatusBadRequest,
+			[]ErrorDetail{{ErrorCode: FieldInvalid, Message: "invalid date", Path: "Data.ExpirationDateTime"}}},
+		{"unauthorized_as_bad_gateway", http.StatusUnauthorized, `{"error":"invalid_token"}`, http.StatusBadGateway,
+			ErrAspspRejected.Errors},
+		{"server_error_as_bad_gateway", http.StatusInternalServerError, "<html>access_token=abc</html>", http.StatusBadGateway,
+			ErrAspspRejected.Errors},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAspspError(tt.statusCode, tt.body)
+			if got.Status != tt.wantStatus || !reflect.DeepEqual(got.Errors, tt.wantErrors) {
+				t.Errorf("NewAspspError() = %v %+v, want %v %+v", got.Status, got.Errors, tt.wantStatus, tt.wantErrors)
+			}
+			if !errors.Is(got, ErrAspspRejected) {
+				t.Errorf("NewAspspError() doesn't match ErrAspspRejected")
+			}
+			var aspspError *AspspError
+			if !errors.As(got, &aspspError) || aspspError.StatusCode != tt.statusCode {
+				t.Errorf("NewAspspError() cause = %v", aspspError)
+			}
+		})
+	}
+}
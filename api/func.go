@@ -4,19 +4,22 @@ import (
 	"github.com/jmoiron/sqlx"
 	"io/ioutil"
 	"log"
+	"strings"
 	"sync"
 	"time"
 )
 
-func ObTime(t time.Time) string {
-	return t.Format(time.RFC3339)
+//BearerToken returns the token of an Authorization header. ErrUnauthorized is returned if it isn't a bearer token.
+func BearerToken(authorizationHeader string) (string, error) {
+	if !strings.HasPrefix(authorizationHeader, "Bearer ") {
+		return "", ErrUnauthorized
+	}
+
+	return authorizationHeader[7:], nil
 }
 
-func JsonResponse(tid, message string) map[string]interface{} {
-	return map[string]interface{}{
-		"tid":     tid,
-		"message": message,
-	}
+func ObTime(t time.Time) string {
+	return t.Format(time.RFC3339)
 }
 
 var mute = &sync.Mutex{}
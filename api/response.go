@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errInvalidPayload = errors.New("aspsp payload isn't valid JSON")
+
+//Version is a route version of the services which return aspsp payloads. Middleware is added to each route of the
+//version rather than to a group, since the group middleware of echo also catches the unmatched requests of the prefix.
+type Version struct {
+	Prefix string
+	//Typed versions pass the aspsp payloads through and send typed responses. The others send them as JSON strings.
+	Typed      bool
+	Middleware []echo.MiddlewareFunc
+}
+
+//Versions are the route versions. The unversioned routes are the v1 routes of the existing consumers.
+//Both are deprecated and will be removed once the consumers migrate to v2.
+var Versions = []Version{
+	{Prefix: "", Middleware: []echo.MiddlewareFunc{Deprecated("", "/v2")}},
+	{Prefix: "/v1", Middleware: []echo.MiddlewareFunc{Deprecated("/v1", "/v2")}},
+	{Prefix: "/v2", Typed: true},
+}
+
+//Respond sends a payload of the aspsp, which is already JSON, in the format of the version.
+func (v Version) Respond(c echo.Context, payload string) error {
+	if v.Typed {
+		return JsonRaw(c, payload)
+	}
+
+	return JsonString(c, payload)
+}
+
+//JsonString sends the payload as a JSON string. It's the behaviour of the v1 routes, which is kept for the
+//existing consumers.
+func JsonString(c echo.Context, payload string) error {
+	return c.JSON(http.StatusOK, payload)
+}
+
+//JsonRaw passes the payload through as the JSON body. It's the behaviour of the v2 routes.
+//A payload which isn't valid JSON is rejected as an invalid aspsp response.
+func JsonRaw(c echo.Context, payload string) error {
+	if !json.Valid([]byte(payload)) {
+		return ErrAspspRejected.Wrap(errInvalidPayload)
+	}
+
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, []byte(payload))
+}
+
+//Deprecated marks the responses of the routes under prefix, which have a newer version under successor, e.g. /v1 and /v2.
+//The Link header points to the same resource under successor, so the consumers can find out and migrate.
+func Deprecated(prefix, successor string) echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := successor + strings.TrimPrefix(c.Request().URL.Path, prefix)
+			c.Response().Header().Set(Deprecation, "true")
+			c.Response().Header().Set(Link, "<"+path+">; rel=\"successor-version\"")
+
+			return handler(c)
+		}
+	}
+}
+
+//BookingDateTimeParam reads an optional query parameter which is sent like the OB parameters, e.g. 2017-04-05T10:43:07
+//The zero time is returned if it's not sent.
+func BookingDateTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(BookingDateTimeLayout, value)
+	if err != nil {
+		return time.Time{}, NewFieldError(FieldInvalidDate, name, name+" must be like 2017-04-05T10:43:07")
+	}
+
+	return t, nil
+}
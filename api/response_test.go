@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	payload := `{"Data":{"Account":[]}}`
+	e := echo.New()
+	for _, v := range Versions {
+		v := v
+		e.Group(v.Prefix).GET("/:aspspId/accounts/cid/:cid", func(c echo.Context) error {
+			return v.Respond(c, payload)
+		}, v.Middleware...)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		wantBody       string
+		wantDeprecated string
+		wantLink       string
+	}{
+		{"unversioned", "/danske/accounts/cid/1", `"{\"Data\":{\"Account\":[]}}"` + "\n", "true", `</v2/danske/accounts/cid/1>; rel="successor-version"`},
+		{"v1", "/v1/danske/accounts/cid/1", `"{\"Data\":{\"Account\":[]}}"` + "\n", "true", `</v2/danske/accounts/cid/1>; rel="successor-version"`},
+		{"v2", "/v2/danske/accounts/cid/1", payload, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %v %v, want %v", rec.Code, rec.Body.String(), tt.wantBody)
+			}
+			if rec.Header().Get(echo.HeaderContentType) != echo.MIMEApplicationJSONCharsetUTF8 {
+				t.Errorf("Content-Type = %v", rec.Header().Get(echo.HeaderContentType))
+			}
+			if rec.Header().Get(Deprecation) != tt.wantDeprecated || rec.Header().Get(Link) != tt.wantLink {
+				t.Errorf("Deprecation = %v, Link = %v", rec.Header().Get(Deprecation), rec.Header().Get(Link))
+			}
+		})
+	}
+}
+
+func TestJsonRaw_invalidPayload(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if err := JsonRaw(c, "<html></html>"); !errors.Is(err, ErrAspspRejected) {
+		t.Errorf("JsonRaw() error = %v, want %v", err, ErrAspspRejected)
+	}
+}
@@ -1,56 +1,116 @@
 package main
 
 import (
-	"github.com/joho/godotenv"
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/config"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accesslimit"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accountdata"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/accounts"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/admin"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/aggregate"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/audit"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/authmanager"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/discovery"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/health"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/registration"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/session"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
 	"log"
-	"os"
+	"time"
 )
 
-func init() {
-	_ = godotenv.Load()
-}
-
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	s, err := settings.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
+	logger.SetLevel(logger.ParseLevel(s.Log.Level))
+	client.Configure(s.Transport, s.Audit, s.ConfigCache)
+	if err := security.Configure(s.Signing); err != nil {
+		log.Fatal(err)
+	}
+	tracing.Init(s.Tracing, "account")
 
 	e := config.NewEchoEngine()
-	dbx := store.LoadDBConnection()
+	dbx, err := store.NewDBConnection(s.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
 	chInMemory := cache.LoadInMemory()
-	chRedis := cache.LoadRedis()
+	chRedis := cache.LoadRedis(s.Redis)
+	workers := config.NewWorkers()
+	workers.Go(func(ctx context.Context) { cfg.ListenChanges(ctx, chInMemory) })
 
-	configRepository := cfg.NewRepository(dbx)
-	configService := cfg.NewService(configRepository, chInMemory)
+	configCipher, err := security.NewCipher(s.ConfigEncryption.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	configRepository := cfg.NewRepository(dbx, configCipher)
+	configService := cfg.NewService(configRepository, chInMemory, s.ConfigCache.Ttl)
+	configAdminService := cfg.NewAdminService(configRepository, chInMemory)
+	discoveryService := discovery.NewService(configService, configAdminService)
 	sessionRepository := session.NewRepository(dbx)
 	sessionService := session.NewService(sessionRepository)
 	tokenService := token.NewService(configService)
+	registrationService := registration.NewService(configService, configAdminService, tokenService, s.Registration)
 	consentRepositoryRead := consent.NewRepositoryRead(dbx)
 	consentServiceRead := consent.NewServiceRead(consentRepositoryRead)
 	consentRepositoryWrite := consent.NewRepositoryWrite(dbx)
 	consentServiceWrite := consent.NewServiceWrite(consentRepositoryWrite)
 	consentProxyService := consent.NewFacade(consentServiceRead, consentServiceWrite, tokenService, configService)
 	consentManagerService := authmanager.NewAuthManager(consentServiceRead, consentServiceWrite, tokenService, chRedis)
-	accountService := accounts.NewService(consentManagerService, configService)
+	accessLimitRepository := accesslimit.NewRepository(dbx)
+	accessLimitService := accesslimit.NewService(cache.LoadRedisCounter(s.Redis), accessLimitRepository, s.AccessLimit)
+	accountService := accounts.NewService(consentManagerService, configService, accessLimitService)
+	aggregateService := aggregate.NewService(consentServiceRead, accountService)
+	accountDataRepository := accountdata.NewRepository(dbx)
+	accountDataService := accountdata.NewService(accountDataRepository, accountService, accessLimitService, s.AccountData)
+	csvColumns, err := accounts.ParseCsvColumns(s.Export.CsvColumns)
+	if err != nil {
+		log.Fatalf("invalid EXPORT_CSV_COLUMNS: %v", err)
+	}
+	auditRepository := audit.NewRepository(dbx)
+	auditService := audit.NewService(auditRepository)
+
+	client.SetAuditRecorder(auditService)
+	workers.Go(func(ctx context.Context) { discoveryService.Watch(ctx, s.Discovery.Interval) })
+	auditService.StartRotation(time.Duration(s.Audit.RetentionDays)*24*time.Hour, 24*time.Hour)
 
 	// Routes
 	session.RegisterHandler(e, sessionService)
 	consent.RegisterHandler(e, sessionService, consentServiceRead, consentProxyService)
 	accounts.RegisterHandler(e, accountService)
+	aggregate.RegisterHandler(e, sessionService, aggregateService)
+	accounts.RegisterExportHandler(e, accounts.NewStatementReader(accountService), accountDataService, csvColumns)
+	accountdata.RegisterHandler(e, accountDataService)
+	admin.RegisterHandler(e)
+	cfg.RegisterHandler(e, configAdminService)
+	discovery.RegisterHandler(e, discoveryService)
+	registration.RegisterHandler(e, registrationService)
+	audit.RegisterHandler(e, auditService)
+	health.RegisterHandler(e, health.NewService(health.DefaultCheckers(dbx, s), health.AspspCertificateCheckers(configAdminService, configService, s)))
 
-	log.Printf("starting server at :%s", port)
-
-	if err := e.Start(":" + port); err != nil {
-		log.Fatalf("error while starting server at :%s, %v", port, err)
+	err = config.StartServer(e, ":"+s.Port, s.ShutdownTimeout,
+		workers.Closer("background jobs"),
+		config.Closer{Name: "account data sync", Close: accountDataService.Stop},
+		config.Closer{Name: "audit", Close: func(ctx context.Context) error {
+			client.SetAuditRecorder(nil)
+			return auditService.Stop(ctx)
+		}},
+		config.Closer{Name: "tracing", Close: tracing.Shutdown},
+		config.Closer{Name: "redis", Close: func(ctx context.Context) error { return cache.CloseRedis() }},
+		config.Closer{Name: "database", Close: func(ctx context.Context) error { return dbx.Close() }},
+	)
+	if err != nil {
+		log.Fatalf("error while starting server at :%s, %v", s.Port, err)
 	}
 }
@@ -1,46 +1,76 @@
 package main
 
 import (
-	"github.com/joho/godotenv"
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/config"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/audit"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/callback"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/health"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
 	"log"
-	"os"
 )
 
-func init() {
-	_ = godotenv.Load()
-}
-
 func main() {
-	port := os.Getenv("PORT_CALLBACK")
-	if port == "" {
-		port = "8081"
+	s, err := settings.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.SetLevel(logger.ParseLevel(s.Log.Level))
+	client.Configure(s.Transport, s.Audit, s.ConfigCache)
+	if err := security.Configure(s.Signing); err != nil {
+		log.Fatal(err)
 	}
+	tracing.Init(s.Tracing, "callback")
 
 	e := config.NewEchoEngine()
-	dbx := store.LoadDBConnection()
+	dbx, err := store.NewDBConnection(s.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
 	chInMemory := cache.LoadInMemory()
-	chInRedis := cache.LoadRedis()
+	chInRedis := cache.LoadRedis(s.Redis)
+	workers := config.NewWorkers()
+	workers.Go(func(ctx context.Context) { cfg.ListenChanges(ctx, chInMemory) })
 
-	configRepository := cfg.NewRepository(dbx)
-	configService := cfg.NewService(configRepository, chInMemory)
+	configCipher, err := security.NewCipher(s.ConfigEncryption.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	configRepository := cfg.NewRepository(dbx, configCipher)
+	configService := cfg.NewService(configRepository, chInMemory, s.ConfigCache.Ttl)
+	configAdminService := cfg.NewAdminService(configRepository, chInMemory)
 	tokenService := token.NewService(configService)
 	consentRepository := consent.NewRepositoryRead(dbx)
 	consentService := consent.NewServiceRead(consentRepository)
 	callbackRepository := callback.NewRepository(dbx)
 	callbackService := callback.NewService(callbackRepository, consentService, tokenService, chInRedis)
+	auditRepository := audit.NewRepository(dbx)
+	auditService := audit.NewService(auditRepository)
+	client.SetAuditRecorder(auditService)
 
 	callback.RegisterHandler(e, callbackService)
+	health.RegisterHandler(e, health.NewService(health.DefaultCheckers(dbx, s), health.AspspCertificateCheckers(configAdminService, configService, s)))
 
-	log.Printf("starting server at :%s", port)
-
-	if err := e.Start(":" + port); err != nil {
-		log.Fatalf("error while starting server at :%s, %v", port, err)
+	err = config.StartServer(e, ":"+s.PortCallback, s.ShutdownTimeout,
+		workers.Closer("background jobs"),
+		config.Closer{Name: "audit", Close: func(ctx context.Context) error {
+			client.SetAuditRecorder(nil)
+			return auditService.Stop(ctx)
+		}},
+		config.Closer{Name: "tracing", Close: tracing.Shutdown},
+		config.Closer{Name: "redis", Close: func(ctx context.Context) error { return cache.CloseRedis() }},
+		config.Closer{Name: "database", Close: func(ctx context.Context) error { return dbx.Close() }},
+	)
+	if err != nil {
+		log.Fatalf("error while starting server at :%s, %v", s.PortCallback, err)
 	}
 }
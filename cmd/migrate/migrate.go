@@ -6,9 +6,8 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/joho/godotenv"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	"log"
-	"os"
-	"strconv"
 )
 
 func init() {
@@ -20,9 +19,8 @@ func main() {
 }
 
 func doMigrate() {
-	m, err := migrate.New(
-		os.Getenv("MIGRATE_SCRIPT_URL"),
-		os.Getenv("MIGRATE_DATABASE_URL"))
+	cfg := settings.FromEnv().Migrate
+	m, err := migrate.New(cfg.ScriptUrl, cfg.DatabaseUrl)
 	defer m.Close()
 
 	if err != nil {
@@ -30,8 +28,7 @@ func doMigrate() {
 	}
 
 	log.Println(m.Version())
-	version, _ := strconv.Atoi(os.Getenv("MIGRATE_VERSION"))
-	err = m.Migrate(uint(version))
+	err = m.Migrate(uint(cfg.Version))
 	if err != nil {
 		log.Fatalf("err in migration, %v", err)
 	}
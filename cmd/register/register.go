@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/registration"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
+	"log"
+	"os"
+	"os/user"
+)
+
+//register runs the dynamic client registration with an aspsp, e.g.
+//go run ./cmd/register -aspsp danske -action register
+func main() {
+	aspspId := flag.String("aspsp", "", "id of the aspsp in config_table")
+	action := flag.String("action", "get", "register, get, update or delete")
+	flag.Parse()
+	if *aspspId == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := settings.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.SetLevel(logger.ParseLevel(s.Log.Level))
+	client.Configure(s.Transport, s.Audit, s.ConfigCache)
+	if err := security.Configure(s.Signing); err != nil {
+		log.Fatal(err)
+	}
+
+	dbx, err := store.NewDBConnection(s.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbx.Close()
+	//loaded to publish the config changes to the running services
+	cache.LoadRedis(s.Redis)
+	defer cache.CloseRedis()
+
+	chInMemory := cache.LoadInMemory()
+	configCipher, err := security.NewCipher(s.ConfigEncryption.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	configRepository := cfg.NewRepository(dbx, configCipher)
+	configService := cfg.NewService(configRepository, chInMemory, s.ConfigCache.Ttl)
+	configAdminService := cfg.NewAdminService(configRepository, chInMemory)
+	registrationService := registration.NewService(configService, configAdminService, token.NewService(configService), s.Registration)
+
+	result, err := run(registrationService, *aspspId, *action, changedBy())
+	if err != nil {
+		log.Fatalf("couldn't %v the registration of %v. %v", *action, *aspspId, err)
+	}
+	if result != nil {
+		out, _ := json.MarshalIndent(result.Public(), "", "  ")
+		fmt.Println(string(out))
+	}
+}
+
+func run(service registration.Service, aspspId, action, changedBy string) (*registration.Registration, error) {
+	ctx := context.Background()
+	switch action {
+	case "register":
+		return service.Register(ctx, aspspId, changedBy)
+	case "get":
+		return service.Get(ctx, aspspId)
+	case "update":
+		return service.Update(ctx, aspspId, changedBy)
+	case "delete":
+		return nil, service.Delete(ctx, aspspId, changedBy)
+	default:
+		return nil, fmt.Errorf("unknown action %v", action)
+	}
+}
+
+//changedBy records the os user as the author of the config changes.
+func changedBy() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+
+	return "cli"
+}
@@ -7,6 +7,17 @@ import (
 type Cache interface {
 	Get(k string) (interface{}, bool)
 	Set(k string, v interface{}, d time.Duration) error
+	Delete(k string) error
+}
+
+//Counter is a counter shared by the instances.
+type Counter interface {
+	//Increment adds 1 to the counter of k and returns the new value. The counter is deleted after expiration.
+	Increment(k string, expiration time.Duration) (int64, error)
+	//Decrement takes 1 from the counter of k and returns the new value.
+	Decrement(k string) (int64, error)
+	//Count returns the value of the counter of k, which is 0 if it doesn't exist.
+	Count(k string) (int64, error)
 }
 
 const (
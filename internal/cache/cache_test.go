@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	gocache "github.com/patrickmn/go-cache"
 	"testing"
 	"time"
@@ -62,6 +64,11 @@ func TestManager_LoadInMemory(t *testing.T) {
 	if c != c1 {
 		t.Errorf("caches inMemory addresses should be same")
 	}
+
+	_ = c1.Delete("name")
+	if _, found := c.Get("name"); found {
+		t.Errorf("Deleted item shouldn't be found")
+	}
 }
 
 func Test_redis_ReadWrite(t *testing.T) {
@@ -73,7 +80,7 @@ func Test_redis_ReadWrite(t *testing.T) {
 
 	// this is an integration test. That's why if there is no connection
 	//with redis server, then just skip the test
-	redisRef.initiateRedis()
+	redisRef.initiateRedis(settings.FromEnv().Redis.Host)
 	if err := redisRef.ping(); err != nil {
 		t.SkipNow()
 	}
@@ -128,3 +135,58 @@ func Test_redis_ReadWrite(t *testing.T) {
 		})
 	}
 }
+
+func Test_redis_PublishSubscribe(t *testing.T) {
+	// this is an integration test. That's why if there is no connection
+	//with redis server, then just skip the test
+	redisRef.initiateRedis(settings.FromEnv().Redis.Host)
+	if err := redisRef.ping(); err != nil {
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan string, 1)
+	go Subscribe(ctx, "test_channel", func(message string) {
+		received <- message
+	})
+
+	//the subscription is asynchronous, so the message is published until it's received
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := Publish("test_channel", "changed"); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		select {
+		case got := <-received:
+			if got != "changed" {
+				t.Errorf("Subscribe() = %v, want changed", got)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Subscribe() didn't receive the message")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func Test_redis_Increment(t *testing.T) {
+	// this is an integration test. That's why if there is no connection
+	//with redis server, then just skip the test
+	redisRef.initiateRedis(settings.FromEnv().Redis.Host)
+	if err := redisRef.ping(); err != nil {
+		t.SkipNow()
+	}
+
+	_ = redisRef.Delete("counter_1")
+	for want := int64(1); want <= 3; want++ {
+		got, err := redisRef.Increment("counter_1", time.Minute)
+		if err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Increment() got = %v, want %v", got, want)
+		}
+	}
+}
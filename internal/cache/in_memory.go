@@ -11,7 +11,10 @@ type inMemory struct {
 }
 
 func (i *inMemory) Get(k string) (interface{}, bool) {
-	return i.cache.Get(k)
+	v, found := i.cache.Get(k)
+	recordLookup(inMemoryCache, found)
+
+	return v, found
 }
 
 func (i *inMemory) Set(k string, v interface{}, d time.Duration) error {
@@ -19,6 +22,11 @@ func (i *inMemory) Set(k string, v interface{}, d time.Duration) error {
 	return nil
 }
 
+func (i *inMemory) Delete(k string) error {
+	i.cache.Delete(k)
+	return nil
+}
+
 var onceInMem sync.Once
 
 func (i *inMemory) initiateInMemory() {
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+)
+
+const (
+	inMemoryCache = "in_memory"
+	redisCache    = "redis"
+)
+
+var lookups = metrics.NewCounterVec("cache_requests_total",
+	"Cache lookups by result.", "cache", "result")
+
+var _ = metrics.NewGaugeFunc("cache_hit_ratio", "Ratio of the cache lookups which were found.",
+	[]string{"cache"}, func() []metrics.Sample {
+		return []metrics.Sample{
+			{LabelValues: []string{inMemoryCache}, Value: hitRatio(inMemoryCache)},
+			{LabelValues: []string{redisCache}, Value: hitRatio(redisCache)},
+		}
+	})
+
+func recordLookup(cache string, found bool) {
+	if found {
+		lookups.Inc(cache, "hit")
+	} else {
+		lookups.Inc(cache, "miss")
+	}
+}
+
+func hitRatio(cache string) float64 {
+	hit, miss := lookups.Value(cache, "hit"), lookups.Value(cache, "miss")
+	if hit+miss == 0 {
+		return 0
+	}
+
+	return hit / (hit + miss)
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	pingInterval      = 30 * time.Second
+	maxResubscribeGap = 30 * time.Second
+)
+
+//Publish sends the message to the subscribers of the channel on every instance.
+func Publish(channel, message string) error {
+	if redisRef.pool == nil {
+		return fmt.Errorf("redis isn't loaded")
+	}
+
+	conn := redisRef.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("PUBLISH", channel, message); err != nil {
+		return errors.WithMessagef(err, "error publishing to redis channel %s", channel)
+	}
+
+	return nil
+}
+
+//Subscribe calls handler with the messages of the channel until ctx is done. The subscription is renewed
+//when the connection drops. Messages published in between are lost, so subscribers need a fallback, e.g. a TTL.
+func Subscribe(ctx context.Context, channel string, handler func(message string)) {
+	if redisRef.pool == nil {
+		logger.Warn("redis isn't loaded. couldn't subscribe", logger.String("channel", channel))
+		return
+	}
+
+	wait := time.Second
+	for {
+		start := time.Now()
+		err := subscribe(ctx, channel, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxResubscribeGap {
+			wait = time.Second
+		}
+		logger.Warn("redis subscription is lost. resubscribing", logger.String("channel", channel),
+			logger.Any("retryIn", wait.String()), logger.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxResubscribeGap {
+			wait = maxResubscribeGap
+		}
+	}
+}
+
+//subscribe receives the messages until ctx is done or the connection fails. The connection is pinged,
+//so a dropped connection is noticed even if nothing is published.
+func subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	psc := redigo.PubSubConn{Conn: redisRef.pool.Get()}
+	defer psc.Close()
+
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.ReceiveWithTimeout(2 * pingInterval).(type) {
+			case redigo.Message:
+				handler(string(v.Data))
+			case error:
+				failed <- v
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = psc.Unsubscribe()
+			return nil
+		case err := <-failed:
+			return err
+		case <-ticker.C:
+			if err := psc.Ping(""); err != nil {
+				return err
+			}
+		}
+	}
+}
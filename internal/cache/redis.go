@@ -3,12 +3,9 @@ package cache
 import (
 	"fmt"
 	redigo "github.com/gomodule/redigo/redis"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	"github.com/pkg/errors"
-	"log"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -21,6 +18,7 @@ func (r *redis) Get(k string) (interface{}, bool) {
 	defer conn.Close()
 
 	data, err := redigo.String(conn.Do("GET", k))
+	recordLookup(redisCache, err == nil)
 	if err != nil {
 		return nil, false
 	}
@@ -45,24 +43,89 @@ func (r *redis) Set(k string, v interface{}, d time.Duration) error {
 	return nil
 }
 
+func (r *redis) Delete(k string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", k); err != nil {
+		return errors.WithMessagef(err, "error deleting key in redis %s", k)
+	}
+
+	return nil
+}
+
+//Increment runs INCR and EXPIRE in a transaction, so the counter doesn't outlive the expiration if EXPIRE fails.
+//Unlike Set, the expiration is a duration, which is rounded up to seconds.
+func (r *redis) Increment(k string, expiration time.Duration) (int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("INCR", k)
+	_ = conn.Send("EXPIRE", k, int64((expiration+time.Second-1)/time.Second))
+	values, err := redigo.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, errors.WithMessagef(err, "error incrementing key in redis %s", k)
+	}
+
+	count, err := redigo.Int64(values[0], nil)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "error incrementing key in redis %s", k)
+	}
+
+	return count, nil
+}
+
+//Decrement runs DECR, which keeps the expiration of the counter.
+func (r *redis) Decrement(k string) (int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count, err := redigo.Int64(conn.Do("DECR", k))
+	if err != nil {
+		return 0, errors.WithMessagef(err, "error decrementing key in redis %s", k)
+	}
+
+	return count, nil
+}
+
+func (r *redis) Count(k string) (int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count, err := redigo.Int64(conn.Do("GET", k))
+	if errors.Is(err, redigo.ErrNil) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.WithMessagef(err, "error reading the counter in redis %s", k)
+	}
+
+	return count, nil
+}
+
 var onceRedis sync.Once
 
-func (r *redis) initiateRedis() {
+func (r *redis) initiateRedis(host string) {
 	onceRedis.Do(func() {
-		redisHost := os.Getenv("REDIS_HOST")
-		if redisHost == "" {
-			redisHost = ":6379"
-		}
-		r.newPool(redisHost)
-		r.cleanupHook()
+		r.newPool(host)
 	})
 }
 
 var redisRef redis
 
-func LoadRedis() Cache {
+//LoadRedis returns the redis cache. The pool is created by the first call.
+func LoadRedis(cfg settings.Redis) Cache {
+	if redisRef.pool == nil {
+		redisRef.initiateRedis(cfg.Host)
+	}
+
+	return &redisRef
+}
+
+//LoadRedisCounter returns the redis cache as a Counter. The pool is created by the first call.
+func LoadRedisCounter(cfg settings.Redis) Counter {
 	if redisRef.pool == nil {
-		redisRef.initiateRedis()
+		redisRef.initiateRedis(cfg.Host)
 	}
 
 	return &redisRef
@@ -88,19 +151,13 @@ func (r *redis) newPool(server string) {
 	}
 }
 
-func (r *redis) cleanupHook() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, syscall.SIGTERM)
-	signal.Notify(c, syscall.SIGKILL)
-	go func() {
-		<-c
-		err := r.pool.Close()
-		if err != nil {
-			log.Fatalf("error in cleanupHook(). err: %v", err)
-		}
-		os.Exit(0)
-	}()
+//CloseRedis closes the redis pool on shutdown. It does nothing if redis hasn't been loaded.
+func CloseRedis() error {
+	if redisRef.pool == nil {
+		return nil
+	}
+
+	return redisRef.pool.Close()
 }
 
 func (r *redis) ping() error {
@@ -114,3 +171,12 @@ func (r *redis) ping() error {
 
 	return nil
 }
+
+//PingRedis checks the connection to the redis server. It fails if redis hasn't been loaded.
+func PingRedis() error {
+	if redisRef.pool == nil {
+		return fmt.Errorf("redis isn't loaded")
+	}
+
+	return redisRef.ping()
+}
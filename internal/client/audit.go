@@ -0,0 +1,119 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//Audit body modes which can be selected with AUDIT_BODY env variable.
+const (
+	AuditBodyHash = "hash"
+	AuditBodyFull = "full"
+	AuditBodyNone = "none"
+)
+
+//AuditEntry is the record of a single request sent to an aspsp.
+type AuditEntry struct {
+	AspspId        string
+	Cid            string
+	InteractionId  string
+	Method         string
+	Url            string
+	RequestHeader  string
+	RequestBody    string
+	StatusCode     int
+	ResponseBody   string
+	LatencyMs      int64
+	Error          string
+	CreateDateTime time.Time
+}
+
+//AuditRecorder persists the audit entries. Record shouldn't block the outbound call.
+type AuditRecorder interface {
+	Record(entry AuditEntry)
+}
+
+var (
+	auditMu       sync.RWMutex
+	auditRecorder AuditRecorder
+	auditBodyMode string
+)
+
+//SetAuditRecorder enables the audit trail of outbound requests. Passing nil disables it. It can be called while
+//requests are in flight, e.g. on shutdown.
+func SetAuditRecorder(recorder AuditRecorder) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditRecorder = recorder
+}
+
+func getAuditRecorder() AuditRecorder {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	return auditRecorder
+}
+
+func redactHeader(header http.Header) string {
+	data, _ := json.Marshal(logger.RedactHeader(header))
+	return string(data)
+}
+
+//auditBody returns the body in the configured form. The hash is of the original body,
+//so it can be matched against the aspsp's own records.
+func auditBody(body string) string {
+	mode := auditBodyMode
+	if mode == "" {
+		mode = settings.FromEnv().Audit.Body
+	}
+
+	switch mode {
+	case AuditBodyFull:
+		return logger.Redact(body)
+	case AuditBodyNone:
+		return ""
+	default:
+		if body == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(body))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+}
+
+func (s *SecureClient) audit(req *http.Request, body []byte, resp *HttpResponse, err error, start time.Time) {
+	recorder := getAuditRecorder()
+	if recorder == nil {
+		return
+	}
+
+	interaction := InteractionFrom(req.Context())
+	url := *req.URL
+	url.RawQuery = logger.Redact(url.RawQuery)
+
+	entry := AuditEntry{
+		AspspId:        s.aspspId,
+		Cid:            interaction.Cid,
+		InteractionId:  interaction.InteractionId,
+		Method:         req.Method,
+		Url:            url.String(),
+		RequestHeader:  redactHeader(req.Header),
+		RequestBody:    auditBody(string(body)),
+		LatencyMs:      time.Since(start).Milliseconds(),
+		CreateDateTime: start,
+	}
+	if resp != nil {
+		entry.StatusCode = resp.StatusCode
+		entry.ResponseBody = auditBody(resp.Body)
+	}
+	if err != nil {
+		entry.Error = logger.Redact(err.Error())
+	}
+
+	recorder.Record(entry)
+}
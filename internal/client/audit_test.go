@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type auditRecorderStub struct {
+	entries []AuditEntry
+}
+
+func (a *auditRecorderStub) Record(entry AuditEntry) {
+	a.entries = append(a.entries, entry)
+}
+
+func Test_SecureClient_audit(t *testing.T) {
+	initTest()
+
+	recorder := &auditRecorderStub{}
+	SetAuditRecorder(recorder)
+	defer SetAuditRecorder(nil)
+
+	server, serverCACertPEM, _ := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token":"secret_access_token","expires_in":300}`))
+	}))
+
+	cfg := configStub{"audit_TLS_CA_CERT_PEM": serverCACertPEM}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret_bearer")
+	client, err := NewSecureHttpClient(cfg, "audit", "test", server.URL, header)
+	if err != nil {
+		t.Fatalf("could not create secure client, %v", err)
+	}
+
+	interaction := &Interaction{InteractionId: "interaction_1", Cid: "10"}
+	ctx := WithInteraction(context.Background(), interaction)
+
+	tests := []struct {
+		name     string
+		bodyMode string
+		check    func(entry AuditEntry) bool
+	}{
+		{"hash_by_default", "", func(entry AuditEntry) bool {
+			return strings.HasPrefix(entry.RequestBody, "sha256:") && strings.HasPrefix(entry.ResponseBody, "sha256:")
+		}},
+		{"full_body_redacted", AuditBodyFull, func(entry AuditEntry) bool {
+			return entry.RequestBody == "code=[REDACTED]&grant_type=authorization_code" &&
+				entry.ResponseBody == `{"access_token":"[REDACTED]","expires_in":300}`
+		}},
+		{"no_body", AuditBodyNone, func(entry AuditEntry) bool {
+			return entry.RequestBody == "" && entry.ResponseBody == ""
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("AUDIT_BODY", tt.bodyMode)
+			defer os.Unsetenv("AUDIT_BODY")
+			recorder.entries = nil
+
+			if _, err := client.Post(ctx, strings.NewReader("code=secret_code&grant_type=authorization_code")); err != nil {
+				t.Fatalf("Post() unexpected error: %v", err)
+			}
+			if len(recorder.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %v", len(recorder.entries))
+			}
+
+			entry := recorder.entries[0]
+			if entry.AspspId != "audit" || entry.Cid != "10" || entry.InteractionId != "interaction_1" ||
+				entry.Method != http.MethodPost || entry.StatusCode != http.StatusOK {
+				t.Errorf("unexpected audit entry: %+v", entry)
+			}
+			if strings.Contains(entry.RequestHeader, "secret_bearer") || !tt.check(entry) {
+				t.Errorf("audit entry isn't redacted as expected: %+v", entry)
+			}
+		})
+	}
+}
+
+type countingRecorder struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *countingRecorder) Record(entry AuditEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count++
+}
+
+func Test_SetAuditRecorder_concurrent(t *testing.T) {
+	defer SetAuditRecorder(nil)
+
+	s := &SecureClient{aspspId: "audit"}
+	req := httptest.NewRequest(http.MethodGet, "https://aspsp/accounts", nil)
+	recorder := &countingRecorder{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.audit(req, nil, &HttpResponse{StatusCode: http.StatusOK}, nil, time.Now())
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		SetAuditRecorder(recorder)
+		SetAuditRecorder(nil)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Circuit states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+const (
+	defaultFailureThreshold      = 5
+	defaultOpenTimeout           = 30 * time.Second
+	defaultMaxConcurrentRequests = 20
+)
+
+//UnavailableError is returned without calling the aspsp when its circuit is open or it has too many requests in flight.
+type UnavailableError struct {
+	AspspId    string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (u *UnavailableError) Error() string {
+	return fmt.Sprintf("aspsp %v is unavailable: %v", u.AspspId, u.Reason)
+}
+
+func (u *UnavailableError) ApiError() *api.Error {
+	return api.ErrUnavailable.Wrap(u)
+}
+
+//RetryAfterSeconds returns RetryAfter rounded up to seconds for the Retry-After header.
+func (u *UnavailableError) RetryAfterSeconds() string {
+	return strconv.Itoa(int((u.RetryAfter + time.Second - 1) / time.Second))
+}
+
+//CircuitState is the snapshot of an aspsp's circuit.
+type CircuitState struct {
+	AspspId             string     `json:"aspspId"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	InFlight            int        `json:"inFlight"`
+	MaxConcurrent       int        `json:"maxConcurrent"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
+
+//outcome of a request guarded by the circuit breaker.
+type outcome int
+
+const (
+	succeeded outcome = iota
+	failed
+	//cancelled by the caller, so it tells nothing about the aspsp
+	cancelled
+)
+
+//circuitBreaker stops calling an aspsp after failureThreshold consecutive failures. After openTimeout a single
+//probe request is let through(half open); its result closes the circuit or opens it again.
+//It also caps the number of concurrent requests to the aspsp, so a slow aspsp can't take every connection.
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	aspspId             string
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	//generation is increased each time the circuit opens. The results of the requests which started before are
+	//ignored, so a late success can't close the circuit while it is open or its probe is in flight.
+	generation       int
+	failureThreshold int
+	openTimeout      time.Duration
+	bulkhead         chan struct{}
+}
+
+var circuitBreakers = struct {
+	sync.Mutex
+	m map[string]*circuitBreaker
+}{m: map[string]*circuitBreaker{}}
+
+func getCircuitBreaker(cfg Config, aspspId string) (*circuitBreaker, error) {
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+
+	if cb, found := circuitBreakers.m[aspspId]; found {
+		return cb, nil
+	}
+
+	failureThreshold, err := findPositiveInt(cfg, aspspId, api.CircuitFailureThreshold, defaultFailureThreshold)
+	if err != nil {
+		return nil, err
+	}
+	openTimeout, err := findDuration(cfg, aspspId, api.CircuitOpenTimeout, defaultOpenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	maxConcurrent, err := findPositiveInt(cfg, aspspId, api.MaxConcurrentRequests, defaultMaxConcurrentRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	cb := &circuitBreaker{
+		aspspId:          aspspId,
+		state:            CircuitClosed,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		bulkhead:         make(chan struct{}, maxConcurrent),
+	}
+	circuitBreakers.m[aspspId] = cb
+
+	return cb, nil
+}
+
+//allow reserves a slot for a request. The returned done func has to be called with the outcome of the request.
+func (cb *circuitBreaker) allow() (func(outcome), error) {
+	cb.mu.Lock()
+	probe := false
+	switch cb.state {
+	case CircuitOpen:
+		if wait := cb.openTimeout - time.Since(cb.openedAt); wait > 0 {
+			cb.mu.Unlock()
+			return nil, &UnavailableError{AspspId: cb.aspspId, Reason: "circuit is open", RetryAfter: wait}
+		}
+		cb.state = CircuitHalfOpen
+		probe = true
+	case CircuitHalfOpen:
+		cb.mu.Unlock()
+		return nil, &UnavailableError{AspspId: cb.aspspId, Reason: "circuit is half open", RetryAfter: time.Second}
+	}
+	generation := cb.generation
+	cb.mu.Unlock()
+
+	select {
+	case cb.bulkhead <- struct{}{}:
+	default:
+		if probe {
+			cb.done(generation, cancelled)
+		}
+		return nil, &UnavailableError{AspspId: cb.aspspId, Reason: "too many concurrent requests", RetryAfter: time.Second}
+	}
+
+	return func(result outcome) {
+		<-cb.bulkhead
+		cb.done(generation, result)
+	}, nil
+}
+
+func (cb *circuitBreaker) done(generation int, result outcome) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch result {
+	case succeeded:
+		cb.state = CircuitClosed
+		cb.consecutiveFailures = 0
+		return
+	case cancelled:
+		//the probe didn't complete. the next probe waits for openTimeout again
+		if cb.state == CircuitHalfOpen {
+			cb.open()
+		}
+		return
+	}
+
+	cb.consecutiveFailures++
+	if cb.state == CircuitHalfOpen || cb.consecutiveFailures >= cb.failureThreshold {
+		cb.open()
+	}
+}
+
+func (cb *circuitBreaker) open() {
+	cb.state = CircuitOpen
+	cb.openedAt = time.Now()
+	cb.generation++
+}
+
+func (cb *circuitBreaker) snapshot() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	state := CircuitState{
+		AspspId:             cb.aspspId,
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		InFlight:            len(cb.bulkhead),
+		MaxConcurrent:       cap(cb.bulkhead),
+	}
+	if cb.state != CircuitClosed {
+		openedAt := cb.openedAt
+		state.OpenedAt = &openedAt
+	}
+
+	return state
+}
+
+//CircuitStates returns the circuit of each aspsp which has been called since the start.
+func CircuitStates() []CircuitState {
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+
+	states := make([]CircuitState, 0, len(circuitBreakers.m))
+	for _, cb := range circuitBreakers.m {
+		states = append(states, cb.snapshot())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].AspspId < states[j].AspspId
+	})
+
+	return states
+}
+
+func requestOutcome(ctx context.Context, resp *HttpResponse, err error) outcome {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return cancelled
+	case err != nil, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= http.StatusInternalServerError:
+		return failed
+	default:
+		return succeeded
+	}
+}
+
+func findPositiveInt(cfg Config, aspspId, configName string, defaultValue int) (int, error) {
+	value, err := findConfig(cfg, aspspId, configName, strconv.Itoa(defaultValue))
+	if err != nil {
+		return 0, err
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid %v: %v", configName, value)
+	}
+
+	return number, nil
+}
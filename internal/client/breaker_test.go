@@ -0,0 +1,153 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestCircuitBreaker(maxConcurrent int) *circuitBreaker {
+	return &circuitBreaker{
+		aspspId:          "test",
+		state:            CircuitClosed,
+		failureThreshold: 2,
+		openTimeout:      50 * time.Millisecond,
+		bulkhead:         make(chan struct{}, maxConcurrent),
+	}
+}
+
+func Test_circuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		outcomes  []outcome
+		wait      time.Duration
+		wantState string
+		wantAllow bool
+	}{
+		{"stays_closed_below_threshold", []outcome{failed}, 0, CircuitClosed, true},
+		{"success_resets_failures", []outcome{failed, succeeded, failed}, 0, CircuitClosed, true},
+		{"opens_at_threshold", []outcome{failed, failed}, 0, CircuitOpen, false},
+		{"cancellations_dont_count", []outcome{failed, cancelled, cancelled}, 0, CircuitClosed, true},
+		{"half_open_after_timeout", []outcome{failed, failed}, 60 * time.Millisecond, CircuitHalfOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := newTestCircuitBreaker(1)
+			for _, o := range tt.outcomes {
+				done, err := cb.allow()
+				if err != nil {
+					t.Fatalf("allow() unexpected error: %v", err)
+				}
+				done(o)
+			}
+			time.Sleep(tt.wait)
+
+			done, err := cb.allow()
+			if (err == nil) != tt.wantAllow {
+				t.Fatalf("allow() error = %v, wantAllow %v", err, tt.wantAllow)
+			}
+			if got := cb.snapshot().State; got != tt.wantState {
+				t.Errorf("state = %v, want %v", got, tt.wantState)
+			}
+			if done != nil {
+				done(succeeded)
+			}
+		})
+	}
+}
+
+func Test_circuitBreaker_halfOpenProbe(t *testing.T) {
+	cb := newTestCircuitBreaker(2)
+	for i := 0; i < 2; i++ {
+		done, _ := cb.allow()
+		done(failed)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	probe, err := cb.allow()
+	if err != nil {
+		t.Fatalf("probe should be allowed. err: %v", err)
+	}
+	var unavailable *UnavailableError
+	if _, err = cb.allow(); !errors.As(err, &unavailable) {
+		t.Fatalf("only one probe should be allowed. err: %v", err)
+	}
+
+	probe(failed)
+	if got := cb.snapshot().State; got != CircuitOpen {
+		t.Errorf("failed probe should open the circuit. state = %v", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	probe, _ = cb.allow()
+	probe(succeeded)
+	if got := cb.snapshot().State; got != CircuitClosed {
+		t.Errorf("successful probe should close the circuit. state = %v", got)
+	}
+}
+
+func Test_circuitBreaker_bulkhead(t *testing.T) {
+	cb := newTestCircuitBreaker(1)
+
+	done, err := cb.allow()
+	if err != nil {
+		t.Fatalf("allow() unexpected error: %v", err)
+	}
+
+	var unavailable *UnavailableError
+	if _, err = cb.allow(); !errors.As(err, &unavailable) || unavailable.RetryAfterSeconds() != "1" {
+		t.Fatalf("allow() should be rejected when the bulkhead is full. err: %v", err)
+	}
+
+	done(succeeded)
+	if _, err = cb.allow(); err != nil {
+		t.Errorf("allow() should succeed after the slot is released. err: %v", err)
+	}
+}
+
+func Test_circuitBreaker_cancelledProbe(t *testing.T) {
+	cb := newTestCircuitBreaker(2)
+	for i := 0; i < 2; i++ {
+		done, _ := cb.allow()
+		done(failed)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	probe, err := cb.allow()
+	if err != nil {
+		t.Fatalf("probe should be allowed. err: %v", err)
+	}
+	probe(cancelled)
+
+	var unavailable *UnavailableError
+	if _, err = cb.allow(); !errors.As(err, &unavailable) || cb.snapshot().State != CircuitOpen {
+		t.Errorf("allow() after a cancelled probe should wait for the open timeout. err: %v", err)
+	}
+}
+
+func Test_circuitBreaker_lateResult(t *testing.T) {
+	cb := newTestCircuitBreaker(3)
+	late, err := cb.allow()
+	if err != nil {
+		t.Fatalf("allow() unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		done, _ := cb.allow()
+		done(failed)
+	}
+
+	late(succeeded)
+	if got := cb.snapshot().State; got != CircuitOpen {
+		t.Errorf("a request started before the circuit opened shouldn't close it. state = %v", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	probe, err := cb.allow()
+	if err != nil {
+		t.Fatalf("probe should be allowed. err: %v", err)
+	}
+	probe(succeeded)
+	if got := cb.snapshot().State; got != CircuitClosed {
+		t.Errorf("successful probe should close the circuit. state = %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"net/http"
+	"time"
+)
+
+//Interaction keeps the FAPI details of an internal request. They are sent to the aspsp with each outbound call.
+type Interaction struct {
+	//InteractionId is the echo request id and sent as x-fapi-interaction-id
+	InteractionId string
+	//CustomerIpAddress is forwarded by the TPP when the PSU is present
+	CustomerIpAddress string
+	//AuthDate is the last time the PSU authorised the consent
+	AuthDate time.Time
+	//AspspInteractionId is the x-fapi-interaction-id echoed by the aspsp
+	AspspInteractionId string
+	//Cid is the internal id of the consent the request is made for
+	Cid string
+}
+
+type interactionKey struct{}
+
+func WithInteraction(ctx context.Context, interaction *Interaction) context.Context {
+	return context.WithValue(ctx, interactionKey{}, interaction)
+}
+
+//InteractionFrom returns the interaction of the request. If there is none, e.g. for background jobs, a new one is created.
+func InteractionFrom(ctx context.Context) *Interaction {
+	if interaction, ok := ctx.Value(interactionKey{}).(*Interaction); ok && interaction != nil {
+		return interaction
+	}
+
+	return &Interaction{InteractionId: uuid.New().String()}
+}
+
+//ForkInteraction returns ctx with a copy of its interaction, so concurrent calls of a request don't share the details
+//which are set per call, e.g. Cid and AspspInteractionId. The copy keeps the InteractionId of the request.
+func ForkInteraction(ctx context.Context) context.Context {
+	interaction := *InteractionFrom(ctx)
+
+	return WithInteraction(ctx, &interaction)
+}
+
+//NewFapiHeader builds the headers which are shared by all outbound aspsp requests.
+//Callers add Authorization, Content-Type etc. on top of it.
+func NewFapiHeader(ctx context.Context, fapiFinancialId string) http.Header {
+	interaction := InteractionFrom(ctx)
+
+	header := http.Header{}
+	header.Set(api.Accept, api.ApplicationJson)
+	header.Set(api.CacheControl, "no-cache")
+	header.Set(api.XFapiFinancialId, fapiFinancialId)
+	header.Set(api.XFapiInteractionId, interaction.InteractionId)
+	if !interaction.AuthDate.IsZero() {
+		header.Set(api.XFapiAuthDate, interaction.AuthDate.UTC().Format(http.TimeFormat))
+	}
+	if interaction.CustomerIpAddress != "" {
+		header.Set(api.XFapiCustomerIpAddress, interaction.CustomerIpAddress)
+	}
+
+	return header
+}
+
+//RecordInteraction keeps the x-fapi-interaction-id echoed by the aspsp, so it can be matched with the aspsp's logs.
+func RecordInteraction(ctx context.Context, resp *HttpResponse) {
+	if resp == nil {
+		return
+	}
+
+	interaction := InteractionFrom(ctx)
+	interaction.AspspInteractionId = resp.Header.Get(api.XFapiInteractionId)
+	if interaction.AspspInteractionId != "" && interaction.AspspInteractionId != interaction.InteractionId {
+		logger.Warn("aspsp returned a different x-fapi-interaction-id",
+			logger.String("interactionId", interaction.InteractionId), logger.String("aspspInteractionId", interaction.AspspInteractionId))
+	}
+}
@@ -1,22 +1,29 @@
 package client
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
 	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"time"
 )
 
 type SecureClient struct {
 	*http.Client
 	endpoint string
 	http.Header
+	aspspId      string
+	endpointType string
+	retry        retryPolicy
+	breaker      *circuitBreaker
 }
 
 type HttpResponse struct {
@@ -25,17 +32,53 @@ type HttpResponse struct {
 	Header     http.Header
 }
 
+//String is used when a response is printed in an error or a log. The body is redacted and the headers are left out.
+func (h HttpResponse) String() string {
+	return fmt.Sprintf("{StatusCode: %v, Body: %v}", h.StatusCode, logger.Redact(h.Body))
+}
+
 var cacheMem = cache.LoadInMemory()
 
-func NewSecureHttpClient(endpoint string, header http.Header) (*SecureClient, error) {
-	transport, err := newHttpTransport()
+//Reset drops the cached transport, configs and circuit breaker of an aspsp, so its changed configs apply to the next request.
+func Reset(aspspId string) {
+	if value, found := cacheMem.Get(transportCacheId(aspspId)); found {
+		value.(*http.Transport).CloseIdleConnections()
+		_ = cacheMem.Delete(transportCacheId(aspspId))
+	}
+	_ = cacheMem.Delete(configsCacheId(aspspId))
+
+	circuitBreakers.Lock()
+	delete(circuitBreakers.m, aspspId)
+	circuitBreakers.Unlock()
+}
+
+//NewSecureHttpClient returns a client which calls the endpoint over the aspsp's own TLS transport.
+//endpointType is the config name of the endpoint, e.g. ENDPOINT_ACCOUNTS, and is used to label the metrics.
+func NewSecureHttpClient(cfg Config, aspspId, endpointType, endpoint string, header http.Header) (*SecureClient, error) {
+	transport, err := newHttpTransport(cfg, aspspId)
 	if err != nil {
 		return nil, err
 	}
 
+	retry, err := newRetryPolicy(cfg, aspspId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewSecureHttpClient()")
+	}
+
+	breaker, err := getCircuitBreaker(cfg, aspspId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewSecureHttpClient()")
+	}
+
+	timeout, err := requestTimeout(cfg, aspspId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewSecureHttpClient()")
+	}
+
 	client := &SecureClient{
 		&http.Client{
 			Transport: transport,
+			Timeout:   timeout,
 			//disable direction follow
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
@@ -43,107 +86,179 @@ func NewSecureHttpClient(endpoint string, header http.Header) (*SecureClient, er
 		},
 		endpoint,
 		header,
+		aspspId,
+		endpointType,
+		retry,
+		breaker,
 	}
 
 	return client, nil
 }
 
-func newHttpTransport() (*http.Transport, error) {
-	if value, found := cacheMem.Get("http_transport"); found {
-		return value.(*http.Transport), nil
+func (s *SecureClient) Post(ctx context.Context, payload io.Reader) (*HttpResponse, error) {
+	resp, err := s.do(ctx, http.MethodPost, nil, payload)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in Post()")
 	}
 
-	serverCACertPEM := os.Getenv("CLIENT_CA_CERT_PEM")
-	clientCertPEM := os.Getenv("CLIENT_CERT_PEM")
-	clientKeyPEM := os.Getenv("CLIENT_KEY_PEM")
-
-	pool := x509.NewCertPool()
-
-	for _, cert := range strings.Split(serverCACertPEM, ",") {
-		caCert, err := ioutil.ReadFile(cert)
-		if err != nil {
-			return nil, errors.WithMessage(err, "error in newHttpTransport() while reading cert file")
-		}
-		pool.AppendCertsFromPEM(caCert)
-	}
+	return resp, nil
+}
 
-	clientCert, err := tls.LoadX509KeyPair(clientCertPEM, clientKeyPEM)
+func (s *SecureClient) Get(ctx context.Context, parameters url.Values) (*HttpResponse, error) {
+	resp, err := s.do(ctx, http.MethodGet, parameters, nil)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in newHttpTransport() while loading x509 certificate")
+		return nil, errors.WithMessage(err, "error in Get()")
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			RootCAs:      pool,
-			Certificates: []tls.Certificate{clientCert},
-		}}
+	return resp, nil
+}
 
-	_ = cacheMem.Set("http_transport", transport, cache.NoExpiration)
+func (s *SecureClient) Put(ctx context.Context, payload io.Reader) (*HttpResponse, error) {
+	resp, err := s.do(ctx, http.MethodPut, nil, payload)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in Put()")
+	}
 
-	return transport, nil
+	return resp, nil
 }
 
-func (s *SecureClient) Post(payload io.Reader) (*HttpResponse, error) {
-	req, err := s.createRequest("POST", payload)
+func (s *SecureClient) Delete(ctx context.Context) (*HttpResponse, error) {
+	resp, err := s.do(ctx, http.MethodDelete, nil, nil)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in Post() while creating NewRequest")
+		return nil, errors.WithMessage(err, "error in Delete()")
 	}
 
-	resp, err := s.Client.Do(req)
+	return resp, nil
+}
+
+//do sends the request through the aspsp's circuit breaker.
+//It fails fast with UnavailableError if the circuit is open or the aspsp has too many requests in flight.
+func (s *SecureClient) do(ctx context.Context, method string, parameters url.Values, payload io.Reader) (*HttpResponse, error) {
+	done, err := s.breaker.allow()
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in Post()")
+		outboundRequests.Inc(s.aspspId, s.endpointType, "rejected")
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.WithMessage(err, "error in Post() in while reading response body")
+	resp, err := s.doWithRetry(ctx, method, parameters, payload)
+	done(requestOutcome(ctx, resp, err))
+
+	return resp, err
+}
+
+//doWithRetry sends the request and retries it, if it's idempotent, on network errors, 429 and 5xx responses.
+//A Retry-After longer than the max backoff returns UnavailableError with it, rather than waiting.
+func (s *SecureClient) doWithRetry(ctx context.Context, method string, parameters url.Values, payload io.Reader) (*HttpResponse, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = ioutil.ReadAll(payload); err != nil {
+			return nil, errors.WithMessage(err, "error while reading the payload")
+		}
 	}
 
-	response := &HttpResponse{
-		StatusCode: resp.StatusCode,
-		Body:       string(body),
-		Header:     resp.Header,
+	maxAttempts := 1
+	if s.isIdempotent(method) {
+		maxAttempts += s.retry.maxRetries
 	}
 
-	return response, nil
+	for attempt := 1; ; attempt++ {
+		resp, err := s.send(ctx, method, parameters, body)
+		if attempt >= maxAttempts || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := s.retry.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get(api.RetryAfter)); ok {
+				//the request isn't held for longer than the backoff. the caller is told when to come back instead
+				if retryAfter > s.retry.maxDelay {
+					return nil, &UnavailableError{AspspId: s.aspspId, RetryAfter: retryAfter,
+						Reason: fmt.Sprintf("aspsp responded %v with Retry-After %v", resp.StatusCode, retryAfter)}
+				}
+				wait = retryAfter
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
 }
 
-func (s *SecureClient) Get(parameters url.Values) (*HttpResponse, error) {
-	req, err := s.createRequest("GET", nil)
+func (s *SecureClient) send(ctx context.Context, method string, parameters url.Values, body []byte) (*HttpResponse, error) {
+	var payload io.Reader
+	if body != nil {
+		payload = bytes.NewReader(body)
+	}
+
+	req, err := s.createRequest(ctx, method, payload)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in Get() while creating NewRequest")
+		return nil, errors.WithMessage(err, "error while creating NewRequest")
+	}
+	//the query of the endpoint, e.g. of the Links.Next of a page, is kept as it is when there aren't any parameters
+	if parameters != nil {
+		req.URL.RawQuery = encode(parameters)
 	}
 
-	req.URL.RawQuery = encode(parameters)
+	span := s.startSpan(req)
+	defer span.End()
+
+	start := time.Now()
+	response, err := s.roundTrip(req)
+	s.observe(response, start)
+	s.audit(req, body, response, err, start)
+	s.endSpan(span, response, err)
 
+	return response, err
+}
+
+func (s *SecureClient) roundTrip(req *http.Request) (*HttpResponse, error) {
 	resp, err := s.Client.Do(req)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in Get()")
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error in Post() while creating NewRequest")
+		return nil, errors.WithMessage(err, "error while reading response body")
 	}
 
 	response := &HttpResponse{
 		StatusCode: resp.StatusCode,
-		Body:       string(body),
+		Body:       string(respBody),
 		Header:     resp.Header,
 	}
 
 	return response, nil
 }
 
-func (s *SecureClient) createRequest(method string, payload io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, s.endpoint, payload)
+//GET, PUT and DELETE are idempotent. A POST is only if it carries an x-idempotency-key, which makes the aspsp
+//return the original result instead of creating the resource again.
+func (s *SecureClient) isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return s.Header.Get(api.XIdempotencyKey) != ""
+	default:
+		return false
+	}
+}
+
+func (s *SecureClient) createRequest(ctx context.Context, method string, payload io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint, payload)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in createRequest()")
 	}
 
-	req.Header = s.Header
+	//cloned, since the traceparent differs per attempt
+	req.Header = s.Header.Clone()
 	return req, nil
 }
 
@@ -2,10 +2,18 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/pkg/errors"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func initTest() {
@@ -39,12 +47,12 @@ func Test_PostService(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client, err := NewSecureHttpClient(tt.args.endpoint, nil)
+			client, err := NewSecureHttpClient(nil, "test", "test", tt.args.endpoint, nil)
 			if err != nil {
 				t.Fatalf("could not create secure client, %v", err)
 			}
 
-			if got, err := client.Post(tt.args.payload); got.StatusCode != tt.want {
+			if got, err := client.Post(context.Background(), tt.args.payload); got.StatusCode != tt.want {
 				t.Error(err)
 				t.Errorf("callService() = %v, want %v", got, tt.want)
 			}
@@ -73,15 +81,191 @@ func Test_GetService(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client, err := NewSecureHttpClient(tt.args.endpoint, nil)
+			client, err := NewSecureHttpClient(nil, "test", "test", tt.args.endpoint, nil)
 			if err != nil {
 				t.Fatalf("could not create secure client, %v", err)
 			}
 
-			if got, err := client.Get(nil); got.StatusCode != tt.want {
+			if got, err := client.Get(context.Background(), nil); got.StatusCode != tt.want {
 				t.Error(err)
 				t.Errorf("callService() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_SecureClient_retry(t *testing.T) {
+	initTest()
+
+	type fields struct {
+		method   string
+		header   http.Header
+		statuses []int
+	}
+	idempotentHeader := http.Header{}
+	idempotentHeader.Set(api.XIdempotencyKey, "key")
+
+	tests := []struct {
+		name         string
+		fields       fields
+		wantStatus   int
+		wantAttempts int
+	}{
+		{"get_retried_on_503", fields{http.MethodGet, http.Header{}, []int{503, 503, 200}}, 200, 3},
+		{"get_retried_on_429_with_retry_after", fields{http.MethodGet, http.Header{}, []int{429, 200}}, 200, 2},
+		{"get_gives_up_after_max_retries", fields{http.MethodGet, http.Header{}, []int{500, 500, 500, 200}}, 500, 3},
+		{"get_not_retried_on_400", fields{http.MethodGet, http.Header{}, []int{400, 200}}, 400, 1},
+		{"post_not_retried", fields{http.MethodPost, http.Header{}, []int{503, 200}}, 503, 1},
+		{"post_with_idempotency_key_retried", fields{http.MethodPost, idempotentHeader, []int{503, 201}}, 201, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server, serverCACertPEM, _ := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if body, _ := ioutil.ReadAll(r.Body); r.Method == http.MethodPost && string(body) != "payload" {
+					t.Errorf("attempt %v got body %q", attempts, body)
+				}
+				status := tt.fields.statuses[attempts]
+				attempts++
+				if status == http.StatusTooManyRequests {
+					w.Header().Set(api.RetryAfter, "0")
+				}
+				w.WriteHeader(status)
+			}))
+
+			aspspId := "retry_" + tt.name
+			cfg := configStub{aspspId + "_TLS_CA_CERT_PEM": serverCACertPEM}
+			client, err := NewSecureHttpClient(cfg, aspspId, "test", server.URL, tt.fields.header)
+			if err != nil {
+				t.Fatalf("could not create secure client, %v", err)
+			}
+
+			var got *HttpResponse
+			if tt.fields.method == http.MethodPost {
+				got, err = client.Post(context.Background(), strings.NewReader("payload"))
+			} else {
+				got, err = client.Get(context.Background(), nil)
+			}
+			if err != nil {
+				t.Fatalf("request failed. err: %v", err)
+			}
+			if got.StatusCode != tt.wantStatus || attempts != tt.wantAttempts {
+				t.Errorf("status = %v attempts = %v, want status %v attempts %v", got.StatusCode, attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_SecureClient_longRetryAfter(t *testing.T) {
+	initTest()
+
+	attempts := 0
+	server, serverCACertPEM, _ := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set(api.RetryAfter, "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	cfg := configStub{"retry_after_TLS_CA_CERT_PEM": serverCACertPEM}
+	client, err := NewSecureHttpClient(cfg, "retry_after", "test", server.URL, http.Header{})
+	if err != nil {
+		t.Fatalf("could not create secure client, %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.Get(context.Background(), nil)
+	var unavailableErr *UnavailableError
+	if !errors.As(err, &unavailableErr) || unavailableErr.RetryAfter != time.Hour {
+		t.Fatalf("Get() error = %v, want UnavailableError with the Retry-After of the aspsp", err)
+	}
+	if attempts != 1 || time.Since(start) > time.Second {
+		t.Errorf("Get() attempts = %v in %v, want it to return without waiting", attempts, time.Since(start))
+	}
+}
+
+func Test_SecureClient_slowBody(t *testing.T) {
+	initTest()
+
+	server, serverCACertPEM, _ := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Data":`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+
+	cfg := configStub{"slow_body_TLS_CA_CERT_PEM": serverCACertPEM, "slow_body_HTTP_CONNECT_TIMEOUT": "100ms",
+		"slow_body_HTTP_READ_TIMEOUT": "100ms"}
+	client, err := NewSecureHttpClient(cfg, "slow_body", "test", server.URL, http.Header{})
+	if err != nil {
+		t.Fatalf("could not create secure client, %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.Post(context.Background(), strings.NewReader("")); err == nil {
+		t.Fatalf("Post() error = nil, want the timeout of the body")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Post() returned in %v, want the request bounded by the connect and read timeouts", time.Since(start))
+	}
+}
+
+func Test_SecureClient_contextCancellation(t *testing.T) {
+	initTest()
+
+	server, serverCACertPEM, _ := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+
+	cfg := configStub{"cancel_TLS_CA_CERT_PEM": serverCACertPEM}
+	client, err := NewSecureHttpClient(cfg, "cancel", "test", server.URL, http.Header{})
+	if err != nil {
+		t.Fatalf("could not create secure client, %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err = client.Get(ctx, nil); err == nil {
+		t.Errorf("Get() expected an error after the context is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get() returned after %v, expected it to stop with the context", elapsed)
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"past_date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"invalid", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %v %v, want %v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_HttpResponse_String(t *testing.T) {
+	resp := HttpResponse{StatusCode: 400, Body: `{"access_token":"abc","refresh_token":"def"}`, Header: http.Header{"Authorization": []string{"Bearer abc"}}}
+
+	want := `{StatusCode: 400, Body: {"access_token":"[REDACTED]","refresh_token":"[REDACTED]"}}`
+	if got := fmt.Sprintf("resp: %v", resp); got != "resp: "+want {
+		t.Errorf("HttpResponse.String() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	outboundRequests = metrics.NewCounterVec("aspsp_requests_total",
+		"Requests sent to the aspsps by status code. error is a network error, rejected is failed fast by the circuit breaker.",
+		"aspsp_id", "endpoint", "status")
+	outboundDuration = metrics.NewHistogramVec("aspsp_request_duration_seconds",
+		"Latency of the requests sent to the aspsps.", metrics.DefaultBuckets, "aspsp_id", "endpoint")
+)
+
+func (s *SecureClient) observe(resp *HttpResponse, start time.Time) {
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	outboundRequests.Inc(s.aspspId, s.endpointType, status)
+	outboundDuration.Observe(time.Since(start).Seconds(), s.aspspId, s.endpointType)
+}
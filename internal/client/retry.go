@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/pkg/errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 2
+	retryBaseDelay    = 200 * time.Millisecond
+	retryMaxDelay     = 5 * time.Second
+)
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newRetryPolicy(cfg Config, aspspId string) (retryPolicy, error) {
+	value, err := FindConfig(cfg, aspspId, api.HttpMaxRetries, strconv.Itoa(defaultMaxRetries))
+	if err != nil {
+		return retryPolicy{}, err
+	}
+
+	maxRetries, err := strconv.Atoi(value)
+	if err != nil || maxRetries < 0 {
+		return retryPolicy{}, fmt.Errorf("invalid %v: %v", api.HttpMaxRetries, value)
+	}
+
+	return retryPolicy{maxRetries: maxRetries, baseDelay: retryBaseDelay, maxDelay: retryMaxDelay}, nil
+}
+
+//backoff returns the exponential delay of the attempt with jitter, so the retries of concurrent requests spread out.
+func (r retryPolicy) backoff(attempt int) time.Duration {
+	delay := r.baseDelay << uint(attempt-1)
+	if delay <= 0 || delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func shouldRetry(resp *HttpResponse, err error) bool {
+	if err != nil {
+		return !isCertificateError(err)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+//parseRetryAfter supports both forms of Retry-After; delay in seconds and http date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+//certificate errors won't go away with a retry.
+func isCertificateError(err error) bool {
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var certificateInvalidError x509.CertificateInvalidError
+	var hostnameError x509.HostnameError
+	var pinningError *certificatePinningError
+
+	return errors.As(err, &unknownAuthorityError) || errors.As(err, &certificateInvalidError) ||
+		errors.As(err, &hostnameError) || errors.As(err, &pinningError)
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"net/http"
+)
+
+//startSpan starts the client span of a single attempt and propagates it to the aspsp with the traceparent header.
+func (s *SecureClient) startSpan(req *http.Request) *tracing.Span {
+	ctx, span := tracing.Start(req.Context(), req.Method+" "+s.endpointType, tracing.KindClient)
+	tracing.Inject(ctx, req.Header)
+
+	url := *req.URL
+	url.RawQuery = logger.Redact(url.RawQuery)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", url.String())
+	span.SetAttribute("aspsp.id", s.aspspId)
+	span.SetAttribute("aspsp.endpoint", s.endpointType)
+	span.SetAttribute(api.XFapiInteractionId, req.Header.Get(api.XFapiInteractionId))
+
+	return span
+}
+
+func (s *SecureClient) endSpan(span *tracing.Span, resp *HttpResponse, err error) {
+	span.RecordError(err)
+	if resp == nil {
+		return
+	}
+
+	span.SetIntAttribute("http.status_code", resp.StatusCode)
+	if aspspInteractionId := resp.Header.Get(api.XFapiInteractionId); aspspInteractionId != "" {
+		span.SetAttribute("aspsp.interaction_id", aspspInteractionId)
+	}
+}
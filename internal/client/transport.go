@@ -0,0 +1,328 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Config looks up the TLS settings of an aspsp. configmanager.Service satisfies it.
+type Config interface {
+	FindByConfigName(aspspId, configName string) (string, error)
+}
+
+//FAPI approved cipher suites for TLS 1.2. TLS 1.3 suites aren't configurable and are always enabled.
+var fapiCipherSuites = map[string]uint16{
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultReadTimeout    = 30 * time.Second
+)
+
+var defaultTransport *settings.Transport
+
+//configTtl bounds how long the transport and the configs of an aspsp are cached, so the config changes which an
+//instance missed, e.g. while redis was down, are applied like the cached configs of configmanager.
+var configTtl = 10 * time.Minute
+
+//Configure sets the default TLS-MA key pair, the audit body mode and the ttl of the cached configs, which are otherwise
+//read from env variables.
+func Configure(transport settings.Transport, audit settings.Audit, configCache settings.ConfigCache) {
+	defaultTransport = &transport
+	auditBodyMode = audit.Body
+	configTtl = configCache.Ttl
+}
+
+//cacheExpiration converts configTtl to the unit of the in-memory cache, which is minutes.
+func cacheExpiration() time.Duration {
+	if configTtl < time.Minute {
+		return cache.NoExpiration
+	}
+
+	return time.Duration(configTtl / time.Minute)
+}
+
+func getDefaultTransport() settings.Transport {
+	if defaultTransport != nil {
+		return *defaultTransport
+	}
+
+	return settings.FromEnv().Transport
+}
+
+func transportCacheId(aspspId string) string {
+	return "http_transport_" + aspspId
+}
+
+//newHttpTransport returns the transport of an aspsp. Each aspsp has its own client certificate, root CAs,
+//minimum TLS version, cipher suites and optionally pinned server certificates.
+//Settings which aren't configured for the aspsp fall back to CLIENT_CA_CERT_PEM, CLIENT_CERT_PEM and CLIENT_KEY_PEM.
+//The transport is cached for CONFIG_CACHE_TTL or until Reset.
+func newHttpTransport(cfg Config, aspspId string) (*http.Transport, error) {
+	if value, found := cacheMem.Get(transportCacheId(aspspId)); found {
+		return value.(*http.Transport), nil
+	}
+
+	tlsConfig, err := newTlsConfig(cfg, aspspId)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error in newHttpTransport(). aspspId: %v", aspspId)
+	}
+
+	connectTimeout, err := findDuration(cfg, aspspId, api.HttpConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error in newHttpTransport(). aspspId: %v", aspspId)
+	}
+	readTimeout, err := findDuration(cfg, aspspId, api.HttpReadTimeout, defaultReadTimeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error in newHttpTransport(). aspspId: %v", aspspId)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   10,
+	}
+	_ = cacheMem.Set(transportCacheId(aspspId), transport, cacheExpiration())
+
+	return transport, nil
+}
+
+func newTlsConfig(cfg Config, aspspId string) (*tls.Config, error) {
+	defaults := getDefaultTransport()
+	serverCACertPEM, err := findConfig(cfg, aspspId, api.TlsCaCertPem, defaults.CaCertPem)
+	if err != nil {
+		return nil, err
+	}
+	clientCertPEM, err := findConfig(cfg, aspspId, api.TlsClientCertPem, defaults.CertPem)
+	if err != nil {
+		return nil, err
+	}
+	clientKeyPEM, err := findConfig(cfg, aspspId, api.TlsClientKeyPem, defaults.KeyPem)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := findConfig(cfg, aspspId, api.TlsMinVersion, "1.2")
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := findConfig(cfg, aspspId, api.TlsCipherSuites, "")
+	if err != nil {
+		return nil, err
+	}
+	pinnedCerts, err := findConfig(cfg, aspspId, api.TlsPinnedCertSha256, "")
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, cert := range strings.Split(serverCACertPEM, ",") {
+		caCert, err := ioutil.ReadFile(strings.TrimSpace(cert))
+		if err != nil {
+			return nil, errors.WithMessage(err, "error while reading cert file")
+		}
+		pool.AppendCertsFromPEM(caCert)
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error while loading x509 certificate")
+	}
+
+	tlsVersion, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tls version: %v", minVersion)
+	}
+
+	suites, err := parseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+		MinVersion:   tlsVersion,
+		CipherSuites: suites,
+	}
+	if pinnedCerts != "" {
+		tlsConfig.VerifyPeerCertificate = verifyPinnedCertificate(strings.Split(pinnedCerts, ","))
+	}
+
+	return tlsConfig, nil
+}
+
+func configsCacheId(aspspId string) string {
+	return "http_configs_" + aspspId
+}
+
+//aspspConfigs are the resolved configs of an aspsp, including the ones which fell back to their default.
+type aspspConfigs struct {
+	sync.Mutex
+	values map[string]string
+}
+
+var configsMu sync.Mutex
+
+func getAspspConfigs(aspspId string) *aspspConfigs {
+	configsMu.Lock()
+	defer configsMu.Unlock()
+
+	if value, found := cacheMem.Get(configsCacheId(aspspId)); found {
+		return value.(*aspspConfigs)
+	}
+
+	configs := &aspspConfigs{values: map[string]string{}}
+	_ = cacheMem.Set(configsCacheId(aspspId), configs, cacheExpiration())
+
+	return configs
+}
+
+//FindConfig returns the aspsp's config value or defaultValue if it isn't configured. The value is resolved once and
+//cached with the transport of the aspsp, so a missing config isn't looked up again by each request. Reset drops it.
+func FindConfig(cfg Config, aspspId, configName, defaultValue string) (string, error) {
+	configs := getAspspConfigs(aspspId)
+	configs.Lock()
+	value, found := configs.values[configName]
+	configs.Unlock()
+	if found {
+		return value, nil
+	}
+
+	value, err := findConfig(cfg, aspspId, configName, defaultValue)
+	if err != nil {
+		return "", err
+	}
+
+	configs.Lock()
+	configs.values[configName] = value
+	configs.Unlock()
+
+	return value, nil
+}
+
+//findConfig returns the aspsp's config value or defaultValue if it isn't configured.
+func findConfig(cfg Config, aspspId, configName, defaultValue string) (string, error) {
+	if cfg == nil {
+		return defaultValue, nil
+	}
+
+	value, err := cfg.FindByConfigName(aspspId, configName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultValue, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+//findDuration returns the aspsp's config value as a duration, e.g. 5s or 500ms.
+func findDuration(cfg Config, aspspId, configName string, defaultValue time.Duration) (time.Duration, error) {
+	value, err := FindConfig(cfg, aspspId, configName, defaultValue.String())
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid %v: %v", configName, value)
+	}
+
+	return duration, nil
+}
+
+//requestTimeout bounds each attempt as a whole, from the connect to the end of the response body.
+//HTTP_CONNECT_TIMEOUT and HTTP_READ_TIMEOUT bound the connect and the response headers of the transport, but a slow
+//body would hold the connection and its MAX_CONCURRENT_REQUESTS slot without it, so their sum bounds the attempt too.
+func requestTimeout(cfg Config, aspspId string) (time.Duration, error) {
+	connectTimeout, err := findDuration(cfg, aspspId, api.HttpConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return 0, err
+	}
+	readTimeout, err := findDuration(cfg, aspspId, api.HttpReadTimeout, defaultReadTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	return connectTimeout + readTimeout, nil
+}
+
+//parseCipherSuites only allows the FAPI approved suites. All of them are used if none is configured.
+func parseCipherSuites(cipherSuites string) ([]uint16, error) {
+	if cipherSuites == "" {
+		return defaultCipherSuites, nil
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(cipherSuites, ",") {
+		suite, ok := fapiCipherSuites[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("cipher suite isn't FAPI approved: %v", name)
+		}
+		suites = append(suites, suite)
+	}
+
+	return suites, nil
+}
+
+//verifyPinnedCertificate accepts the connection only if the server's leaf certificate matches one of
+//the pinned SHA-256 fingerprints(hex). It runs after the chain has been verified against the root CAs.
+func verifyPinnedCertificate(fingerprints []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server didn't present a certificate")
+		}
+
+		sum := sha256.Sum256(rawCerts[0])
+		fingerprint := hex.EncodeToString(sum[:])
+		for _, pinned := range fingerprints {
+			if strings.EqualFold(strings.ReplaceAll(strings.TrimSpace(pinned), ":", ""), fingerprint) {
+				return nil
+			}
+		}
+
+		return &certificatePinningError{fingerprint: fingerprint}
+	}
+}
+
+type certificatePinningError struct {
+	fingerprint string
+}
+
+func (c *certificatePinningError) Error() string {
+	return fmt.Sprintf("server certificate doesn't match the pinned certificates. fingerprint: %v", c.fingerprint)
+}
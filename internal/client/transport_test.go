@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type configStub map[string]string
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if value, found := c[aspspId+"_"+configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+//newTestServer starts a TLS server and writes its certificate, so it can be used as the aspsp's root CA.
+func newTestServer(t *testing.T, handler http.Handler) (server *httptest.Server, serverCACertPEM, fingerprint string) {
+	server = httptest.NewTLSServer(handler)
+
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatalf("couldn't create temp dir. err: %v", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		_ = os.RemoveAll(dir)
+	})
+
+	serverCert := server.Certificate()
+	serverCACertPEM = filepath.Join(dir, "server.cer")
+	if err = ioutil.WriteFile(serverCACertPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Raw}), 0600); err != nil {
+		t.Fatalf("couldn't write the server cert. err: %v", err)
+	}
+	sum := sha256.Sum256(serverCert.Raw)
+
+	return server, serverCACertPEM, hex.EncodeToString(sum[:])
+}
+
+func Test_newHttpTransport(t *testing.T) {
+	initTest()
+
+	server, serverCACertPEM, fingerprint := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cfg := configStub{
+		"pinned_TLS_CA_CERT_PEM":          serverCACertPEM,
+		"pinned_TLS_PINNED_CERT_SHA256":   fingerprint,
+		"mismatch_TLS_CA_CERT_PEM":        serverCACertPEM,
+		"mismatch_TLS_PINNED_CERT_SHA256": "00" + fingerprint[2:],
+		"tls13_TLS_CA_CERT_PEM":           serverCACertPEM,
+		"tls13_TLS_MIN_VERSION":           "1.3",
+		"cipher_TLS_CIPHER_SUITES":        "TLS_RSA_WITH_AES_128_CBC_SHA",
+		"version_TLS_MIN_VERSION":         "1.0",
+	}
+
+	tests := []struct {
+		name           string
+		aspspId        string
+		wantConfigErr  bool
+		wantRequestErr bool
+	}{
+		{"own_root_ca_and_pinned_cert", "pinned", false, false},
+		{"pinned_cert_mismatch", "mismatch", false, true},
+		{"tls13_only", "tls13", false, false},
+		{"env_root_ca_untrusted_server", "default", false, true},
+		{"cipher_suite_not_fapi_approved", "cipher", true, false},
+		{"tls_version_not_supported", "version", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewSecureHttpClient(cfg, tt.aspspId, "test", server.URL, http.Header{})
+			if (err != nil) != tt.wantConfigErr {
+				t.Fatalf("NewSecureHttpClient() error = %v, wantErr %v", err, tt.wantConfigErr)
+			}
+			if tt.wantConfigErr {
+				return
+			}
+
+			got, err := client.Get(context.Background(), nil)
+			if (err != nil) != tt.wantRequestErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantRequestErr)
+			}
+			if !tt.wantRequestErr && got.StatusCode != http.StatusOK {
+				t.Errorf("Get() status = %v, want %v", got.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+type countingConfig struct {
+	configStub
+	lookups int
+}
+
+func (c *countingConfig) FindByConfigName(aspspId, configName string) (string, error) {
+	c.lookups++
+	return c.configStub.FindByConfigName(aspspId, configName)
+}
+
+func Test_FindConfig(t *testing.T) {
+	cfg := &countingConfig{configStub: configStub{"cached_HTTP_MAX_RETRIES": "1"}}
+
+	for i := 0; i < 2; i++ {
+		if got, err := FindConfig(cfg, "cached", "HTTP_MAX_RETRIES", "2"); err != nil || got != "1" {
+			t.Errorf("FindConfig() = %v, %v, want 1", got, err)
+		}
+		if got, err := FindConfig(cfg, "cached", "REQUEST_OBJECT_SIGNING_ALG", ""); err != nil || got != "" {
+			t.Errorf("FindConfig() = %v, %v, want the default", got, err)
+		}
+	}
+	if cfg.lookups != 2 {
+		t.Errorf("FindConfig() lookups = %v, want each config looked up once, including the missing one", cfg.lookups)
+	}
+
+	Reset("cached")
+	cfg.configStub["cached_HTTP_MAX_RETRIES"] = "3"
+	if got, _ := FindConfig(cfg, "cached", "HTTP_MAX_RETRIES", "2"); got != "3" {
+		t.Errorf("FindConfig() after Reset = %v, want the changed value 3", got)
+	}
+}
+
+func Test_cacheExpiration(t *testing.T) {
+	defer func(ttl time.Duration) { configTtl = ttl }(configTtl)
+
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{10 * time.Minute, 10},
+		{90 * time.Second, 1},
+		{0, cache.NoExpiration},
+	}
+	for _, tt := range tests {
+		configTtl = tt.ttl
+		if got := cacheExpiration(); got != tt.want {
+			t.Errorf("cacheExpiration() of %v = %v, want %v", tt.ttl, got, tt.want)
+		}
+	}
+}
@@ -1,31 +1,202 @@
 package config
 
 import (
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"github.com/labstack/gommon/log"
+	"github.com/pkg/errors"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func NewEchoEngine() *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
+	e.HTTPErrorHandler = errorHandler
 
 	// Middlewares
 	e.Use(middleware.RequestID())
-	e.Use(middleware.Logger())
+	e.Use(httpMetrics())
+	e.Use(requestLogger())
 	e.Use(middleware.Recover())
+	e.Use(fapiInteraction())
+	e.Use(trace())
 	e.Use(validate())
 
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+
 	return e
 }
 
-var permittedUri = []string{"/internal", "/callback", "/favicon.ico"}
+var (
+	httpRequests = metrics.NewCounterVec("http_requests_total",
+		"Requests served by route and status code.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Latency of the requests served by route.", metrics.DefaultBuckets, "method", "route")
+)
+
+//Custom middleware to record the request metrics. The route pattern is used as label rather than the uri,
+//so the path parameters e.g. accountId don't grow the number of series.
+func httpMetrics() echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := handler(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			method := c.Request().Method
+			httpRequests.Inc(method, route, strconv.Itoa(c.Response().Status))
+			httpDuration.Observe(time.Since(start).Seconds(), method, route)
+
+			return nil
+		}
+	}
+}
+
+//Custom middleware to log the requests. Query strings are redacted since the callback receives the authorisation code.
+func requestLogger() echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := handler(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			logger.Info("request",
+				logger.String("rid", res.Header().Get(echo.HeaderXRequestID)),
+				logger.String("remoteIp", c.RealIP()),
+				logger.String("method", req.Method),
+				logger.String("uri", req.RequestURI),
+				logger.Int("status", res.Status),
+				logger.Any("latencyMs", time.Since(start).Milliseconds()),
+				logger.Err(err))
+
+			return nil
+		}
+	}
+}
+
+//Custom middleware to attach the FAPI interaction to the request context.
+//The echo request id is used as x-fapi-interaction-id towards the aspsp and the TPP.
+func fapiInteraction() echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rid := c.Response().Header().Get(echo.HeaderXRequestID)
+			interaction := &client.Interaction{
+				InteractionId:     rid,
+				CustomerIpAddress: c.Request().Header.Get(api.XFapiCustomerIpAddress),
+			}
+			c.SetRequest(c.Request().WithContext(client.WithInteraction(c.Request().Context(), interaction)))
+			c.Response().Header().Set(api.XFapiInteractionId, rid)
+
+			return handler(c)
+		}
+	}
+}
+
+//Custom middleware to start the server span of the request. The caller's trace is continued if it sends a traceparent.
+func trace() echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			ctx, span := tracing.Start(tracing.Extract(req.Context(), req.Header), req.Method+" "+route, tracing.KindServer)
+			defer span.End()
+			span.SetAttribute("http.method", req.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("request.id", c.Response().Header().Get(echo.HeaderXRequestID))
+			span.SetAttribute(api.XFapiInteractionId, client.InteractionFrom(ctx).InteractionId)
+			c.SetRequest(req.WithContext(ctx))
+
+			err := handler(c)
+			span.RecordError(err)
+			span.SetIntAttribute("http.status_code", responseStatus(c, err))
+
+			return err
+		}
+	}
+}
+
+//responseStatus returns the status which will be sent. The error isn't handled yet, so its status is used.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+
+	return apiError(err).Status
+}
+
+//errorHandler sends the errors returned by the handlers in the OBErrorResponse1 format. Only the messages of *api.Error
+//are sent. The other errors are logged and sent as an unexpected error, so the internal details don't leak.
+func errorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	rid := c.Response().Header().Get(echo.HeaderXRequestID)
+	e := apiError(err)
+	if e.Status >= http.StatusInternalServerError {
+		logger.Error("request failed", logger.String("rid", rid), logger.Int("status", e.Status), logger.Err(err))
+	} else {
+		logger.Debug("request rejected", logger.String("rid", rid), logger.Int("status", e.Status), logger.Err(err))
+	}
+
+	var retryAfter api.RetryAfterError
+	if errors.As(err, &retryAfter) {
+		c.Response().Header().Set(api.RetryAfter, retryAfter.RetryAfterSeconds())
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(e.Status)
+	} else {
+		err = c.JSON(e.Status, e.Response(rid))
+	}
+	if err != nil {
+		logger.Warn("couldn't send the error response", logger.String("rid", rid), logger.Err(err))
+	}
+}
+
+//apiError maps err to the error which is sent to the caller.
+func apiError(err error) *api.Error {
+	var e *api.Error
+	var converter api.ErrorConverter
+	var httpError *echo.HTTPError
+	if !errors.As(err, &e) && !errors.As(err, &converter) && errors.As(err, &httpError) {
+		code := api.UnexpectedError
+		switch httpError.Code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			code = api.HeaderInvalid
+		case http.StatusNotFound:
+			code = api.ResourceNotFound
+		}
+		return api.NewError(httpError.Code, code, fmt.Sprint(httpError.Message)).Wrap(err)
+	}
+
+	return api.AsError(err)
+}
+
+var permittedUri = []string{"/internal", "/callback", "/favicon.ico", "/metrics", "/health"}
 
 //Custom middleware to validate requests JWT
 func validate() echo.MiddlewareFunc {
@@ -40,11 +211,10 @@ func validate() echo.MiddlewareFunc {
 				}
 			}
 
-			log.Debug(reqUri + " is not permitted. Checking for JWT validation...")
-			keyData, err := security.GetSecretKey(api.InternalSignKey, os.Getenv("INTERNAL_SIGN_KEY"))
+			logger.Debug("uri is not permitted. checking for JWT validation", logger.String("uri", reqUri), logger.String("rid", rid))
+			keyData, err := security.GetInternalSignKey()
 			if err != nil {
-				log.Error(err)
-				return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, "internal server error"))
+				return errors.WithMessage(err, "couldn't load the internal sign key")
 			}
 
 			var bearerToken string
@@ -53,10 +223,12 @@ func validate() echo.MiddlewareFunc {
 				bearerToken = authorizationHeader[7:]
 			}
 
-			if err := security.VerifyJwt(bearerToken, jwt.SigningMethodHS256, keyData); err != nil {
-				log.Error(err)
-				return c.JSON(http.StatusUnauthorized, api.JsonResponse(rid, "Unauthorized request"))
+			claims, err := security.ParseJwt(bearerToken, jwt.SigningMethodHS256, keyData)
+			if err != nil {
+				logger.Warn("unauthorized request", logger.String("rid", rid), logger.Err(err))
+				return api.ErrUnauthorized.Wrap(err)
 			}
+			c.Set(api.JwtClaims, claims)
 
 			return handler(c)
 		}
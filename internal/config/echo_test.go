@@ -0,0 +1,71 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validationError struct{}
+
+func (validationError) Error() string {
+	return "invalid"
+}
+
+func (validationError) ApiError() *api.Error {
+	return api.NewFieldError(api.FieldInvalid, "Data.Permissions", "invalid")
+}
+
+func Test_errorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   api.ErrorCode
+		wantPath   string
+	}{
+		{"api_error", api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty"), http.StatusBadRequest, api.FieldMissing, "aspspId"},
+		{"wrapped_api_error", errors.WithMessage(api.ErrUnauthorized.Wrap(errors.New("token is expired")), "error in validate()"),
+			http.StatusUnauthorized, api.HeaderInvalid, ""},
+		{"converter", errors.WithMessage(validationError{}, "error in Validate()"), http.StatusBadRequest, api.FieldInvalid, "Data.Permissions"},
+		{"no_rows", errors.WithMessage(sql.ErrNoRows, "error in findByCid()"), http.StatusNotFound, api.ResourceNotFound, ""},
+		{"unavailable", errors.WithMessage(&client.UnavailableError{AspspId: "test", RetryAfter: time.Minute}, "error in processCall()"),
+			http.StatusServiceUnavailable, api.UnexpectedError, ""},
+		{"echo_not_found", echo.ErrNotFound, http.StatusNotFound, api.ResourceNotFound, ""},
+		{"internal", errors.New("pq: password authentication failed for user postgres"), http.StatusInternalServerError, api.UnexpectedError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = errorHandler
+			e.GET("/", func(c echo.Context) error {
+				return tt.err
+			})
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			var got api.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response %v. err: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantStatus || len(got.Errors) != 1 || got.Errors[0].ErrorCode != tt.wantCode || got.Errors[0].Path != tt.wantPath {
+				t.Errorf("errorHandler() = %v %v, want %v %v %v", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantCode, tt.wantPath)
+			}
+			if strings.Contains(rec.Body.String(), "pq:") || strings.Contains(rec.Body.String(), "error in") {
+				t.Errorf("errorHandler() leaked the cause: %v", rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get(api.RetryAfter) != "60" {
+				t.Errorf("errorHandler() Retry-After = %v", rec.Header().Get(api.RetryAfter))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Closer releases a resource or stops a background worker on shutdown.
+type Closer struct {
+	Name  string
+	Close func(ctx context.Context) error
+}
+
+//Workers runs the background workers, e.g. the discovery and the config change listener, until its closer is called.
+type Workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWorkers() *Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workers{ctx: ctx, cancel: cancel}
+}
+
+//Go runs the worker in its own goroutine. The worker should return once ctx is done.
+func (w *Workers) Go(run func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		run(w.ctx)
+	}()
+}
+
+//Closer cancels the workers and waits for them to return. It should be placed before the closers of the services
+//and the resources the workers use, so they aren't used after they are closed.
+func (w *Workers) Closer(name string) Closer {
+	return Closer{Name: name, Close: func(ctx context.Context) error {
+		w.cancel()
+
+		done := make(chan struct{})
+		go func() {
+			w.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}}
+}
+
+//StartServer serves e on address until SIGINT or SIGTERM. Then it stops accepting connections, waits for the in-flight
+//requests and calls the closers in the given order, all within the shutdown timeout.
+func StartServer(e *echo.Echo, address string, shutdownTimeout time.Duration, closers ...Closer) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", logger.String("address", address))
+		if err := e.Start(address); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var startErr error
+	select {
+	case sig := <-quit:
+		logger.Info("shutting down", logger.String("signal", sig.String()))
+	case startErr = <-serverErr:
+		logger.Error("server stopped", logger.Err(startErr))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		logger.Error("in-flight requests couldn't be completed", logger.Err(err))
+	}
+	for _, closer := range closers {
+		if err := closer.Close(ctx); err != nil {
+			logger.Error("couldn't close "+closer.Name, logger.Err(err))
+		}
+	}
+	logger.Info("shutdown is completed")
+
+	return startErr
+}
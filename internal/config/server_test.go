@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Workers_Closer(t *testing.T) {
+	workers := NewWorkers()
+	stopped := make(chan struct{})
+	workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		//the worker still runs after the cancel, e.g. while finishing its write
+		time.Sleep(50 * time.Millisecond)
+		close(stopped)
+	})
+
+	if err := workers.Closer("background jobs").Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("Close() returned before the worker")
+	}
+
+	stuck := NewWorkers()
+	stuck.Go(func(ctx context.Context) {
+		time.Sleep(time.Second)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stuck.Closer("stuck").Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
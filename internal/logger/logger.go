@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Level of a log entry. Entries below the level set with LOG_LEVEL are discarded.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+//Field is a key value pair which is written as a separate json field of the entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+var std = struct {
+	sync.Mutex
+	out   io.Writer
+	level Level
+}{out: os.Stdout, level: ParseLevel(settings.FromEnv().Log.Level)}
+
+//SetOutput changes where the entries are written. Passing nil resets it to os.Stdout.
+func SetOutput(w io.Writer) {
+	std.Lock()
+	defer std.Unlock()
+	if w == nil {
+		w = os.Stdout
+	}
+	std.out = w
+}
+
+func SetLevel(level Level) {
+	std.Lock()
+	defer std.Unlock()
+	std.level = level
+}
+
+//ParseLevel returns the level of its name, e.g. debug. Unknown names are InfoLevel.
+func ParseLevel(value string) Level {
+	for level, name := range levelNames {
+		if strings.EqualFold(value, name) {
+			return level
+		}
+	}
+
+	return InfoLevel
+}
+
+func Debug(msg string, fields ...Field) {
+	write(DebugLevel, msg, fields)
+}
+
+func Info(msg string, fields ...Field) {
+	write(InfoLevel, msg, fields)
+}
+
+func Warn(msg string, fields ...Field) {
+	write(WarnLevel, msg, fields)
+}
+
+func Error(msg string, fields ...Field) {
+	write(ErrorLevel, msg, fields)
+}
+
+//Fatal writes the entry and exits the application.
+func Fatal(msg string, fields ...Field) {
+	write(FatalLevel, msg, fields)
+	os.Exit(1)
+}
+
+func write(level Level, msg string, fields []Field) {
+	std.Lock()
+	defer std.Unlock()
+
+	if level < std.level {
+		return
+	}
+
+	entry := make(map[string]interface{}, len(fields)+3)
+	for _, field := range fields {
+		entry[field.Key] = redactField(field.Key, field.Value)
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = levelNames[level]
+	entry["msg"] = Redact(msg)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		data = []byte(fmt.Sprintf(`{"level":"error","msg":"couldn't marshal the log entry. err: %v"}`, err))
+	}
+	_, _ = std.out.Write(append(data, '\n'))
+}
+
+func redactField(key string, value interface{}) interface{} {
+	if isSecret(key) {
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case error:
+		return Redact(v.Error())
+	case http.Header:
+		return RedactHeader(v)
+	case fmt.Stringer:
+		return Redact(v.String())
+	default:
+		return v
+	}
+}
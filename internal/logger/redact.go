@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+//secret parameters which are never written to the logs or the audit trail.
+var secretParameters = []string{
+	"access_token", "refresh_token", "id_token", "code", "client_assertion", "client_secret",
+	"registration_access_token", "password", "passphrase",
+}
+
+var (
+	jsonSecretRegexp   = regexp.MustCompile(`("(?:` + strings.Join(secretParameters, "|") + `)"\s*:\s*)"[^"]*"`)
+	formSecretRegexp   = regexp.MustCompile(`((?:^|[&?])(?:` + strings.Join(secretParameters, "|") + `)=)[^&\s]*`)
+	bearerSecretRegexp = regexp.MustCompile(`((?i:bearer|basic)\s+)[A-Za-z0-9\-._~+/]+=*`)
+)
+
+//secret field keys, e.g. resourceAccessToken or refresh_token, compared without _ and - and case insensitive.
+var secretKeys = map[string]bool{
+	"accesstoken":             true,
+	"refreshtoken":            true,
+	"resourceaccesstoken":     true,
+	"resourcerefreshtoken":    true,
+	"idtoken":                 true,
+	"token":                   true,
+	"code":                    true,
+	"clientassertion":         true,
+	"clientsecret":            true,
+	"registrationaccesstoken": true,
+	"authorization":           true,
+	"password":                true,
+	"passphrase":              true,
+}
+
+func isSecret(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	return secretKeys[normalized]
+}
+
+//Redact masks the tokens, codes and client assertions in a json or form encoded payload, a query string
+//or a free text which contains Bearer/Basic credentials.
+func Redact(value string) string {
+	value = jsonSecretRegexp.ReplaceAllString(value, `$1"`+redacted+`"`)
+	value = formSecretRegexp.ReplaceAllString(value, "${1}"+redacted)
+	return bearerSecretRegexp.ReplaceAllString(value, "${1}"+redacted)
+}
+
+//RedactHeader returns a copy of the header with Authorization and other credentials masked.
+func RedactHeader(header http.Header) http.Header {
+	masked := http.Header{}
+	for key, values := range header {
+		if isSecret(key) {
+			masked[key] = []string{redacted}
+			continue
+		}
+		for _, v := range values {
+			masked.Add(key, Redact(v))
+		}
+	}
+
+	return masked
+}
+
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"net/http"
+)
+
+//DefaultBuckets are the latency buckets in seconds.
+var DefaultBuckets = prometheus.DefBuckets
+
+//registry holds the metrics of the service together with the go runtime and process metrics.
+var registry = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+	return r
+}
+
+//register returns the collector which is already registered with the same name and labels, e.g. when a package
+//defines its metrics more than once, rather than failing. It panics only if the metric is invalid or its name is
+//used by another metric with different labels or help.
+func register(c prometheus.Collector) prometheus.Collector {
+	if err := registry.Register(c); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector
+		}
+		panic(err)
+	}
+
+	return c
+}
+
+//Handler serves all registered metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+//CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec *prometheus.CounterVec
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames)
+	return &CounterVec{vec: register(vec).(*prometheus.CounterVec)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Inc()
+}
+
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(value)
+}
+
+//Value returns the current value of the labels.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	var m dto.Metric
+	if err := c.vec.WithLabelValues(labelValues...).Write(&m); err != nil {
+		return 0
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+//HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	vec *prometheus.HistogramVec
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labelNames)
+	return &HistogramVec{vec: register(vec).(*prometheus.HistogramVec)}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
+
+//Sample is a single value of a GaugeFunc.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+//GaugeFunc reads its values when the metrics are scraped, e.g. from sql.DB.Stats().
+type GaugeFunc struct {
+	desc    *prometheus.Desc
+	collect func() []Sample
+}
+
+func NewGaugeFunc(name, help string, labelNames []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    prometheus.NewDesc(name, help, labelNames, nil),
+		collect: collect,
+	}
+
+	return register(g).(*GaugeFunc)
+}
+
+func (g *GaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *GaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	for _, sample := range g.collect() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, sample.Value, sample.LabelValues...)
+	}
+}
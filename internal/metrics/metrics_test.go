@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T) (string, string) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("couldn't read the metrics. err: %v", err)
+	}
+
+	return string(body), rec.Header().Get("Content-Type")
+}
+
+func Test_Handler(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Test requests.", "route", "status")
+	counter.Inc("/accounts", "200")
+	counter.Add(2, "/accounts", "200")
+	counter.Inc(`/a"b`, "500")
+
+	histogram := NewHistogramVec("test_duration_seconds", "Test latency.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/accounts")
+	histogram.Observe(0.5, "/accounts")
+
+	NewGaugeFunc("test_open_connections", "Test gauge.", nil, func() []Sample {
+		return []Sample{{Value: 3}}
+	})
+
+	got, contentType := scrape(t)
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Handler() content type = %v", contentType)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"counter_header", "# HELP test_requests_total Test requests.\n# TYPE test_requests_total counter\n"},
+		{"counter_value", `test_requests_total{route="/accounts",status="200"} 3` + "\n"},
+		{"counter_escaped_label", `test_requests_total{route="/a\"b",status="500"} 1` + "\n"},
+		{"histogram_bucket", `test_duration_seconds_bucket{route="/accounts",le="0.1"} 1` + "\n"},
+		{"histogram_cumulative_bucket", `test_duration_seconds_bucket{route="/accounts",le="1"} 2` + "\n"},
+		{"histogram_inf_bucket", `test_duration_seconds_bucket{route="/accounts",le="+Inf"} 2` + "\n"},
+		{"histogram_sum", `test_duration_seconds_sum{route="/accounts"} 0.55` + "\n"},
+		{"histogram_count", `test_duration_seconds_count{route="/accounts"} 2` + "\n"},
+		{"gauge_without_labels", "test_open_connections 3\n"},
+		{"go_runtime", "# TYPE go_goroutines gauge\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("Handler() = %v, want to contain %v", got, tt.want)
+			}
+		})
+	}
+
+	if counter.Value("/accounts", "200") != 3 {
+		t.Errorf("Value() = %v, want 3", counter.Value("/accounts", "200"))
+	}
+}
+
+func Test_register_duplicate(t *testing.T) {
+	first := NewCounterVec("test_duplicate_total", "Test duplicate.", "route")
+	second := NewCounterVec("test_duplicate_total", "Test duplicate.", "route")
+	first.Inc("/accounts")
+	second.Inc("/accounts")
+
+	if got := first.Value("/accounts"); got != 2 {
+		t.Errorf("Value() = %v, want 2 as the duplicate shares the registered counter", got)
+	}
+
+	collect := func() []Sample { return []Sample{{Value: 1}} }
+	if NewGaugeFunc("test_duplicate_gauge", "Test duplicate.", nil, collect) != NewGaugeFunc("test_duplicate_gauge", "Test duplicate.", nil, collect) {
+		t.Errorf("NewGaugeFunc() should return the registered gauge for a duplicate")
+	}
+}
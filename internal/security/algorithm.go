@@ -0,0 +1,123 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+)
+
+//signing methods in preference order. PS256 and ES256 are the FAPI approved ones, RS256 is kept for older ASPSPs.
+var preferredSigningMethods = []jwt.SigningMethod{jwt.SigningMethodPS256, jwt.SigningMethodES256, jwt.SigningMethodRS256}
+
+//DefaultSigningMethod is used when an aspsp doesn't advertise request_object_signing_alg_values_supported.
+var DefaultSigningMethod jwt.SigningMethod = jwt.SigningMethodPS256
+
+//NegotiateSigningMethod picks the signing method for request objects and client assertions of an aspsp.
+//supportedValues is the aspsp's request_object_signing_alg_values_supported as a comma or space separated list.
+//Only the algorithms which can be produced with the current signer's key are considered.
+func NegotiateSigningMethod(supportedValues string) (jwt.SigningMethod, error) {
+	signer, err := getSigner()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NegotiateSigningMethod()")
+	}
+
+	signerAlgorithms, err := signer.Algorithms()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NegotiateSigningMethod()")
+	}
+
+	aspspAlgorithms := strings.FieldsFunc(supportedValues, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(aspspAlgorithms) == 0 {
+		aspspAlgorithms = []string{DefaultSigningMethod.Alg()}
+	}
+
+	for _, signingMethod := range preferredSigningMethods {
+		if contains(aspspAlgorithms, signingMethod.Alg()) && (len(signerAlgorithms) == 0 || contains(signerAlgorithms, signingMethod.Alg())) {
+			return signingMethod, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error in NegotiateSigningMethod(). no common signing algorithm. aspsp: %v, signer: %v", aspspAlgorithms, signerAlgorithms)
+}
+
+//returns the JWS algorithms a private key can produce.
+func keyAlgorithms(key interface{}) ([]string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return []string{jwt.SigningMethodPS256.Alg(), jwt.SigningMethodRS256.Alg()}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().BitSize == jwt.SigningMethodES256.CurveBits {
+			return []string{jwt.SigningMethodES256.Alg()}, nil
+		}
+		return nil, fmt.Errorf("unsupported curve: %v", k.Curve.Params().Name)
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+//toJoseSignature converts an ASN.1 DER encoded ECDSA signature, which most HSMs and signing services return,
+//into the fixed length R||S form JWS requires(RFC 7518 section 3.4). Signatures of other methods are returned as they are.
+//A DER signature can be as long as an R||S one, so it is parsed first and the signature is only taken as R||S if it
+//isn't DER encoded.
+func toJoseSignature(signature string, signingMethod jwt.SigningMethod) (string, error) {
+	ecdsaMethod, ok := signingMethod.(*jwt.SigningMethodECDSA)
+	if !ok {
+		return signature, nil
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in toJoseSignature()")
+	}
+
+	r, s, ok := parseDerSignature(sig, ecdsaMethod.KeySize)
+	if !ok {
+		if len(sig) == 2*ecdsaMethod.KeySize {
+			return signature, nil
+		}
+		return "", fmt.Errorf("error in toJoseSignature(). signature is neither DER encoded nor R||S of %v", ecdsaMethod.Alg())
+	}
+
+	joseSignature := make([]byte, 2*ecdsaMethod.KeySize)
+	copy(joseSignature[ecdsaMethod.KeySize-len(r):ecdsaMethod.KeySize], r)
+	copy(joseSignature[2*ecdsaMethod.KeySize-len(s):], s)
+
+	return base64.RawURLEncoding.EncodeToString(joseSignature), nil
+}
+
+//parseDerSignature returns R and S of a DER encoded signature. It fails if anything follows the sequence, or R or S
+//isn't a positive integer of the key size.
+func parseDerSignature(sig []byte, keySize int) ([]byte, []byte, bool) {
+	var derSignature struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(sig, &derSignature)
+	if err != nil || len(rest) != 0 || derSignature.R.Sign() <= 0 || derSignature.S.Sign() <= 0 {
+		return nil, nil, false
+	}
+
+	r, s := derSignature.R.Bytes(), derSignature.S.Bytes()
+	if len(r) > keySize || len(s) > keySize {
+		return nil, nil, false
+	}
+
+	return r, s, true
+}
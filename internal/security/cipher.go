@@ -0,0 +1,76 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+//encryptedPrefix marks the values which are encrypted by Cipher, so the values which were stored before the key was
+//set are still read as they are.
+const encryptedPrefix = "enc:v1:"
+
+//Cipher encrypts the values which are kept at rest, e.g. the client secrets of the aspsps in config_table.
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	//Decrypt returns the values which weren't encrypted by Encrypt as they are.
+	Decrypt(value string) (string, error)
+}
+
+type aesCipher struct {
+	aead cipher.AEAD
+}
+
+//NewCipher returns the AES-GCM cipher of the base64 encoded 16, 24 or 32 bytes key. It returns nil for an empty key.
+func NewCipher(key string) (Cipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewCipher(). key isn't base64 encoded")
+	}
+	block, err := aes.NewCipher(data)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewCipher(). key should be 16, 24 or 32 bytes")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewCipher()")
+	}
+
+	return &aesCipher{aead: aead}, nil
+}
+
+func (c aesCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithMessage(err, "error in Encrypt()")
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c aesCipher) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", fmt.Errorf("error in Decrypt(). invalid encrypted value")
+	}
+	plaintext, err := c.aead.Open(nil, data[:c.aead.NonceSize()], data[c.aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in Decrypt(). the value was encrypted with another key")
+	}
+
+	return string(plaintext), nil
+}
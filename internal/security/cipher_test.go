@@ -0,0 +1,41 @@
+package security
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestNewCipher(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+
+	encrypted, err := c.Encrypt("secret-1")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "secret-1") {
+		t.Errorf("Encrypt() = %v, want an encrypted value", encrypted)
+	}
+	if got, err := c.Decrypt(encrypted); err != nil || got != "secret-1" {
+		t.Errorf("Decrypt() = %v, %v, want secret-1", got, err)
+	}
+	if got, err := c.Decrypt("plain"); err != nil || got != "plain" {
+		t.Errorf("Decrypt() = %v, %v, want the unencrypted value as it is", got, err)
+	}
+
+	other, _ := NewCipher(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210")))
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Error("Decrypt() with another key error = nil, want an error")
+	}
+
+	if c, err := NewCipher(""); c != nil || err != nil {
+		t.Errorf("NewCipher(\"\") = %v, %v, want nil", c, err)
+	}
+	if _, err := NewCipher(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("NewCipher() with a 5 bytes key error = nil, want an error")
+	}
+}
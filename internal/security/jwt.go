@@ -1,15 +1,16 @@
 package security
 
 import (
+	"crypto/ecdsa"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/pkg/errors"
 	"io/ioutil"
-	"os"
 	"strings"
 	"time"
 )
@@ -23,7 +24,7 @@ func init() {
 }
 
 func GenerateJwtWithClaims(claims jwt.MapClaims, signingMethod jwt.SigningMethod) (string, error) {
-	if signingMethod == jwt.SigningMethodRS256 || signingMethod == jwt.SigningMethodPS256 {
+	if isAsymmetric(signingMethod) {
 		return signWithPrivateKey(claims, signingMethod)
 	}
 
@@ -35,7 +36,7 @@ func GenerateJwtWithClaims(claims jwt.MapClaims, signingMethod jwt.SigningMethod
 }
 
 func GenerateJwtWithJsonString(jsonBody string, signingMethod jwt.SigningMethod) (string, error) {
-	if signingMethod == jwt.SigningMethodRS256 || signingMethod == jwt.SigningMethodPS256 {
+	if isAsymmetric(signingMethod) {
 		return signJsonStringWithPrivateKey(jsonBody, signingMethod)
 	}
 
@@ -46,8 +47,12 @@ func GenerateJwtWithJsonString(jsonBody string, signingMethod jwt.SigningMethod)
 	return "", fmt.Errorf("error in GenerateJwtWithJsonString(). unsupported signing algorithm: %v", signingMethod.Alg())
 }
 
+func isAsymmetric(signingMethod jwt.SigningMethod) bool {
+	return signingMethod == jwt.SigningMethodRS256 || signingMethod == jwt.SigningMethodPS256 || signingMethod == jwt.SigningMethodES256
+}
+
 func signWithSecret(claims jwt.MapClaims, signingMethod jwt.SigningMethod) (string, error) {
-	keyData, err := GetSecretKey(api.InternalSignKey, os.Getenv("INTERNAL_SIGN_KEY"))
+	keyData, err := GetInternalSignKey()
 	if err != nil {
 		return "", errors.WithMessage(err, "error in signWithSecret()")
 	}
@@ -58,7 +63,7 @@ func signWithSecret(claims jwt.MapClaims, signingMethod jwt.SigningMethod) (stri
 }
 
 func signJsonStringWithSecret(jsonBody string, signingMethod jwt.SigningMethod) (string, error) {
-	keyData, err := GetSecretKey(api.InternalSignKey, os.Getenv("INTERNAL_SIGN_KEY"))
+	keyData, err := GetInternalSignKey()
 	if err != nil {
 		return "", errors.WithMessage(err, "error in signJsonStringWithSecret()")
 	}
@@ -68,53 +73,61 @@ func signJsonStringWithSecret(jsonBody string, signingMethod jwt.SigningMethod)
 		"alg": signingMethod.Alg(),
 	}
 
-	return signJwtString(headers, jsonBody, signingMethod, keyData)
+	return signJwtString(headers, jsonBody, signingMethod, keySigner{key: keyData})
 }
 
 func signWithPrivateKey(claims jwt.MapClaims, signingMethod jwt.SigningMethod) (string, error) {
-	key, err := GetPrivateKey(api.ObSignKey, os.Getenv("OB_SIGN_KEY"))
+	signer, err := getSigner()
 	if err != nil {
 		return "", errors.WithMessage(err, "error in signWithPrivateKey()")
 	}
 
 	token := jwt.NewWithClaims(signingMethod, claims)
-	kid := os.Getenv("KID")
-	token.Header["kid"] = kid
+	token.Header["kid"] = signer.Kid()
+
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", errors.WithMessage(err, "error in signWithPrivateKey()")
+	}
+
+	sig, err := signer.Sign(signingString, signingMethod)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in signWithPrivateKey()")
+	}
 
-	return token.SignedString(key)
+	return strings.Join([]string{signingString, sig}, "."), nil
 }
 
 func signJsonStringWithPrivateKey(jsonBody string, signingMethod jwt.SigningMethod) (string, error) {
-	key, err := GetPrivateKey(api.ObSignKey, os.Getenv("OB_SIGN_KEY"))
+	signer, err := getSigner()
 	if err != nil {
 		return "", errors.WithMessage(err, "error in signJsonStringWithPrivateKey()")
 	}
 
-	kid := os.Getenv("KID")
 	headers := map[string]interface{}{
 		"typ": "JWT",
 		"alg": signingMethod.Alg(),
-		"kid": kid,
+		"kid": signer.Kid(),
 	}
 
-	return signJwtString(headers, jsonBody, signingMethod, key)
+	return signJwtString(headers, jsonBody, signingMethod, signer)
 }
 
 func CreateTokenTime(addMinute time.Duration) int64 {
 	return time.Now().Add(time.Minute * addMinute).Unix()
 }
 
-func GetPrivateKey(cacheId, keyAddress string) (*rsa.PrivateKey, error) {
+//GetPrivateKey loads a PEM encoded RSA or EC private key. It returns either *rsa.PrivateKey or *ecdsa.PrivateKey
+func GetPrivateKey(cacheId, keyAddress string) (interface{}, error) {
 	if value, found := cacheMem.Get(cacheId); found {
-		return value.(*rsa.PrivateKey), nil
+		return value, nil
 	}
 
 	keyData, err := ioutil.ReadFile(keyAddress)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in GetPrivateKey(). couldn't parse private key file")
 	}
-	var key *rsa.PrivateKey
-	key, err = jwt.ParseRSAPrivateKeyFromPEM(keyData)
+	key, err := parsePrivateKeyFromPEM(keyData)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in GetPrivateKey(). couldn't parse private key")
 	}
@@ -123,6 +136,32 @@ func GetPrivateKey(cacheId, keyAddress string) (*rsa.PrivateKey, error) {
 	return key, nil
 }
 
+//parses PKCS#1, SEC 1 or PKCS#8 encoded keys. Only RSA and EC keys are accepted.
+func parsePrivateKeyFromPEM(keyData []byte) (interface{}, error) {
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+}
+
 func GetSecretKey(cacheId, keyAddress string) ([]byte, error) {
 	if value, found := cacheMem.Get(cacheId); found {
 		return value.([]byte), nil
@@ -140,7 +179,7 @@ func GetSecretKey(cacheId, keyAddress string) ([]byte, error) {
 //signs encoded json body.
 //This is an alternative of signing with claims, if you want directly sign json string
 //Returns a signed Jwt
-func signJwtString(headers map[string]interface{}, bodyJson string, signingMethod jwt.SigningMethod, key interface{}) (string, error) {
+func signJwtString(headers map[string]interface{}, bodyJson string, signingMethod jwt.SigningMethod, signer Signer) (string, error) {
 	var err error
 	var headerJson []byte
 	if headerJson, err = json.Marshal(headers); err != nil {
@@ -152,7 +191,7 @@ func signJwtString(headers map[string]interface{}, bodyJson string, signingMetho
 	parts[1] = jwt.EncodeSegment([]byte(bodyJson))
 	jwtString := strings.Join(parts, ".")
 
-	sig, err := signingMethod.Sign(jwtString, key)
+	sig, err := signer.Sign(jwtString, signingMethod)
 	if err != nil {
 		return "", errors.WithMessage(err, "error in signJwtString()")
 	}
@@ -161,7 +200,17 @@ func signJwtString(headers map[string]interface{}, bodyJson string, signingMetho
 }
 
 func VerifyJwt(tokenString string, signingMethod jwt.SigningMethod, key interface{}) error {
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	if _, err := ParseJwt(tokenString, signingMethod, key); err != nil {
+		return errors.WithMessage(err, "error in VerifyJwt(). couldn't verify Jwt")
+	}
+
+	return nil
+}
+
+//ParseJwt verifies the token like VerifyJwt and returns its claims.
+func ParseJwt(tokenString string, signingMethod jwt.SigningMethod, key interface{}) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if token.Method != signingMethod {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
@@ -169,8 +218,8 @@ func VerifyJwt(tokenString string, signingMethod jwt.SigningMethod, key interfac
 		return key, nil
 	})
 	if err != nil {
-		return errors.WithMessage(err, "error in VerifyJwt(). couldn't verify Jwt")
+		return nil, errors.WithMessage(err, "error in ParseJwt()")
 	}
 
-	return nil
+	return claims, nil
 }
@@ -0,0 +1,287 @@
+package security
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Signer types which can be selected with OB_SIGNER env variable.
+const (
+	FileSignerType          = "file"
+	EncryptedFileSignerType = "encrypted_file"
+	RemoteSignerType        = "remote"
+)
+
+//Signer signs the signing input(base64url header + "." + base64url payload) of a JWS
+//and returns the base64url encoded signature.
+type Signer interface {
+	Sign(signingString string, signingMethod jwt.SigningMethod) (string, error)
+	Kid() string
+	//Algorithms returns the JWS algorithms the signer can produce. An empty list means it isn't known upfront.
+	Algorithms() ([]string, error)
+}
+
+var (
+	obSigner        Signer
+	internalSignKey string
+)
+
+//SetSigner overrides the signer which is built from the settings.
+//Passing nil resets it back to the env configuration.
+func SetSigner(signer Signer) {
+	obSigner = signer
+}
+
+//Configure sets the signer and the internal sign key from the settings, which are otherwise read from env variables.
+func Configure(cfg settings.Signing) error {
+	signer, err := NewSigner(cfg)
+	if err != nil {
+		return err
+	}
+
+	obSigner = signer
+	internalSignKey = cfg.InternalSignKey
+	return nil
+}
+
+//NewSigner returns the signer of the OB_SIGNER type.
+func NewSigner(cfg settings.Signing) (Signer, error) {
+	switch cfg.Signer {
+	case "", FileSignerType:
+		return NewFileSigner(cfg.SignKey, cfg.Kid), nil
+	case EncryptedFileSignerType:
+		return NewEncryptedFileSigner(cfg.SignKey, cfg.SignKeyPassphrase, cfg.Kid), nil
+	case RemoteSignerType:
+		var algorithms []string
+		if cfg.SignerAlgs != "" {
+			algorithms = strings.Split(cfg.SignerAlgs, ",")
+		}
+		return NewRemoteSigner(cfg.SignerUrl, cfg.Kid, algorithms), nil
+	default:
+		return nil, fmt.Errorf("error in NewSigner(). unsupported signer type: %v", cfg.Signer)
+	}
+}
+
+func getSigner() (Signer, error) {
+	if obSigner != nil {
+		return obSigner, nil
+	}
+
+	return NewSigner(settings.FromEnv().Signing)
+}
+
+//GetInternalSignKey returns the shared secret of the internal jwts.
+func GetInternalSignKey() ([]byte, error) {
+	keyAddress := internalSignKey
+	if keyAddress == "" {
+		keyAddress = settings.FromEnv().Signing.InternalSignKey
+	}
+
+	return GetSecretKey(api.InternalSignKey, keyAddress)
+}
+
+//keySigner signs with a key which is already loaded into memory.
+type keySigner struct {
+	key interface{}
+	kid string
+}
+
+func (k keySigner) Sign(signingString string, signingMethod jwt.SigningMethod) (string, error) {
+	return signingMethod.Sign(signingString, k.key)
+}
+
+func (k keySigner) Kid() string {
+	return k.kid
+}
+
+func (k keySigner) Algorithms() ([]string, error) {
+	return keyAlgorithms(k.key)
+}
+
+type fileSigner struct {
+	keyAddress string
+	kid        string
+}
+
+//NewFileSigner returns a Signer which uses a PEM encoded private key from the disk.
+func NewFileSigner(keyAddress, kid string) Signer {
+	return &fileSigner{keyAddress: keyAddress, kid: kid}
+}
+
+func (f fileSigner) Sign(signingString string, signingMethod jwt.SigningMethod) (string, error) {
+	key, err := GetPrivateKey(api.ObSignKey+"_"+f.keyAddress, f.keyAddress)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in fileSigner.Sign()")
+	}
+
+	return keySigner{key: key}.Sign(signingString, signingMethod)
+}
+
+func (f fileSigner) Algorithms() ([]string, error) {
+	key, err := GetPrivateKey(api.ObSignKey+"_"+f.keyAddress, f.keyAddress)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in fileSigner.Algorithms()")
+	}
+
+	return keyAlgorithms(key)
+}
+
+func (f fileSigner) Kid() string {
+	return f.kid
+}
+
+type encryptedFileSigner struct {
+	keyAddress string
+	passphrase string
+	kid        string
+}
+
+//NewEncryptedFileSigner returns a Signer which uses a passphrase protected PKCS#8 private key from the disk.
+func NewEncryptedFileSigner(keyAddress, passphrase, kid string) Signer {
+	return &encryptedFileSigner{keyAddress: keyAddress, passphrase: passphrase, kid: kid}
+}
+
+func (e encryptedFileSigner) Sign(signingString string, signingMethod jwt.SigningMethod) (string, error) {
+	key, err := GetEncryptedPrivateKey(api.ObSignKey+"_"+e.keyAddress, e.keyAddress, e.passphrase)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in encryptedFileSigner.Sign()")
+	}
+
+	return keySigner{key: key}.Sign(signingString, signingMethod)
+}
+
+func (e encryptedFileSigner) Kid() string {
+	return e.kid
+}
+
+func (e encryptedFileSigner) Algorithms() ([]string, error) {
+	key, err := GetEncryptedPrivateKey(api.ObSignKey+"_"+e.keyAddress, e.keyAddress, e.passphrase)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in encryptedFileSigner.Algorithms()")
+	}
+
+	return keyAlgorithms(key)
+}
+
+type remoteSigner struct {
+	endpoint   string
+	kid        string
+	algorithms []string
+	client     *http.Client
+}
+
+type remoteSignRequest struct {
+	Kid          string `json:"kid"`
+	Alg          string `json:"alg"`
+	SigningInput string `json:"signing_input"`
+}
+
+type remoteSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+//NewRemoteSigner returns a Signer which delegates signing to a signing service, so the private key never leaves it.
+//The service receives the base64url encoded signing input and returns the base64url encoded signature.
+//ECDSA signatures may be returned either DER encoded or in JWS(R||S) form.
+//algorithms are the ones the key in the service supports; leave it empty if it's unknown.
+func NewRemoteSigner(endpoint, kid string, algorithms []string) Signer {
+	return &remoteSigner{
+		endpoint:   endpoint,
+		kid:        kid,
+		algorithms: algorithms,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r remoteSigner) Sign(signingString string, signingMethod jwt.SigningMethod) (string, error) {
+	var errMessage = "error in remoteSigner.Sign()"
+	payload, err := json.Marshal(remoteSignRequest{
+		Kid:          r.kid,
+		Alg:          signingMethod.Alg(),
+		SigningInput: base64.RawURLEncoding.EncodeToString([]byte(signingString)),
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
+	req.Header.Set(api.ContentType, api.ApplicationJson)
+	req.Header.Set(api.Accept, api.ApplicationJson)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected result from the signing service. status: %v", resp.StatusCode)
+	}
+
+	var signResponse remoteSignResponse
+	if err = json.Unmarshal(body, &signResponse); err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
+	if signResponse.Signature == "" {
+		return "", fmt.Errorf("%v. signing service returned an empty signature", errMessage)
+	}
+
+	return toJoseSignature(signResponse.Signature, signingMethod)
+}
+
+func (r remoteSigner) Kid() string {
+	return r.kid
+}
+
+func (r remoteSigner) Algorithms() ([]string, error) {
+	return r.algorithms, nil
+}
+
+func GetEncryptedPrivateKey(cacheId, keyAddress, passphrase string) (interface{}, error) {
+	if value, found := cacheMem.Get(cacheId); found {
+		return value, nil
+	}
+
+	keyData, err := ioutil.ReadFile(keyAddress)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in GetEncryptedPrivateKey(). couldn't read private key file")
+	}
+
+	key, err := parseEncryptedPKCS8PrivateKeyFromPEM(keyData, []byte(passphrase))
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in GetEncryptedPrivateKey(). couldn't parse private key")
+	}
+	_ = cacheMem.Set(cacheId, key, cache.NoExpiration)
+
+	return key, nil
+}
+
+//CheckSigner loads the signer configured with OB_SIGNER and its key, so a missing or broken key is found before the first request.
+func CheckSigner() error {
+	signer, err := getSigner()
+	if err != nil {
+		return err
+	}
+
+	_, err = signer.Algorithms()
+	return err
+}
@@ -0,0 +1,203 @@
+package security
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//newSigningServiceStub is a local stand-in for the remote signing service. It signs with the test keys
+//and returns ECDSA signatures DER encoded, as HSM backed services usually do.
+func newSigningServiceStub(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signingInput, err := base64.RawURLEncoding.DecodeString(req.SigningInput)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var sig string
+		if req.Alg == jwt.SigningMethodES256.Alg() {
+			key, _ := GetPrivateKey("stub_ec_key", "./testdata/test_ec_key.pem")
+			digest := sha256.Sum256(signingInput)
+			derSignature, signErr := ecdsa.SignASN1(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+			sig, err = base64.RawURLEncoding.EncodeToString(derSignature), signErr
+		} else {
+			sig, err = NewFileSigner("./testdata/test_key.pem", req.Kid).Sign(string(signingInput), jwt.GetSigningMethod(req.Alg))
+		}
+		if err != nil {
+			t.Errorf("signing service stub couldn't sign. err: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(remoteSignResponse{Signature: sig})
+	}))
+}
+
+func Test_Signer(t *testing.T) {
+	initTest()
+	defer SetSigner(nil)
+
+	stub := newSigningServiceStub(t)
+	defer stub.Close()
+
+	certData, _ := ioutil.ReadFile("./testdata/test_cert.pem")
+	rsaPublicKey, err := jwt.ParseRSAPublicKeyFromPEM(certData)
+	if err != nil {
+		t.Fatalf("couldn't retrieve the pem file. err: %v", err)
+	}
+	ecPublicKeyData, _ := ioutil.ReadFile("./testdata/test_ec_pub.pem")
+	ecPublicKey, err := jwt.ParseECPublicKeyFromPEM(ecPublicKeyData)
+	if err != nil {
+		t.Fatalf("couldn't retrieve the pem file. err: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		signer        Signer
+		signingMethod jwt.SigningMethod
+		publicKey     interface{}
+		wantErr       bool
+	}{
+		{"file_signer", NewFileSigner("./testdata/test_key.pem", "kid_file"), jwt.SigningMethodPS256, rsaPublicKey, false},
+		{"file_signer_es256", NewFileSigner("./testdata/test_ec_key.pem", "kid_file_ec"), jwt.SigningMethodES256, ecPublicKey, false},
+		{"encrypted_file_signer", NewEncryptedFileSigner("./testdata/test_key_encrypted.pem", "test_passphrase", "kid_encrypted"), jwt.SigningMethodPS256, rsaPublicKey, false},
+		{"remote_signer", NewRemoteSigner(stub.URL, "kid_remote", nil), jwt.SigningMethodPS256, rsaPublicKey, false},
+		{"remote_signer_es256_der", NewRemoteSigner(stub.URL, "kid_remote_ec", nil), jwt.SigningMethodES256, ecPublicKey, false},
+		{"remote_signer_unreachable", NewRemoteSigner("http://127.0.0.1:1/sign", "kid_remote", nil), jwt.SigningMethodPS256, rsaPublicKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetSigner(tt.signer)
+
+			got, err := GenerateJwtWithJsonString(`{"name":"test"}`, tt.signingMethod)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateJwtWithJsonString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			token, err := jwt.Parse(got, func(token *jwt.Token) (interface{}, error) {
+				return tt.publicKey, nil
+			})
+			if err != nil {
+				t.Errorf("couldn't verify the signed jwt. err: %v", err)
+				return
+			}
+			if token.Header["kid"] != tt.signer.Kid() || token.Header["alg"] != tt.signingMethod.Alg() {
+				t.Errorf("header = %v, want kid %v alg %v", token.Header, tt.signer.Kid(), tt.signingMethod.Alg())
+			}
+		})
+	}
+}
+
+func Test_NegotiateSigningMethod(t *testing.T) {
+	initTest()
+	defer SetSigner(nil)
+
+	tests := []struct {
+		name            string
+		signer          Signer
+		supportedValues string
+		want            string
+		wantErr         bool
+	}{
+		{"not_advertised_defaults_to_ps256", NewFileSigner("./testdata/test_key.pem", "kid"), "", "PS256", false},
+		{"prefers_ps256", NewFileSigner("./testdata/test_key.pem", "kid"), "RS256,ES256,PS256", "PS256", false},
+		{"rsa_key_falls_back_to_rs256", NewFileSigner("./testdata/test_key.pem", "kid"), "RS256 ES256", "RS256", false},
+		{"ec_key_picks_es256", NewFileSigner("./testdata/test_ec_key.pem", "kid"), "PS256,ES256", "ES256", false},
+		{"ec_key_without_es256", NewFileSigner("./testdata/test_ec_key.pem", "kid"), "PS256,RS256", "", true},
+		{"remote_signer_unknown_algorithms", NewRemoteSigner("http://localhost", "kid", nil), "ES256", "ES256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetSigner(tt.signer)
+
+			got, err := NegotiateSigningMethod(tt.supportedValues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NegotiateSigningMethod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.Alg() != tt.want {
+				t.Errorf("NegotiateSigningMethod() got = %v, want %v", got.Alg(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseEncryptedPKCS8PrivateKeyFromPEM(t *testing.T) {
+	keyData, err := ioutil.ReadFile("./testdata/test_key_encrypted.pem")
+	if err != nil {
+		t.Fatalf("couldn't read the key file. err: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    bool
+	}{
+		{"correct_passphrase", "test_passphrase", false},
+		{"wrong_passphrase", "wrong_passphrase", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseEncryptedPKCS8PrivateKeyFromPEM(keyData, []byte(tt.passphrase)); (err != nil) != tt.wantErr {
+				t.Errorf("parseEncryptedPKCS8PrivateKeyFromPEM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_toJoseSignature(t *testing.T) {
+	//a DER signature with a 32 bytes R and a 26 bytes S is 64 bytes, as long as an R||S one
+	r := new(big.Int).SetBytes(append([]byte{0x7f}, bytes.Repeat([]byte{0x11}, 31)...))
+	s := new(big.Int).SetBytes(bytes.Repeat([]byte{0x22}, 26))
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil || len(der) != 64 {
+		t.Fatalf("couldn't create a 64 bytes DER signature. length: %v, err: %v", len(der), err)
+	}
+	want := make([]byte, 64)
+	r.FillBytes(want[:32])
+	s.FillBytes(want[32:])
+	raw := bytes.Repeat([]byte{0x33}, 64)
+
+	tests := []struct {
+		name      string
+		signature []byte
+		want      []byte
+		wantErr   bool
+	}{
+		{"der_as_long_as_raw", der, want, false},
+		{"raw", raw, raw, false},
+		{"neither", raw[:40], nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toJoseSignature(base64.RawURLEncoding.EncodeToString(tt.signature), jwt.SigningMethodES256)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toJoseSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != base64.RawURLEncoding.EncodeToString(tt.want) {
+				t.Errorf("toJoseSignature() = %v, want %v", got, base64.RawURLEncoding.EncodeToString(tt.want))
+			}
+		})
+	}
+}
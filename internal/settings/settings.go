@@ -0,0 +1,228 @@
+package settings
+
+import (
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Settings is the configuration of the services. Each field is read from its env variable, which overrides the
+//optional YAML file given with CONFIG_FILE, which overrides the default.
+type Settings struct {
+	Port             string           `env:"PORT" yaml:"port" default:"8080"`
+	PortCallback     string           `env:"PORT_CALLBACK" yaml:"portCallback" default:"8081"`
+	ShutdownTimeout  time.Duration    `env:"SHUTDOWN_TIMEOUT" yaml:"shutdownTimeout" default:"30s"`
+	Database         Database         `yaml:"database"`
+	Redis            Redis            `yaml:"redis"`
+	ConfigCache      ConfigCache      `yaml:"configCache"`
+	ConfigEncryption ConfigEncryption `yaml:"configEncryption"`
+	Discovery        Discovery        `yaml:"discovery"`
+	Registration     Registration     `yaml:"registration"`
+	Signing          Signing          `yaml:"signing"`
+	Transport        Transport        `yaml:"transport"`
+	Log              Log              `yaml:"log"`
+	Audit            Audit            `yaml:"audit"`
+	Tracing          Tracing          `yaml:"tracing"`
+	Health           Health           `yaml:"health"`
+	AccountData      AccountData      `yaml:"accountData"`
+	AccessLimit      AccessLimit      `yaml:"accessLimit"`
+	Export           Export           `yaml:"export"`
+	Migrate          Migrate          `yaml:"migrate"`
+}
+
+type Database struct {
+	DriverName    string `env:"DRIVER_NAME" yaml:"driverName"`
+	DatasourceUrl string `env:"DATASOURCE_URL" yaml:"datasourceUrl"`
+}
+
+type Redis struct {
+	Host string `env:"REDIS_HOST" yaml:"host" default:":6379"`
+}
+
+//ConfigCache is how long the aspsp configs are cached. Changes made by the admin API are applied to the other
+//instances through redis, and the ttl bounds how long a missed change can be served.
+type ConfigCache struct {
+	Ttl time.Duration `env:"CONFIG_CACHE_TTL" yaml:"ttl" default:"10m"`
+}
+
+//ConfigEncryption is the base64 encoded AES key of the secret aspsp configs in config_table, e.g. CLIENT_SECRET.
+//The secrets are stored in plaintext without it.
+type ConfigEncryption struct {
+	Key string `env:"CONFIG_ENCRYPTION_KEY" yaml:"key"`
+}
+
+//Discovery is how often the OpenID Connect discovery documents of the aspsps are checked. 0 disables it.
+type Discovery struct {
+	Interval time.Duration `env:"DISCOVERY_INTERVAL" yaml:"interval" default:"24h"`
+}
+
+//Registration is used for the dynamic client registration with the aspsps.
+type Registration struct {
+	//SoftwareStatement is the file of the software statement assertion(SSA) issued by the Open Banking directory.
+	SoftwareStatement string `env:"DCR_SOFTWARE_STATEMENT" yaml:"softwareStatement"`
+}
+
+type Signing struct {
+	InternalSignKey   string `env:"INTERNAL_SIGN_KEY" yaml:"internalSignKey"`
+	Signer            string `env:"OB_SIGNER" yaml:"signer" default:"file"`
+	SignKey           string `env:"OB_SIGN_KEY" yaml:"signKey"`
+	SignKeyPassphrase string `env:"OB_SIGN_KEY_PASSPHRASE" yaml:"signKeyPassphrase"`
+	SignerUrl         string `env:"OB_SIGNER_URL" yaml:"signerUrl"`
+	SignerAlgs        string `env:"OB_SIGNER_ALGS" yaml:"signerAlgs"`
+	Kid               string `env:"KID" yaml:"kid"`
+}
+
+//Transport is the default TLS-MA key pair, used for the aspsps which don't have their own TLS_* configs.
+type Transport struct {
+	CaCertPem string `env:"CLIENT_CA_CERT_PEM" yaml:"caCertPem"`
+	CertPem   string `env:"CLIENT_CERT_PEM" yaml:"certPem"`
+	KeyPem    string `env:"CLIENT_KEY_PEM" yaml:"keyPem"`
+}
+
+type Log struct {
+	Level string `env:"LOG_LEVEL" yaml:"level" default:"info"`
+}
+
+type Audit struct {
+	Body          string `env:"AUDIT_BODY" yaml:"body" default:"hash"`
+	RetentionDays int    `env:"AUDIT_RETENTION_DAYS" yaml:"retentionDays" default:"90"`
+}
+
+type Tracing struct {
+	Endpoint    string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" yaml:"endpoint"`
+	ServiceName string `env:"OTEL_SERVICE_NAME" yaml:"serviceName"`
+}
+
+type Health struct {
+	CertExpiryDays int `env:"HEALTH_CERT_EXPIRY_DAYS" yaml:"certExpiryDays" default:"30"`
+}
+
+//AccountData is how the stored account data is kept up to date. The data which was synced more than MaxAge ago is
+//synced again, and the transactions are requested again from SyncOverlap before the last booked transaction.
+type AccountData struct {
+	MaxAge      time.Duration `env:"ACCOUNT_DATA_MAX_AGE" yaml:"maxAge" default:"1h"`
+	SyncOverlap time.Duration `env:"ACCOUNT_DATA_SYNC_OVERLAP" yaml:"syncOverlap" default:"72h"`
+}
+
+//AccessLimit is how many times a day each resource of a consent can be requested without the PSU present.
+//The requests which have the x-fapi-customer-ip-address header are made with the PSU present and aren't limited. 0 disables it.
+type AccessLimit struct {
+	PsuNotPresentPerDay int `env:"ACCESS_LIMIT_PSU_NOT_PRESENT" yaml:"psuNotPresentPerDay" default:"4"`
+}
+
+//Export is the default columns of the CSV statements, which can be changed by the columns parameter of the request.
+type Export struct {
+	CsvColumns string `env:"EXPORT_CSV_COLUMNS" yaml:"csvColumns" default:"bookingDateTime,valueDateTime,status,amount,currency,reference,description,transactionId"`
+}
+
+type Migrate struct {
+	ScriptUrl   string `env:"MIGRATE_SCRIPT_URL" yaml:"scriptUrl"`
+	DatabaseUrl string `env:"MIGRATE_DATABASE_URL" yaml:"databaseUrl"`
+	Version     int    `env:"MIGRATE_VERSION" yaml:"version"`
+}
+
+//Load reads .env, the YAML file given with CONFIG_FILE and the env variables, then validates the settings of the services.
+//All missing and invalid settings are returned together in a *ValidationError.
+func Load() (*Settings, error) {
+	_ = godotenv.Load()
+
+	s := &Settings{}
+	verr := &ValidationError{}
+	apply(s, verr, func(field reflect.StructField) (string, bool) {
+		return field.Tag.Get("default"), field.Tag.Get("default") != ""
+	})
+
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error in Load(). couldn't read CONFIG_FILE")
+		}
+		if err := yaml.UnmarshalStrict(data, s); err != nil {
+			return nil, errors.WithMessagef(err, "error in Load(). couldn't parse %v", file)
+		}
+	}
+
+	apply(s, verr, func(field reflect.StructField) (string, bool) {
+		return os.LookupEnv(field.Tag.Get("env"))
+	})
+
+	s.validate(verr)
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+
+	return s, nil
+}
+
+//FromEnv returns the settings from the defaults and the env variables without validation.
+//It's the fallback of the packages whose settings aren't injected, e.g. in tests and tools.
+func FromEnv() *Settings {
+	s := &Settings{}
+	apply(s, &ValidationError{}, func(field reflect.StructField) (string, bool) {
+		if value, found := os.LookupEnv(field.Tag.Get("env")); found {
+			return value, true
+		}
+		return field.Tag.Get("default"), field.Tag.Get("default") != ""
+	})
+
+	return s
+}
+
+//apply sets the fields which have a value in lookup. Values which can't be parsed are added to verr.
+func apply(s *Settings, verr *ValidationError, lookup func(field reflect.StructField) (string, bool)) {
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field, value := v.Type().Field(i), v.Field(i)
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(value)
+				continue
+			}
+
+			raw, found := lookup(field)
+			if !found {
+				continue
+			}
+			if err := set(value, strings.TrimSpace(raw)); err != nil {
+				verr.add(field.Tag.Get("env"), err.Error())
+			}
+		}
+	}
+	walk(reflect.ValueOf(s).Elem())
+}
+
+func set(value reflect.Value, raw string) error {
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't a duration, e.g. 30s", raw)
+		}
+		value.SetInt(int64(d))
+	case value.Kind() == reflect.Int:
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't a number", raw)
+		}
+		value.SetInt(int64(n))
+	default:
+		value.SetString(raw)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package settings
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setEnv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		_ = os.Setenv(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range env {
+			_ = os.Unsetenv(k)
+		}
+	})
+}
+
+func Test_Load(t *testing.T) {
+	setEnv(t, map[string]string{
+		"CONFIG_FILE": "./testdata/settings.yaml",
+		"KID":         "env_kid",
+		"LOG_LEVEL":   "debug",
+	})
+
+	got, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"yaml_value", got.Port, "9090"},
+		{"default_value", got.PortCallback, "8081"},
+		{"yaml_duration", got.ShutdownTimeout, 10 * time.Second},
+		{"yaml_int", got.Audit.RetentionDays, 30},
+		{"env_overrides_yaml", got.Signing.Kid, "env_kid"},
+		{"env_value", got.Log.Level, "debug"},
+		{"nested_default", got.Redis.Host, ":6379"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("Load() = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Load_validation(t *testing.T) {
+	setEnv(t, map[string]string{
+		"CONFIG_FILE":          "./testdata/settings.yaml",
+		"PORT":                 "http",
+		"DRIVER_NAME":          "mysql",
+		"OB_SIGNER":            "remote",
+		"OB_SIGNER_URL":        "",
+		"CLIENT_CERT_PEM":      "./testdata/missing.pem",
+		"AUDIT_RETENTION_DAYS": "ninety",
+		"SHUTDOWN_TIMEOUT":     "soon",
+	})
+
+	_, err := Load()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+
+	want := []string{
+		`AUDIT_RETENTION_DAYS: "ninety" isn't a number`,
+		`SHUTDOWN_TIMEOUT: "soon" isn't a duration, e.g. 30s`,
+		`PORT: "http" isn't a valid port`,
+		`DRIVER_NAME: "mysql" must be one of postgres, sqlite3`,
+		`OB_SIGNER_URL: is required`,
+		`CLIENT_CERT_PEM: couldn't read ./testdata/missing.pem`,
+	}
+	for _, w := range want {
+		found := false
+		for _, e := range verr.Errors {
+			found = found || e == w
+		}
+		if !found {
+			t.Errorf("Load() errors = %v, want to contain %v", verr.Errors, w)
+		}
+	}
+}
+
+func Test_FromEnv(t *testing.T) {
+	setEnv(t, map[string]string{"DRIVER_NAME": "postgres"})
+
+	got := FromEnv()
+	if got.Database.DriverName != "postgres" || got.Audit.Body != "hash" || got.Signing.Signer != "file" {
+		t.Errorf("FromEnv() = %+v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package settings
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ValidationError lists every missing or invalid setting, so they can be fixed at once.
+type ValidationError struct {
+	Errors []string
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("invalid settings:\n  %v", strings.Join(v.Errors, "\n  "))
+}
+
+func (v *ValidationError) add(name, message string) {
+	v.Errors = append(v.Errors, name+": "+message)
+}
+
+func (s *Settings) validate(verr *ValidationError) {
+	validatePort(verr, "PORT", s.Port)
+	validatePort(verr, "PORT_CALLBACK", s.PortCallback)
+	if s.ShutdownTimeout <= 0 {
+		verr.add("SHUTDOWN_TIMEOUT", "must be greater than 0")
+	}
+
+	validateOneOf(verr, "DRIVER_NAME", s.Database.DriverName, "postgres", "sqlite3")
+	validateRequired(verr, "DATASOURCE_URL", s.Database.DatasourceUrl)
+	validateRequired(verr, "REDIS_HOST", s.Redis.Host)
+	if s.ConfigCache.Ttl < time.Minute {
+		verr.add("CONFIG_CACHE_TTL", "must be at least 1m")
+	}
+	if s.ConfigEncryption.Key != "" {
+		if key, err := base64.StdEncoding.DecodeString(s.ConfigEncryption.Key); err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			verr.add("CONFIG_ENCRYPTION_KEY", "must be a base64 encoded 16, 24 or 32 bytes key")
+		}
+	}
+	if s.Discovery.Interval < 0 {
+		verr.add("DISCOVERY_INTERVAL", "can't be negative")
+	}
+	if s.Registration.SoftwareStatement != "" {
+		validateFile(verr, "DCR_SOFTWARE_STATEMENT", s.Registration.SoftwareStatement)
+	}
+
+	validateFile(verr, "INTERNAL_SIGN_KEY", s.Signing.InternalSignKey)
+	validateRequired(verr, "KID", s.Signing.Kid)
+	validateOneOf(verr, "OB_SIGNER", s.Signing.Signer, "file", "encrypted_file", "remote")
+	switch s.Signing.Signer {
+	case "file":
+		validateFile(verr, "OB_SIGN_KEY", s.Signing.SignKey)
+	case "encrypted_file":
+		validateFile(verr, "OB_SIGN_KEY", s.Signing.SignKey)
+		validateRequired(verr, "OB_SIGN_KEY_PASSPHRASE", s.Signing.SignKeyPassphrase)
+	case "remote":
+		validateRequired(verr, "OB_SIGNER_URL", s.Signing.SignerUrl)
+		validateUrl(verr, "OB_SIGNER_URL", s.Signing.SignerUrl)
+	}
+
+	for _, caCert := range strings.Split(s.Transport.CaCertPem, ",") {
+		validateFile(verr, "CLIENT_CA_CERT_PEM", strings.TrimSpace(caCert))
+	}
+	validateFile(verr, "CLIENT_CERT_PEM", s.Transport.CertPem)
+	validateFile(verr, "CLIENT_KEY_PEM", s.Transport.KeyPem)
+
+	validateOneOf(verr, "LOG_LEVEL", s.Log.Level, "debug", "info", "warn", "error")
+	validateOneOf(verr, "AUDIT_BODY", s.Audit.Body, "hash", "full", "none")
+	if s.Audit.RetentionDays <= 0 {
+		verr.add("AUDIT_RETENTION_DAYS", "must be greater than 0")
+	}
+	validateUrl(verr, "OTEL_EXPORTER_OTLP_ENDPOINT", s.Tracing.Endpoint)
+	if s.Health.CertExpiryDays < 0 {
+		verr.add("HEALTH_CERT_EXPIRY_DAYS", "can't be negative")
+	}
+	if s.AccountData.MaxAge <= 0 {
+		verr.add("ACCOUNT_DATA_MAX_AGE", "must be greater than 0")
+	}
+	if s.AccountData.SyncOverlap < 0 {
+		verr.add("ACCOUNT_DATA_SYNC_OVERLAP", "can't be negative")
+	}
+	if s.AccessLimit.PsuNotPresentPerDay < 0 {
+		verr.add("ACCESS_LIMIT_PSU_NOT_PRESENT", "can't be negative")
+	}
+}
+
+func validateRequired(verr *ValidationError, name, value string) bool {
+	if value == "" {
+		verr.add(name, "is required")
+		return false
+	}
+
+	return true
+}
+
+func validatePort(verr *ValidationError, name, value string) {
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		verr.add(name, fmt.Sprintf("%q isn't a valid port", value))
+	}
+}
+
+func validateOneOf(verr *ValidationError, name, value string, allowed ...string) {
+	for _, v := range allowed {
+		if value == v {
+			return
+		}
+	}
+	verr.add(name, fmt.Sprintf("%q must be one of %v", value, strings.Join(allowed, ", ")))
+}
+
+func validateFile(verr *ValidationError, name, path string) {
+	if !validateRequired(verr, name, path) {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		verr.add(name, fmt.Sprintf("couldn't read %v", path))
+	}
+}
+
+//validateUrl accepts an empty value, as the urls are optional unless validateRequired is used.
+func validateUrl(verr *ValidationError, name, value string) {
+	if value == "" {
+		return
+	}
+	if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+		verr.add(name, fmt.Sprintf("%q isn't a valid url", value))
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+	"sync"
+)
+
+var oncePoolMetrics sync.Once
+
+//registerPoolMetrics exposes the connection pool stats of the first db which is opened.
+func registerPoolMetrics(db *sqlx.DB) {
+	oncePoolMetrics.Do(func() {
+		registerPoolGauges(db)
+	})
+}
+
+func registerPoolGauges(db *sqlx.DB) {
+	gauge := func(name, help string, value func(stats sql.DBStats) float64) {
+		metrics.NewGaugeFunc(name, help, nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: value(db.Stats())}}
+		})
+	}
+
+	gauge("db_max_open_connections", "Maximum number of open connections to the database.",
+		func(stats sql.DBStats) float64 { return float64(stats.MaxOpenConnections) })
+	gauge("db_open_connections", "Number of established connections, both in use and idle.",
+		func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) })
+	gauge("db_in_use_connections", "Number of connections currently in use.",
+		func(stats sql.DBStats) float64 { return float64(stats.InUse) })
+	gauge("db_idle_connections", "Number of idle connections.",
+		func(stats sql.DBStats) float64 { return float64(stats.Idle) })
+	gauge("db_wait_count_total", "Total number of connections waited for.",
+		func(stats sql.DBStats) float64 { return float64(stats.WaitCount) })
+	gauge("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+		func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() })
+}
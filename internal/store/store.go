@@ -2,10 +2,11 @@ package store
 
 import (
 	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
-	"log"
-	"os"
+	"github.com/pkg/errors"
 	"sync"
 	"time"
 )
@@ -13,31 +14,39 @@ import (
 var db *sqlx.DB
 var onceDb sync.Once
 
+//LoadDBConnection returns the connection from the DRIVER_NAME and DATASOURCE_URL env variables. It's used by the tests,
+//the services open theirs with NewDBConnection.
 func LoadDBConnection() *sqlx.DB {
 	onceDb.Do(func() {
-		db = initializeDb()
+		var err error
+		db, err = NewDBConnection(settings.FromEnv().Database)
+		if err != nil {
+			panic(err)
+		}
 	})
 
 	return db
 }
 
-func initializeDb() *sqlx.DB {
-	DriverName := os.Getenv("DRIVER_NAME")
-	DataSource := os.Getenv("DATASOURCE_URL")
-	db, dbError := sqlx.Open(DriverName, DataSource)
-	if dbError != nil {
-		panic(dbError)
+//NewDBConnection opens the database and checks the connection.
+func NewDBConnection(cfg settings.Database) (*sqlx.DB, error) {
+	db, err := sqlx.Open(cfg.DriverName, cfg.DatasourceUrl)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewDBConnection()")
 	}
 
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	if dbError = db.Ping(); dbError != nil {
-		panic(dbError)
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, errors.WithMessage(err, "error in NewDBConnection()")
 	}
 
-	log.Println("Database connection is successful.")
+	logger.Info("database connection is successful")
+	driverName = cfg.DriverName
+	registerPoolMetrics(db)
 
-	return db
+	return db, nil
 }
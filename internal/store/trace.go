@@ -0,0 +1,17 @@
+package store
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+)
+
+var driverName string
+
+//StartSpan starts the span of a database call. operation is the repository method, e.g. findByCid.
+func StartSpan(ctx context.Context, operation string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "db."+operation, tracing.KindClient)
+	span.SetAttribute("db.system", driverName)
+	span.SetAttribute("db.operation", operation)
+
+	return ctx, span
+}
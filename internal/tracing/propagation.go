@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/propagation"
+	"net/http"
+)
+
+//TraceParent is the W3C trace context header.
+const TraceParent = "traceparent"
+
+var propagator = propagation.TraceContext{}
+
+//Inject sets the traceparent header of the outbound request from the current span of ctx.
+func Inject(ctx context.Context, header http.Header) {
+	if currentProvider() == nil {
+		return
+	}
+
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+//Extract reads the traceparent header of the incoming request, so the spans of the request join the caller's trace.
+//A missing or malformed header starts a new trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if currentProvider() == nil {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//SpanKind is the kind of the OpenTelemetry span.
+type SpanKind = trace.SpanKind
+
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+//instrumentationName is the name of the tracer of the spans.
+const instrumentationName = "github.com/kaanaktas/openbanking-accountinformation"
+
+var (
+	providerMu sync.RWMutex
+	provider   *sdktrace.TracerProvider
+)
+
+func currentProvider() *sdktrace.TracerProvider {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	return provider
+}
+
+func setProvider(p *sdktrace.TracerProvider) {
+	providerMu.Lock()
+	provider = p
+	providerMu.Unlock()
+}
+
+//Init enables tracing if the collector endpoint is set, e.g. http://otel-collector:4318. The spans are exported in
+//batches by the OTLP/HTTP exporter of OpenTelemetry. Otherwise spans aren't recorded, which is the default for local runs.
+//The configured service name overrides serviceName.
+func Init(cfg settings.Tracing, serviceName string) {
+	if cfg.Endpoint == "" {
+		logger.Info("tracing is disabled. OTEL_EXPORTER_OTLP_ENDPOINT isn't set")
+		return
+	}
+	if cfg.ServiceName != "" {
+		serviceName = cfg.ServiceName
+	}
+
+	exporter, err := newExporter(cfg.Endpoint)
+	if err != nil {
+		logger.Error("tracing is disabled", logger.Err(err))
+		return
+	}
+
+	p := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	//the global provider is set too, so the libraries which are instrumented with OpenTelemetry join the traces
+	otel.SetTracerProvider(p)
+	setProvider(p)
+	logger.Info("tracing is enabled", logger.String("endpoint", cfg.Endpoint), logger.String("serviceName", serviceName))
+}
+
+//newExporter returns the exporter of the endpoint, which is sent the spans at its /v1/traces path.
+func newExporter(endpoint string) (*otlptrace.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT: %v", endpoint)
+	}
+
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in newExporter()")
+	}
+
+	return exporter, nil
+}
+
+//Shutdown disables tracing and exports the queued spans.
+func Shutdown(ctx context.Context) error {
+	providerMu.Lock()
+	p := provider
+	provider = nil
+	providerMu.Unlock()
+	if p == nil {
+		return nil
+	}
+
+	return p.Shutdown(ctx)
+}
+
+//Span is a single timed operation. All methods are safe on a nil span, which is returned when tracing is disabled.
+type Span struct {
+	span trace.Span
+}
+
+//Start starts a span as the child of the span in ctx, or of the remote parent extracted from the incoming request.
+//The returned context carries the new span.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	p := currentProvider()
+	if p == nil {
+		return ctx, nil
+	}
+
+	ctx, span := p.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &Span{span: span}
+}
+
+func (s *Span) SpanContext() trace.SpanContext {
+	if s == nil {
+		return trace.SpanContext{}
+	}
+	return s.span.SpanContext()
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s *Span) SetIntAttribute(key string, value int) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attribute.Int(key, value))
+}
+
+//RecordError marks the span as failed. A nil error is ignored, so it can be called with the result of the operation.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+//End finishes the span and queues it for the export. Only the first call has effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
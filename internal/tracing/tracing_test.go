@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Start_disabled(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := Start(Extract(context.Background(), incoming), "test", KindInternal)
+	if span != nil {
+		t.Fatalf("Start() = %v, want nil span when tracing is disabled", span)
+	}
+
+	span.SetAttribute("key", "value")
+	span.SetIntAttribute("key", 1)
+	span.RecordError(errors.New("error"))
+	span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if header.Get(TraceParent) != "" {
+		t.Errorf("Inject() = %v, want no traceparent", header.Get(TraceParent))
+	}
+}
+
+func Test_Start(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	setProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer func() { _ = Shutdown(context.Background()) }()
+
+	incoming := http.Header{}
+	incoming.Set(TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := Start(Extract(context.Background(), incoming), "GET /accounts", KindServer)
+	_, child := Start(ctx, "accounts.Accounts", KindInternal)
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + server.SpanContext().SpanID().String() + "-01"; outgoing.Get(TraceParent) != want {
+		t.Errorf("Inject() = %v, want %v", outgoing.Get(TraceParent), want)
+	}
+
+	child.RecordError(errors.New("aspsp is unavailable"))
+	child.End()
+	server.SetAttribute("request.id", "rid")
+	server.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %v, want 2", len(spans))
+	}
+	first, second := spans[0], spans[1]
+	if first.Name() != "accounts.Accounts" || first.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("child span = %v, parent = %v", first.Name(), first.Parent().SpanID())
+	}
+	if first.Status().Code != codes.Error || first.Status().Description != "aspsp is unavailable" {
+		t.Errorf("child span status = %v, want error", first.Status())
+	}
+	if second.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || second.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span = %v, want it to continue the caller's trace", second.SpanContext())
+	}
+}
+
+func Test_Init(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer collector.Close()
+
+	Init(settings.Tracing{Endpoint: collector.URL}, "test")
+	_, span := Start(context.Background(), "accounts.Accounts", KindInternal)
+	if span == nil {
+		t.Fatal("Start() = nil, want a span when tracing is enabled")
+	}
+	span.End()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case r := <-received:
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("collector got %v %v, want the OTLP/HTTP spans at /v1/traces", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("spans weren't exported")
+	}
+}
+
+func Test_newExporter_invalidEndpoint(t *testing.T) {
+	if _, err := newExporter("otel-collector:4318"); err == nil {
+		t.Error("newExporter() error = nil, want an error for the endpoint without a scheme")
+	}
+}
@@ -0,0 +1,25 @@
+package accesslimit
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DRIVER_NAME") == "" {
+		_ = os.Setenv("DRIVER_NAME", "sqlite3")
+
+	}
+	if os.Getenv("DATASOURCE_URL") == "" {
+		_ = os.Setenv("DATASOURCE_URL", "../../testdata/accountinformation.sqlite")
+	}
+
+	log.Print("ACCESS-LIMIT START")
+	_ = store.LoadDBConnection()
+
+	exitCode := m.Run()
+	log.Print("ACCESS-LIMIT END")
+	os.Exit(exitCode)
+}
@@ -0,0 +1,95 @@
+package accesslimit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"github.com/pkg/errors"
+)
+
+type Repository interface {
+	//increment adds 1 to the count of the resource of the consent on day, e.g. 2021-01-05, and returns the new count.
+	//The count starts from known, the last count known before, if it's higher.
+	increment(ctx context.Context, cid, resource, day string, known int64) (int64, error)
+	//decrement takes 1 from the count of the resource of the consent on day.
+	decrement(ctx context.Context, cid, resource, day string) error
+	//count returns the count of the resource of the consent on day, which is 0 if it hasn't been requested.
+	count(ctx context.Context, cid, resource, day string) (int64, error)
+	//deleteBefore deletes the counts of the days before day.
+	deleteBefore(ctx context.Context, day string) error
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r repository) increment(ctx context.Context, cid, resource, day string, known int64) (int64, error) {
+	ctx, span := store.StartSpan(ctx, "incrementAccessCount")
+	defer span.End()
+
+	tx := r.db.MustBeginTx(ctx, nil)
+	_, err := tx.ExecContext(ctx, `INSERT INTO access_count_table(cid, resource, access_date, access_count) VALUES ($1, $2, $3, $4 + 1)
+						ON CONFLICT (cid, resource, access_date) DO UPDATE SET access_count =
+						CASE WHEN access_count_table.access_count > $4 THEN access_count_table.access_count ELSE $4 END + 1`, cid, resource, day, known)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.WithMessage(err, "error in increment() while counting the request")
+	}
+
+	var count int64
+	err = tx.GetContext(ctx, &count, `SELECT access_count from access_count_table WHERE cid = $1 AND resource = $2 AND access_date = $3`, cid, resource, day)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.WithMessage(err, "error in increment() while reading the count")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.WithMessage(err, "error in increment() while committing")
+	}
+
+	return count, nil
+}
+
+func (r repository) decrement(ctx context.Context, cid, resource, day string) error {
+	ctx, span := store.StartSpan(ctx, "decrementAccessCount")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE access_count_table SET access_count = access_count - 1
+						WHERE cid = $1 AND resource = $2 AND access_date = $3 AND access_count > 0`, cid, resource, day)
+	if err != nil {
+		return errors.WithMessage(err, "error in decrement()")
+	}
+
+	return nil
+}
+
+func (r repository) count(ctx context.Context, cid, resource, day string) (int64, error) {
+	ctx, span := store.StartSpan(ctx, "findAccessCount")
+	defer span.End()
+
+	var count int64
+	err := r.db.GetContext(ctx, &count, `SELECT access_count from access_count_table WHERE cid = $1 AND resource = $2 AND access_date = $3`, cid, resource, day)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.WithMessage(err, "error in count()")
+	}
+
+	return count, nil
+}
+
+func (r repository) deleteBefore(ctx context.Context, day string) error {
+	ctx, span := store.StartSpan(ctx, "deleteAccessCounts")
+	defer span.End()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE from access_count_table WHERE access_date < $1`, day); err != nil {
+		return errors.WithMessage(err, "error in deleteBefore()")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package accesslimit
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"testing"
+)
+
+func Test_repository_increment(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+	ctx := context.Background()
+	cid := uuid.New().String()
+
+	if count, err := repo.count(ctx, cid, "accounts", "2021-01-05"); err != nil || count != 0 {
+		t.Errorf("count() = %v, error = %v, want 0 before any request", count, err)
+	}
+
+	for i, want := range []int64{1, 2} {
+		if count, err := repo.increment(ctx, cid, "accounts", "2021-01-05", 0); err != nil || count != want {
+			t.Errorf("increment() %v = %v, error = %v, want %v", i, count, err, want)
+		}
+	}
+	if count, err := repo.increment(ctx, cid, "accounts/1/balances", "2021-01-05", 0); err != nil || count != 1 {
+		t.Errorf("increment() of another resource = %v, error = %v, want 1", count, err)
+	}
+	if count, err := repo.increment(ctx, cid, "accounts", "2021-01-06", 0); err != nil || count != 1 {
+		t.Errorf("increment() of another day = %v, error = %v, want 1", count, err)
+	}
+
+	if err := repo.decrement(ctx, cid, "accounts", "2021-01-05"); err != nil {
+		t.Fatalf("decrement() error = %v", err)
+	}
+	if count, err := repo.count(ctx, cid, "accounts", "2021-01-05"); err != nil || count != 1 {
+		t.Errorf("count() = %v, error = %v, want 1 after the release", count, err)
+	}
+}
+
+func Test_repository_increment_known(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+	ctx := context.Background()
+	cid := uuid.New().String()
+
+	//the known count seeds the new row, and is only applied to an existing row if it's higher
+	if count, err := repo.increment(ctx, cid, "accounts", "2021-01-05", 3); err != nil || count != 4 {
+		t.Errorf("increment() = %v, error = %v, want 4 after the known 3", count, err)
+	}
+	if count, err := repo.increment(ctx, cid, "accounts", "2021-01-05", 2); err != nil || count != 5 {
+		t.Errorf("increment() = %v, error = %v, want 5 with a lower known count", count, err)
+	}
+	if count, err := repo.increment(ctx, cid, "accounts", "2021-01-05", 7); err != nil || count != 8 {
+		t.Errorf("increment() = %v, error = %v, want 8 with a higher known count", count, err)
+	}
+}
+
+func Test_repository_deleteBefore(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+	ctx := context.Background()
+	cid := uuid.New().String()
+
+	for _, day := range []string{"2021-01-04", "2021-01-05"} {
+		if _, err := repo.increment(ctx, cid, "accounts", day, 0); err != nil {
+			t.Fatalf("increment() error = %v", err)
+		}
+	}
+	if err := repo.deleteBefore(ctx, "2021-01-05"); err != nil {
+		t.Fatalf("deleteBefore() error = %v", err)
+	}
+
+	if count, err := repo.count(ctx, cid, "accounts", "2021-01-04"); err != nil || count != 0 {
+		t.Errorf("count() of the previous day = %v, error = %v, want it deleted", count, err)
+	}
+	if count, err := repo.count(ctx, cid, "accounts", "2021-01-05"); err != nil || count != 1 {
+		t.Errorf("count() of the day = %v, error = %v, want it kept", count, err)
+	}
+}
@@ -0,0 +1,211 @@
+package accesslimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//ErrLimitReached is sent when a resource has been requested the allowed times of the day without the PSU present.
+var ErrLimitReached = api.NewError(http.StatusTooManyRequests, api.UnexpectedError,
+	"the daily limit of the requests without the psu present has been reached. try again tomorrow or with the psu present")
+
+var requests = metrics.NewCounterVec("psu_not_present_requests_total",
+	"Requests made to the aspsps without the PSU present by result.", "result")
+
+type Service interface {
+	//Allow counts the request of the resource of the consent, e.g. accounts/{accountId}/balances, if it's made without
+	//the PSU present. It returns a *LimitError once the resource has been requested the allowed times of the day.
+	//The returned release func gives the request back, and is called if the aspsp didn't serve it, e.g. it failed,
+	//so the failed requests don't spend the limit.
+	Allow(ctx context.Context, cid, resource string) (release func(), err error)
+	//Spent returns true if the resource of the consent can't be requested again today without the PSU present.
+	//It doesn't count a request.
+	Spent(ctx context.Context, cid, resource string) bool
+}
+
+//LimitError is returned by Allow when the daily limit of the resource has been reached. It's sent as ErrLimitReached
+//with the Retry-After header of the next day.
+type LimitError struct {
+	Cid        string
+	Resource   string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (l *LimitError) Error() string {
+	return fmt.Sprintf("resource %v of consent %v has been requested %v times today without the psu present", l.Resource, l.Cid, l.Limit)
+}
+
+func (l *LimitError) ApiError() *api.Error {
+	return ErrLimitReached.Wrap(l)
+}
+
+//RetryAfterSeconds returns RetryAfter rounded up to seconds for the Retry-After header.
+func (l *LimitError) RetryAfterSeconds() string {
+	return strconv.Itoa(int((l.RetryAfter + time.Second - 1) / time.Second))
+}
+
+type service struct {
+	counter cache.Counter
+	repo    Repository
+	limit   int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	cleaned string
+
+	//known are the counts of the day which the counter returned to this instance. They seed the counts in the
+	//database when the counter fails, so the requests which were counted before aren't allowed again.
+	knownMu  sync.Mutex
+	knownDay string
+	known    map[string]int64
+}
+
+//NewService returns the limit shared by the instances through the counter. The counts are kept in the database
+//while the counter fails, e.g. redis is down. They start from the last count each instance knows, so the requests
+//counted by other instances before the failure may be allowed again, up to the limit on each instance.
+func NewService(counter cache.Counter, repo Repository, cfg settings.AccessLimit) Service {
+	return &service{
+		counter: counter,
+		repo:    repo,
+		limit:   cfg.PsuNotPresentPerDay,
+		now:     time.Now,
+		known:   map[string]int64{},
+	}
+}
+
+func (s *service) Allow(ctx context.Context, cid, resource string) (func(), error) {
+	if !s.limited(ctx) {
+		return noRelease, nil
+	}
+
+	now := s.now().UTC()
+	day := dayOf(now)
+	key := counterKey(cid, resource, day)
+	count, err := s.counter.Increment(key, 24*time.Hour)
+	release := func() { s.releaseCounter(day, key) }
+	if err != nil {
+		logger.Warn("couldn't count the request in the cache. the database is used", logger.String("cid", cid), logger.Err(err))
+		s.deleteExpired(ctx, day)
+		if count, err = s.repo.increment(ctx, cid, resource, day, s.knownCount(day, key)); err != nil {
+			return nil, err
+		}
+		release = func() { s.releaseRepository(cid, resource, day) }
+	} else {
+		s.setKnownCount(day, key, count)
+	}
+
+	if count > int64(s.limit) {
+		//the rejected request isn't made, so it doesn't hold a count which a released request would leave spent
+		release()
+		requests.Inc("rejected")
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return nil, &LimitError{Cid: cid, Resource: resource, Limit: s.limit, RetryAfter: tomorrow.Sub(now)}
+	}
+	requests.Inc("allowed")
+
+	return release, nil
+}
+
+func (s *service) Spent(ctx context.Context, cid, resource string) bool {
+	if !s.limited(ctx) {
+		return false
+	}
+
+	day := dayOf(s.now().UTC())
+	key := counterKey(cid, resource, day)
+	count, err := s.counter.Count(key)
+	if err != nil {
+		if count, err = s.repo.count(ctx, cid, resource, day); err != nil {
+			logger.Warn("couldn't read the request count", logger.String("cid", cid), logger.Err(err))
+			return false
+		}
+		if known := s.knownCount(day, key); known > count {
+			count = known
+		}
+	}
+
+	return count >= int64(s.limit)
+}
+
+//limited returns true if the requests of ctx are counted, i.e. the limit is enabled and the PSU isn't present.
+func (s *service) limited(ctx context.Context) bool {
+	return s.limit > 0 && client.InteractionFrom(ctx).CustomerIpAddress == ""
+}
+
+func noRelease() {}
+
+//dayOf returns the day the requests at now are counted on. The days are counted in UTC, so all instances agree on
+//when the limit is reset.
+func dayOf(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+func counterKey(cid, resource, day string) string {
+	return "access_limit:" + cid + ":" + resource + ":" + day
+}
+
+func (s *service) releaseCounter(day, key string) {
+	count, err := s.counter.Decrement(key)
+	if err != nil {
+		logger.Warn("couldn't release the request count in the cache", logger.String("key", key), logger.Err(err))
+		return
+	}
+	s.setKnownCount(day, key, count)
+}
+
+//knownCount returns the last count of key which the counter returned on day, or 0.
+func (s *service) knownCount(day, key string) int64 {
+	s.knownMu.Lock()
+	defer s.knownMu.Unlock()
+	if s.knownDay != day {
+		return 0
+	}
+
+	return s.known[key]
+}
+
+//setKnownCount keeps the count of key. The counts of the previous days are dropped, so they're kept for a day.
+func (s *service) setKnownCount(day, key string, count int64) {
+	s.knownMu.Lock()
+	defer s.knownMu.Unlock()
+	if s.knownDay != day {
+		s.knownDay = day
+		s.known = map[string]int64{}
+	}
+
+	s.known[key] = count
+}
+
+//releaseRepository isn't bound to the request, as it's called after the request has failed, e.g. timed out.
+func (s *service) releaseRepository(cid, resource, day string) {
+	if err := s.repo.decrement(context.Background(), cid, resource, day); err != nil {
+		logger.Warn("couldn't release the request count", logger.String("cid", cid), logger.Err(err))
+	}
+}
+
+//deleteExpired deletes the counts of the previous days once a day. The counts are only kept in the database
+//while the counter fails, so they're cleaned up there.
+func (s *service) deleteExpired(ctx context.Context, day string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cleaned == day {
+		return
+	}
+
+	if err := s.repo.deleteBefore(ctx, day); err != nil {
+		logger.Warn("couldn't delete the expired request counts", logger.Err(err))
+		return
+	}
+	s.cleaned = day
+}
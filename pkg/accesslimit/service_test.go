@@ -0,0 +1,219 @@
+package accesslimit
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/pkg/errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type counterStub struct {
+	counts map[string]int64
+	err    error
+}
+
+func (c *counterStub) Increment(k string, _ time.Duration) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.counts[k]++
+
+	return c.counts[k], nil
+}
+
+func (c *counterStub) Decrement(k string) (int64, error) {
+	c.counts[k]--
+
+	return c.counts[k], nil
+}
+
+func (c *counterStub) Count(k string) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.counts[k], nil
+}
+
+type repositoryStub struct {
+	counts  map[string]int64
+	deleted []string
+}
+
+func (r *repositoryStub) increment(_ context.Context, cid, resource, day string, known int64) (int64, error) {
+	if r.counts[cid+resource+day] < known {
+		r.counts[cid+resource+day] = known
+	}
+	r.counts[cid+resource+day]++
+
+	return r.counts[cid+resource+day], nil
+}
+
+func (r *repositoryStub) decrement(_ context.Context, cid, resource, day string) error {
+	r.counts[cid+resource+day]--
+	return nil
+}
+
+func (r *repositoryStub) count(_ context.Context, cid, resource, day string) (int64, error) {
+	return r.counts[cid+resource+day], nil
+}
+
+func (r *repositoryStub) deleteBefore(_ context.Context, day string) error {
+	r.deleted = append(r.deleted, day)
+	return nil
+}
+
+func newTestService(limit int, counterErr error) (*service, *repositoryStub) {
+	repo := &repositoryStub{counts: map[string]int64{}}
+	s := NewService(&counterStub{counts: map[string]int64{}, err: counterErr}, repo, settings.AccessLimit{PsuNotPresentPerDay: limit}).(*service)
+	s.now = func() time.Time { return time.Date(2021, 1, 5, 18, 0, 0, 0, time.UTC) }
+
+	return s, repo
+}
+
+func Test_service_Allow(t *testing.T) {
+	psuPresent := client.WithInteraction(context.Background(), &client.Interaction{CustomerIpAddress: "10.0.0.1"})
+	tests := []struct {
+		name        string
+		limit       int
+		counterErr  error
+		ctx         context.Context
+		requests    int
+		wantAllowed int
+	}{
+		{"psu_not_present_limited", 4, nil, context.Background(), 6, 4},
+		{"psu_present_not_limited", 4, nil, psuPresent, 6, 6},
+		{"disabled", 0, nil, context.Background(), 6, 6},
+		{"database_fallback", 4, errors.New("connection refused"), context.Background(), 6, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(tt.limit, tt.counterErr)
+
+			allowed := 0
+			for i := 0; i < tt.requests; i++ {
+				_, err := s.Allow(tt.ctx, "10", "accounts/1/balances")
+				if err == nil {
+					allowed++
+					continue
+				}
+				var limitErr *LimitError
+				if !errors.As(err, &limitErr) {
+					t.Fatalf("Allow() error = %v, want a *LimitError", err)
+				}
+				if limitErr.RetryAfterSeconds() != "21600" {
+					t.Errorf("Allow() RetryAfterSeconds = %v, want the seconds until midnight", limitErr.RetryAfterSeconds())
+				}
+				if e := api.AsError(err); e.Status != http.StatusTooManyRequests || !errors.Is(e, ErrLimitReached) {
+					t.Errorf("Allow() api error = %v, want ErrLimitReached", e)
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("Allow() allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func Test_service_Allow_perResource(t *testing.T) {
+	s, _ := newTestService(1, nil)
+
+	for _, resource := range []string{"accounts", "accounts/1/balances", "accounts/2/balances"} {
+		if _, err := s.Allow(context.Background(), "10", resource); err != nil {
+			t.Errorf("Allow(%v) error = %v, want each resource counted separately", resource, err)
+		}
+	}
+	if _, err := s.Allow(context.Background(), "11", "accounts"); err != nil {
+		t.Errorf("Allow() error = %v, want each consent counted separately", err)
+	}
+	if _, err := s.Allow(context.Background(), "10", "accounts"); err == nil {
+		t.Errorf("Allow() error = nil, want the limit reached")
+	}
+
+	s.now = func() time.Time { return time.Date(2021, 1, 6, 0, 0, 1, 0, time.UTC) }
+	if _, err := s.Allow(context.Background(), "10", "accounts"); err != nil {
+		t.Errorf("Allow() error = %v, want the limit reset the next day", err)
+	}
+}
+
+func Test_service_Allow_deletesExpiredOnce(t *testing.T) {
+	s, repo := newTestService(4, errors.New("connection refused"))
+
+	for i := 0; i < 3; i++ {
+		_, _ = s.Allow(context.Background(), "10", "accounts")
+	}
+	if len(repo.deleted) != 1 || repo.deleted[0] != "2021-01-05" {
+		t.Errorf("Allow() deleted = %v, want the days before 2021-01-05 deleted once", repo.deleted)
+	}
+}
+
+func Test_service_Allow_release(t *testing.T) {
+	for _, counterErr := range []error{nil, errors.New("connection refused")} {
+		s, _ := newTestService(2, counterErr)
+
+		//the failed requests are released, and the rejected ones don't hold a count
+		for i := 0; i < 3; i++ {
+			release, err := s.Allow(context.Background(), "10", "accounts")
+			if err != nil {
+				t.Fatalf("Allow() error = %v, want the released requests not counted", err)
+			}
+			release()
+		}
+		for i := 0; i < 3; i++ {
+			_, _ = s.Allow(context.Background(), "10", "accounts")
+		}
+		release, err := s.Allow(context.Background(), "10", "accounts")
+		if err == nil {
+			t.Fatalf("Allow() error = nil, want the limit reached")
+		}
+		if release != nil {
+			t.Errorf("Allow() release = %p, want nil with the error", release)
+		}
+	}
+}
+
+func Test_service_Spent(t *testing.T) {
+	psuPresent := client.WithInteraction(context.Background(), &client.Interaction{CustomerIpAddress: "10.0.0.1"})
+	for _, counterErr := range []error{nil, errors.New("connection refused")} {
+		s, _ := newTestService(2, counterErr)
+
+		for i := 0; i < 2; i++ {
+			if s.Spent(context.Background(), "10", "accounts") {
+				t.Errorf("Spent() = true after %v requests, want false", i)
+			}
+			_, _ = s.Allow(context.Background(), "10", "accounts")
+		}
+		if !s.Spent(context.Background(), "10", "accounts") {
+			t.Errorf("Spent() = false, want true after the allowed requests")
+		}
+		if s.Spent(psuPresent, "10", "accounts") {
+			t.Errorf("Spent() = true, want false with the psu present")
+		}
+	}
+}
+
+func Test_service_Allow_databaseFallbackSeeded(t *testing.T) {
+	s, _ := newTestService(4, nil)
+	for i := 0; i < 3; i++ {
+		_, _ = s.Allow(context.Background(), "10", "accounts")
+	}
+
+	//redis fails after 3 requests, so the database carries on from them
+	s.counter.(*counterStub).err = errors.New("connection refused")
+	if s.Spent(context.Background(), "10", "accounts") {
+		t.Errorf("Spent() = true, want false after 3 requests")
+	}
+	if _, err := s.Allow(context.Background(), "10", "accounts"); err != nil {
+		t.Fatalf("Allow() error = %v, want the 4th request allowed", err)
+	}
+	if _, err := s.Allow(context.Background(), "10", "accounts"); err == nil {
+		t.Errorf("Allow() error = nil, want the limit reached with the counts before the failure")
+	}
+	if !s.Spent(context.Background(), "10", "accounts") {
+		t.Errorf("Spent() = false, want true")
+	}
+}
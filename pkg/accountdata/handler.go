@@ -0,0 +1,86 @@
+package accountdata
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+//RegisterHandler registers the stored account data, which is only served by v2 since v1 has no typed responses.
+func RegisterHandler(e *echo.Echo, service Service) {
+	g := e.Group("/v2/:aspspId/stored")
+	g.GET("/accounts/cid/:cid", storedAccounts(service))
+	g.GET("/accounts/:accountId/transactions/cid/:cid", storedTransactions(service))
+	g.POST("/sync/cid/:cid", syncConsent(service))
+}
+
+func storedAccounts(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		aspspId, cid, err := params(c)
+		if err != nil {
+			return err
+		}
+
+		res, err := service.Accounts(c.Request().Context(), cid, aspspId)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+func storedTransactions(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		aspspId, cid, err := params(c)
+		if err != nil {
+			return err
+		}
+
+		from, err := api.BookingDateTimeParam(c, "fromBookingDateTime")
+		if err != nil {
+			return err
+		}
+		to, err := api.BookingDateTimeParam(c, "toBookingDateTime")
+		if err != nil {
+			return err
+		}
+
+		res, err := service.Transactions(c.Request().Context(), cid, aspspId, c.Param("accountId"), from, to)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+func syncConsent(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		aspspId, cid, err := params(c)
+		if err != nil {
+			return err
+		}
+
+		res, err := service.Sync(c.Request().Context(), cid, aspspId)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+func params(c echo.Context) (aspspId, cid string, err error) {
+	aspspId = c.Param("aspspId")
+	if aspspId == "" {
+		return "", "", api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty")
+	}
+
+	cid = c.Param("cid")
+	if cid == "" {
+		return "", "", api.NewFieldError(api.FieldMissing, "cid", "cid can't be empty")
+	}
+
+	return aspspId, cid, nil
+}
@@ -0,0 +1,25 @@
+package accountdata
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DRIVER_NAME") == "" {
+		_ = os.Setenv("DRIVER_NAME", "sqlite3")
+
+	}
+	if os.Getenv("DATASOURCE_URL") == "" {
+		_ = os.Setenv("DATASOURCE_URL", "../../testdata/accountinformation.sqlite")
+	}
+
+	log.Print("ACCOUNT-DATA START")
+	_ = store.LoadDBConnection()
+
+	exitCode := m.Run()
+	log.Print("ACCOUNT-DATA END")
+	os.Exit(exitCode)
+}
@@ -0,0 +1,245 @@
+package accountdata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type Repository interface {
+	findAccounts(ctx context.Context, aspspId, cid string) ([]account, error)
+	findAccount(ctx context.Context, aspspId, cid, accountId string) (*account, error)
+	findBalances(ctx context.Context, aspspId, cid string) ([]balance, error)
+	findTransactions(ctx context.Context, aspspId, cid, accountId string, from, to time.Time) ([]transaction, error)
+	//eachTransaction calls fn with the transactions of the account booked between from and to, oldest first. The rows
+	//are read one by one, so a long period isn't kept in memory.
+	eachTransaction(ctx context.Context, aspspId, cid, accountId string, from, to time.Time, fn func(transaction) error) error
+	//findSyncBoundaries returns the booking time of the last booked and of the oldest pending transaction of the account.
+	//They're nil if the account has no such transaction.
+	findSyncBoundaries(ctx context.Context, cid, accountId string) (lastBooked, oldestPending *time.Time, err error)
+	//saveAccount replaces the balances and the pending transactions of the account, and inserts or updates the others.
+	saveAccount(ctx context.Context, account *account, balances []*balance, transactions []*transaction) error
+	//deleteAccountsExcept deletes the accounts of the consent which aren't in accountIds, with their balances and transactions.
+	deleteAccountsExcept(ctx context.Context, cid string, accountIds []string) error
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r repository) findAccounts(ctx context.Context, aspspId, cid string) ([]account, error) {
+	ctx, span := store.StartSpan(ctx, "findAccounts")
+	defer span.End()
+
+	var accounts []account
+	err := r.db.SelectContext(ctx, &accounts, `SELECT * from account_table WHERE aspsp_id = $1 AND cid = $2 ORDER BY id`, aspspId, cid)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in findAccounts()")
+	}
+
+	return accounts, nil
+}
+
+func (r repository) findAccount(ctx context.Context, aspspId, cid, accountId string) (*account, error) {
+	ctx, span := store.StartSpan(ctx, "findAccount")
+	defer span.End()
+
+	var account account
+	err := r.db.GetContext(ctx, &account, `SELECT * from account_table WHERE aspsp_id = $1 AND cid = $2 AND account_id = $3`, aspspId, cid, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (r repository) findBalances(ctx context.Context, aspspId, cid string) ([]balance, error) {
+	ctx, span := store.StartSpan(ctx, "findBalances")
+	defer span.End()
+
+	var balances []balance
+	err := r.db.SelectContext(ctx, &balances, `SELECT * from balance_table WHERE aspsp_id = $1 AND cid = $2 ORDER BY id`, aspspId, cid)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in findBalances()")
+	}
+
+	return balances, nil
+}
+
+func (r repository) findTransactions(ctx context.Context, aspspId, cid, accountId string, from, to time.Time) ([]transaction, error) {
+	ctx, span := store.StartSpan(ctx, "findTransactions")
+	defer span.End()
+
+	query, args := transactionsQuery(aspspId, cid, accountId, from, to)
+	var transactions []transaction
+	if err := r.db.SelectContext(ctx, &transactions, query+" ORDER BY booking_date_time DESC, id DESC", args...); err != nil {
+		return nil, errors.WithMessage(err, "error in findTransactions()")
+	}
+
+	return transactions, nil
+}
+
+func (r repository) eachTransaction(ctx context.Context, aspspId, cid, accountId string, from, to time.Time, fn func(transaction) error) error {
+	ctx, span := store.StartSpan(ctx, "eachTransaction")
+	defer span.End()
+
+	query, args := transactionsQuery(aspspId, cid, accountId, from, to)
+	rows, err := r.db.QueryxContext(ctx, query+" ORDER BY booking_date_time, id", args...)
+	if err != nil {
+		return errors.WithMessage(err, "error in eachTransaction()")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t transaction
+		if err := rows.StructScan(&t); err != nil {
+			return errors.WithMessage(err, "error in eachTransaction()")
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+
+	return errors.WithMessage(rows.Err(), "error in eachTransaction()")
+}
+
+//transactionsQuery returns the query of the transactions of the account booked between from and to. The zero times aren't applied.
+func transactionsQuery(aspspId, cid, accountId string, from, to time.Time) (string, []interface{}) {
+	query := `SELECT * from transaction_table WHERE aspsp_id = $1 AND cid = $2 AND account_id = $3`
+	args := []interface{}{aspspId, cid, accountId}
+	if !from.IsZero() {
+		args = append(args, from.UTC())
+		query += fmt.Sprintf(" AND booking_date_time >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to.UTC())
+		query += fmt.Sprintf(" AND booking_date_time <= $%d", len(args))
+	}
+
+	return query, args
+}
+
+func (r repository) findSyncBoundaries(ctx context.Context, cid, accountId string) (*time.Time, *time.Time, error) {
+	ctx, span := store.StartSpan(ctx, "findSyncBoundaries")
+	defer span.End()
+
+	lastBooked, err := r.findBookingDateTime(ctx, `SELECT booking_date_time from transaction_table
+		WHERE cid = $1 AND account_id = $2 AND status <> 'Pending' ORDER BY booking_date_time DESC LIMIT 1`, cid, accountId)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error in findSyncBoundaries() while finding the last booked transaction")
+	}
+	oldestPending, err := r.findBookingDateTime(ctx, `SELECT booking_date_time from transaction_table
+		WHERE cid = $1 AND account_id = $2 AND status = 'Pending' ORDER BY booking_date_time LIMIT 1`, cid, accountId)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error in findSyncBoundaries() while finding the oldest pending transaction")
+	}
+
+	return lastBooked, oldestPending, nil
+}
+
+func (r repository) findBookingDateTime(ctx context.Context, query string, args ...interface{}) (*time.Time, error) {
+	var bookingDateTime time.Time
+	err := r.db.GetContext(ctx, &bookingDateTime, query, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &bookingDateTime, nil
+}
+
+func (r repository) saveAccount(ctx context.Context, account *account, balances []*balance, transactions []*transaction) error {
+	ctx, span := store.StartSpan(ctx, "saveAccount")
+	defer span.End()
+
+	tx := r.db.MustBeginTx(ctx, nil)
+
+	_, err := tx.NamedExecContext(ctx, `INSERT INTO account_table(aspsp_id, cid, account_id, currency, account_type, account_sub_type, nickname, identifiers, servicer, sync_date_time)
+						VALUES (:aspsp_id, :cid, :account_id, :currency, :account_type, :account_sub_type, :nickname, :identifiers, :servicer, :sync_date_time)
+						ON CONFLICT (cid, account_id) DO UPDATE SET aspsp_id = excluded.aspsp_id, currency = excluded.currency, account_type = excluded.account_type,
+						account_sub_type = excluded.account_sub_type, nickname = excluded.nickname, identifiers = excluded.identifiers, servicer = excluded.servicer,
+						sync_date_time = excluded.sync_date_time`, account)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithMessage(err, "error in saveAccount() while saving the account")
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE from balance_table WHERE cid = $1 AND account_id = $2`, account.Cid, account.AccountId)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithMessage(err, "error in saveAccount() while deleting the balances")
+	}
+	for _, balance := range balances {
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO balance_table(aspsp_id, cid, account_id, balance_type, amount, currency, date_time, credit_line_included)
+						VALUES (:aspsp_id, :cid, :account_id, :balance_type, :amount, :currency, :date_time, :credit_line_included)`, balance)
+		if err != nil {
+			tx.Rollback()
+			return errors.WithMessage(err, "error in saveAccount() while inserting a balance")
+		}
+	}
+
+	//the pending transactions are fetched again by each sync. the ones which have been booked or dropped since aren't kept
+	_, err = tx.ExecContext(ctx, `DELETE from transaction_table WHERE cid = $1 AND account_id = $2 AND status = 'Pending'`, account.Cid, account.AccountId)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithMessage(err, "error in saveAccount() while deleting the pending transactions")
+	}
+	for _, transaction := range transactions {
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO transaction_table(aspsp_id, cid, account_id, transaction_key, transaction_id, reference, status, booking_date_time,
+						value_date_time, amount, currency, description, merchant_name, balance_amount, balance_currency, update_date_time)
+						VALUES (:aspsp_id, :cid, :account_id, :transaction_key, :transaction_id, :reference, :status, :booking_date_time, :value_date_time, :amount,
+						:currency, :description, :merchant_name, :balance_amount, :balance_currency, :update_date_time)
+						ON CONFLICT (cid, account_id, transaction_key) DO UPDATE SET transaction_id = excluded.transaction_id, reference = excluded.reference,
+						status = excluded.status, booking_date_time = excluded.booking_date_time, value_date_time = excluded.value_date_time, amount = excluded.amount,
+						currency = excluded.currency, description = excluded.description, merchant_name = excluded.merchant_name,
+						balance_amount = excluded.balance_amount, balance_currency = excluded.balance_currency, update_date_time = excluded.update_date_time`, transaction)
+		if err != nil {
+			tx.Rollback()
+			return errors.WithMessage(err, "error in saveAccount() while saving a transaction")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.WithMessage(err, "error in saveAccount() while committing")
+	}
+
+	return nil
+}
+
+func (r repository) deleteAccountsExcept(ctx context.Context, cid string, accountIds []string) error {
+	ctx, span := store.StartSpan(ctx, "deleteAccountsExcept")
+	defer span.End()
+
+	tx := r.db.MustBeginTx(ctx, nil)
+	for _, table := range []string{"transaction_table", "balance_table", "account_table"} {
+		query, args := "DELETE from "+table+" WHERE cid = ?", []interface{}{cid}
+		if len(accountIds) > 0 {
+			var err error
+			query, args, err = sqlx.In(query+" AND account_id NOT IN (?)", cid, accountIds)
+			if err != nil {
+				tx.Rollback()
+				return errors.WithMessage(err, "error in deleteAccountsExcept()")
+			}
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+			tx.Rollback()
+			return errors.WithMessagef(err, "error in deleteAccountsExcept() while deleting from %v", table)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithMessage(err, "error in deleteAccountsExcept() while committing")
+	}
+
+	return nil
+}
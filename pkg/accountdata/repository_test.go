@@ -0,0 +1,123 @@
+package accountdata
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+func newTestAccount(cid, accountId string, syncDateTime time.Time) *account {
+	return &account{AspspId: "danske", Cid: cid, AccountId: accountId, Currency: "GBP", Identifiers: "[]", SyncDateTime: syncDateTime}
+}
+
+func newTestTransaction(cid, accountId, key, status string, bookingDateTime time.Time, amount int64) *transaction {
+	return &transaction{AspspId: "danske", Cid: cid, AccountId: accountId, TransactionKey: key, TransactionId: key, Status: status,
+		BookingDateTime: bookingDateTime, Amount: normalise.Decimal{Unscaled: amount, Scale: 2}, Currency: "GBP", UpdateDateTime: bookingDateTime}
+}
+
+func Test_repository_saveAccount(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+	ctx := context.Background()
+	cid := uuid.New().String()
+	day := time.Date(2021, 1, 8, 10, 0, 0, 0, time.UTC)
+
+	balances := []*balance{{AspspId: "danske", Cid: cid, AccountId: "a1", BalanceType: "InterimBooked",
+		Amount: normalise.Decimal{Unscaled: 10000, Scale: 2}, Currency: "GBP", DateTime: day}}
+	err := repo.saveAccount(ctx, newTestAccount(cid, "a1", day), balances, []*transaction{
+		newTestTransaction(cid, "a1", "t1", "Booked", day.AddDate(0, 0, -2), -500),
+		newTestTransaction(cid, "a1", "p1", "Pending", day.AddDate(0, 0, -1), -750),
+	})
+	if err != nil {
+		t.Fatalf("saveAccount() error = %v", err)
+	}
+
+	lastBooked, oldestPending, err := repo.findSyncBoundaries(ctx, cid, "a1")
+	if err != nil || lastBooked == nil || !lastBooked.Equal(day.AddDate(0, 0, -2)) || oldestPending == nil || !oldestPending.Equal(day.AddDate(0, 0, -1)) {
+		t.Errorf("findSyncBoundaries() = %v, %v, error = %v", lastBooked, oldestPending, err)
+	}
+
+	//the second sync updates t1 on its key, books t2 and drops the pending transaction
+	err = repo.saveAccount(ctx, newTestAccount(cid, "a1", day.Add(time.Hour)), balances, []*transaction{
+		newTestTransaction(cid, "a1", "t1", "Booked", day.AddDate(0, 0, -2), -510),
+		newTestTransaction(cid, "a1", "t2", "Booked", day.AddDate(0, 0, -1), -750),
+	})
+	if err != nil {
+		t.Fatalf("saveAccount() error = %v", err)
+	}
+
+	account, err := repo.findAccount(ctx, "danske", cid, "a1")
+	if err != nil || !account.SyncDateTime.Equal(day.Add(time.Hour)) {
+		t.Errorf("findAccount() = %+v, error = %v", account, err)
+	}
+	if got, err := repo.findBalances(ctx, "danske", cid); err != nil || len(got) != 1 {
+		t.Errorf("findBalances() = %v, error = %v, want the balances replaced", got, err)
+	}
+
+	transactions, err := repo.findTransactions(ctx, "danske", cid, "a1", time.Time{}, time.Time{})
+	if err != nil || len(transactions) != 2 || transactions[0].TransactionKey != "t2" || transactions[1].Amount.String() != "-5.10" {
+		t.Errorf("findTransactions() = %+v, error = %v, want t2 and the updated t1, latest first", transactions, err)
+	}
+	transactions, err = repo.findTransactions(ctx, "danske", cid, "a1", day.AddDate(0, 0, -1), day)
+	if err != nil || len(transactions) != 1 || transactions[0].TransactionKey != "t2" {
+		t.Errorf("findTransactions() between = %+v, error = %v, want t2", transactions, err)
+	}
+
+	var keys []string
+	err = repo.eachTransaction(ctx, "danske", cid, "a1", time.Time{}, day, func(t transaction) error {
+		keys = append(keys, t.TransactionKey)
+		return nil
+	})
+	if err != nil || len(keys) != 2 || keys[0] != "t1" || keys[1] != "t2" {
+		t.Errorf("eachTransaction() = %v, error = %v, want t1 and t2, oldest first", keys, err)
+	}
+	errStop := errors.New("stop")
+	if err := repo.eachTransaction(ctx, "danske", cid, "a1", time.Time{}, time.Time{}, func(t transaction) error { return errStop }); err != errStop {
+		t.Errorf("eachTransaction() error = %v, want the error of fn", err)
+	}
+
+	lastBooked, oldestPending, err = repo.findSyncBoundaries(ctx, cid, "a1")
+	if err != nil || lastBooked == nil || !lastBooked.Equal(day.AddDate(0, 0, -1)) || oldestPending != nil {
+		t.Errorf("findSyncBoundaries() = %v, %v, error = %v, want t2 and no pending", lastBooked, oldestPending, err)
+	}
+}
+
+func Test_repository_deleteAccountsExcept(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+	ctx := context.Background()
+	cid := uuid.New().String()
+	day := time.Date(2021, 1, 8, 10, 0, 0, 0, time.UTC)
+
+	for _, accountId := range []string{"a1", "a2", "a3"} {
+		balances := []*balance{{AspspId: "danske", Cid: cid, AccountId: accountId, BalanceType: "InterimBooked", Currency: "GBP", DateTime: day}}
+		transactions := []*transaction{newTestTransaction(cid, accountId, "t1", "Booked", day, -500)}
+		if err := repo.saveAccount(ctx, newTestAccount(cid, accountId, day), balances, transactions); err != nil {
+			t.Fatalf("saveAccount() error = %v", err)
+		}
+	}
+
+	if err := repo.deleteAccountsExcept(ctx, cid, []string{"a1", "a3"}); err != nil {
+		t.Fatalf("deleteAccountsExcept() error = %v", err)
+	}
+	accounts, err := repo.findAccounts(ctx, "danske", cid)
+	if err != nil || len(accounts) != 2 || accounts[0].AccountId != "a1" || accounts[1].AccountId != "a3" {
+		t.Errorf("findAccounts() = %+v, error = %v, want a1 and a3", accounts, err)
+	}
+	if balances, err := repo.findBalances(ctx, "danske", cid); err != nil || len(balances) != 2 {
+		t.Errorf("findBalances() = %+v, error = %v, want the balances of a1 and a3", balances, err)
+	}
+	if transactions, err := repo.findTransactions(ctx, "danske", cid, "a2", time.Time{}, time.Time{}); err != nil || len(transactions) != 0 {
+		t.Errorf("findTransactions() of a2 = %+v, error = %v, want none", transactions, err)
+	}
+
+	if err := repo.deleteAccountsExcept(ctx, cid, nil); err != nil {
+		t.Fatalf("deleteAccountsExcept() error = %v", err)
+	}
+	if _, err := repo.findAccount(ctx, "danske", cid, "a1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("findAccount() error = %v, want %v after all accounts are deleted", err, sql.ErrNoRows)
+	}
+}
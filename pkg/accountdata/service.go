@@ -0,0 +1,353 @@
+package accountdata
+
+import (
+	"context"
+	"database/sql"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accesslimit"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accounts"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/aggregate"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//ErrAccountNotFound is returned for the accounts which aren't in the consent after it's synced.
+var ErrAccountNotFound = api.NewError(http.StatusNotFound, api.ResourceNotFound, "couldn't find the account")
+
+type Service interface {
+	//Sync fetches the accounts of the consent with their balances and transactions, and stores them.
+	Sync(ctx context.Context, cid, aspspId string) (*SyncResult, error)
+	//Accounts returns the stored accounts of the consent with their balances. The consent is synced first if it has
+	//never been, and in the background if its data is stale.
+	Accounts(ctx context.Context, cid, aspspId string) (*Accounts, error)
+	//Transactions returns the stored transactions of the account booked between from and to, latest first.
+	//The zero times aren't applied.
+	Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*Transactions, error)
+	//Statement returns the statement of the stored account, so the service is the accounts.StatementReader of the
+	//stored data. The transactions are read from the database while the statement is written.
+	Statement(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*accounts.Statement, error)
+	//Stop waits for the background syncs until ctx is done.
+	Stop(ctx context.Context) error
+}
+
+type service struct {
+	repo           Repository
+	accountService accounts.Service
+	accessLimit    accesslimit.Service
+	maxAge         time.Duration
+	overlap        time.Duration
+	now            func() time.Time
+
+	mu         sync.Mutex
+	background map[string]bool
+	wg         sync.WaitGroup
+}
+
+func NewService(repo Repository, accountService accounts.Service, accessLimit accesslimit.Service, cfg settings.AccountData) Service {
+	return &service{
+		repo:           repo,
+		accountService: accountService,
+		accessLimit:    accessLimit,
+		maxAge:         cfg.MaxAge,
+		overlap:        cfg.SyncOverlap,
+		now:            time.Now,
+		background:     map[string]bool{},
+	}
+}
+
+func (s *service) Sync(ctx context.Context, cid, aspspId string) (*SyncResult, error) {
+	ctx, span := tracing.Start(ctx, "accountdata.Sync", tracing.KindInternal)
+	defer span.End()
+
+	source := normalise.Source{AspspId: aspspId, Cid: cid}
+	payload, err := s.accountService.Accounts(ctx, cid, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	normalised, err := normalise.Accounts(source, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	accountIds := make([]string, 0, len(normalised))
+	for _, account := range normalised {
+		accountIds = append(accountIds, account.AccountId)
+	}
+	if err := s.repo.deleteAccountsExcept(ctx, cid, accountIds); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{SyncDateTime: s.now().UTC(), Accounts: len(normalised), Failures: []aggregate.Failure{}}
+	for _, account := range normalised {
+		count, err := s.syncAccount(ctx, account, result.SyncDateTime)
+		if err != nil {
+			e := api.AsError(err)
+			logger.Warn("couldn't sync the account", logger.String("aspspId", aspspId), logger.String("cid", cid),
+				logger.String("accountId", account.AccountId), logger.Err(err))
+			result.Failures = append(result.Failures, aggregate.Failure{AspspId: aspspId, Cid: cid, AccountId: account.AccountId, Error: e.Response("")})
+			continue
+		}
+		result.Transactions += count
+	}
+
+	return result, nil
+}
+
+//syncAccount stores the balances and the transactions of the account. It returns the number of the fetched transactions.
+func (s *service) syncAccount(ctx context.Context, account normalise.Account, syncDateTime time.Time) (int, error) {
+	payload, err := s.accountService.Balances(ctx, account.Cid, account.AspspId, account.AccountId)
+	if err != nil {
+		return 0, err
+	}
+	balances, err := normalise.Balances(account.Source, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	from, err := s.transactionsFrom(ctx, account.Cid, account.AccountId)
+	if err != nil {
+		return 0, err
+	}
+	var transactions []normalise.Transaction
+	occurrences := normalise.Occurrences{}
+	err = s.accountService.TransactionPages(ctx, account.Cid, account.AspspId, account.AccountId, from, time.Time{}, func(payload string) error {
+		page, err := normalise.Transactions(account.Source, payload)
+		for i := range page {
+			occurrences.Number(&page[i])
+		}
+		transactions = append(transactions, page...)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	row, err := newAccount(account, syncDateTime)
+	if err != nil {
+		return 0, errors.WithMessage(err, "error in syncAccount()")
+	}
+	balanceRows := make([]*balance, 0, len(balances))
+	for _, b := range balances {
+		b.AccountId = account.AccountId
+		balanceRows = append(balanceRows, newBalance(b))
+	}
+	transactionRows := make([]*transaction, 0, len(transactions))
+	for _, t := range transactions {
+		t.AccountId = account.AccountId
+		transactionRows = append(transactionRows, newTransaction(t, syncDateTime))
+	}
+
+	if err := s.repo.saveAccount(ctx, row, balanceRows, transactionRows); err != nil {
+		return 0, err
+	}
+
+	return len(transactions), nil
+}
+
+//transactionsFrom returns the booking time the transactions are requested from. It's the overlap before the last
+//booked transaction, so the late bookings are caught, or the oldest pending transaction if it's before that,
+//so the pending transactions are fetched again to be replaced. The zero time is returned for the first sync.
+func (s *service) transactionsFrom(ctx context.Context, cid, accountId string) (time.Time, error) {
+	lastBooked, oldestPending, err := s.repo.findSyncBoundaries(ctx, cid, accountId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastBooked == nil {
+		return time.Time{}, nil
+	}
+
+	from := lastBooked.Add(-s.overlap)
+	if oldestPending != nil && oldestPending.Before(from) {
+		from = *oldestPending
+	}
+
+	return from.UTC(), nil
+}
+
+func (s *service) Accounts(ctx context.Context, cid, aspspId string) (*Accounts, error) {
+	ctx, span := tracing.Start(ctx, "accountdata.Accounts", tracing.KindInternal)
+	defer span.End()
+
+	rows, err := s.repo.findAccounts(ctx, aspspId, cid)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		if _, err := s.Sync(ctx, cid, aspspId); err != nil {
+			return nil, err
+		}
+		if rows, err = s.repo.findAccounts(ctx, aspspId, cid); err != nil {
+			return nil, err
+		}
+	}
+	balances, err := s.repo.findBalances(ctx, aspspId, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Accounts{Accounts: make([]aggregate.Account, 0, len(rows))}
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		account, err := row.normalised()
+		if err != nil {
+			return nil, errors.WithMessage(err, "error in Accounts()")
+		}
+		index[row.AccountId] = i
+		res.Accounts = append(res.Accounts, aggregate.Account{Account: account, Balances: []normalise.Balance{}})
+		if i == 0 || row.SyncDateTime.Before(res.SyncDateTime) {
+			res.SyncDateTime = row.SyncDateTime.UTC()
+		}
+	}
+	for _, b := range balances {
+		if i, found := index[b.AccountId]; found {
+			res.Accounts[i].Balances = append(res.Accounts[i].Balances, b.normalised())
+		}
+	}
+	res.Freshness = s.freshness(cid, aspspId, res.SyncDateTime)
+
+	return res, nil
+}
+
+func (s *service) Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*Transactions, error) {
+	ctx, span := tracing.Start(ctx, "accountdata.Transactions", tracing.KindInternal)
+	defer span.End()
+
+	account, err := s.findAccount(ctx, cid, aspspId, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.findTransactions(ctx, aspspId, cid, accountId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Transactions{Transactions: make([]normalise.Transaction, 0, len(rows))}
+	for _, row := range rows {
+		res.Transactions = append(res.Transactions, row.normalised())
+	}
+	res.Freshness = s.freshness(cid, aspspId, account.SyncDateTime.UTC())
+
+	return res, nil
+}
+
+func (s *service) Statement(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*accounts.Statement, error) {
+	ctx, span := tracing.Start(ctx, "accountdata.Statement", tracing.KindInternal)
+	defer span.End()
+
+	row, err := s.findAccount(ctx, cid, aspspId, accountId)
+	if err != nil {
+		return nil, err
+	}
+	account, err := row.normalised()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in Statement()")
+	}
+	balanceRows, err := s.repo.findBalances(ctx, aspspId, cid)
+	if err != nil {
+		return nil, err
+	}
+	var balances []normalise.Balance
+	for _, b := range balanceRows {
+		if b.AccountId == accountId {
+			balances = append(balances, b.normalised())
+		}
+	}
+	freshness := s.freshness(cid, aspspId, row.SyncDateTime.UTC())
+
+	st, err := accounts.NewStatement(account, balances, from, to, s.now(), func(fn func(normalise.Transaction) error) error {
+		return s.repo.eachTransaction(ctx, aspspId, cid, accountId, from, to, func(t transaction) error {
+			return fn(t.normalised())
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	st.Freshness = &freshness
+
+	return st, nil
+}
+
+//findAccount returns the stored account. The consent is synced first if the account isn't stored.
+func (s *service) findAccount(ctx context.Context, cid, aspspId, accountId string) (*account, error) {
+	account, err := s.repo.findAccount(ctx, aspspId, cid, accountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.Sync(ctx, cid, aspspId); err != nil {
+			return nil, err
+		}
+		account, err = s.repo.findAccount(ctx, aspspId, cid, accountId)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound.Wrap(err)
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "error in findAccount()")
+	}
+
+	return account, nil
+}
+
+//freshness returns the freshness of the data synced at syncDateTime, and syncs the consent in the background if it's stale.
+func (s *service) freshness(cid, aspspId string, syncDateTime time.Time) Freshness {
+	freshness := Freshness{SyncDateTime: syncDateTime, Stale: s.now().Sub(syncDateTime) > s.maxAge}
+	if freshness.Stale {
+		freshness.Syncing = s.syncInBackground(cid, aspspId)
+	}
+
+	return freshness
+}
+
+//syncInBackground starts a sync of the consent unless there's one running already. The sync isn't bound to the request,
+//so it doesn't forward the PSU's details to the aspsp and is counted by the access limit. It isn't started once the
+//accounts of the consent have been requested the allowed times of the day, as each sync requests them first, so the
+//stored data is served until the limit is reset. It returns true if the consent is being synced.
+func (s *service) syncInBackground(cid, aspspId string) bool {
+	if s.accessLimit.Spent(context.Background(), cid, "accounts") {
+		logger.Debug("background sync is skipped, as the access limit has been reached", logger.String("aspspId", aspspId), logger.String("cid", cid))
+		return false
+	}
+
+	key := aspspId + "/" + cid
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.background[key] {
+		return true
+	}
+	s.background[key] = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.background, key)
+			s.mu.Unlock()
+		}()
+
+		if _, err := s.Sync(context.Background(), cid, aspspId); err != nil {
+			logger.Warn("background sync failed", logger.String("aspspId", aspspId), logger.String("cid", cid), logger.Err(err))
+		}
+	}()
+
+	return true
+}
+
+func (s *service) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,419 @@
+package accountdata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+//repositoryStub keeps the rows like the tables do, keyed by cid/accountId and cid/accountId/transactionKey.
+type repositoryStub struct {
+	mu           sync.Mutex
+	accounts     map[string]account
+	balances     map[string][]balance
+	transactions map[string]transaction
+}
+
+func newRepositoryStub() *repositoryStub {
+	return &repositoryStub{accounts: map[string]account{}, balances: map[string][]balance{}, transactions: map[string]transaction{}}
+}
+
+func (r *repositoryStub) findAccounts(ctx context.Context, aspspId, cid string) ([]account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []account
+	for _, a := range r.accounts {
+		if a.AspspId == aspspId && a.Cid == cid {
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].AccountId < accounts[j].AccountId })
+	return accounts, nil
+}
+
+func (r *repositoryStub) findAccount(ctx context.Context, aspspId, cid, accountId string) (*account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, found := r.accounts[cid+"/"+accountId]
+	if !found || a.AspspId != aspspId {
+		return nil, sql.ErrNoRows
+	}
+	return &a, nil
+}
+
+func (r *repositoryStub) findBalances(ctx context.Context, aspspId, cid string) ([]balance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var balances []balance
+	for _, b := range r.balances {
+		balances = append(balances, b...)
+	}
+	return balances, nil
+}
+
+func (r *repositoryStub) findTransactions(ctx context.Context, aspspId, cid, accountId string, from, to time.Time) ([]transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var transactions []transaction
+	for _, t := range r.transactions {
+		if t.Cid == cid && t.AccountId == accountId && (from.IsZero() || !t.BookingDateTime.Before(from)) && (to.IsZero() || !t.BookingDateTime.After(to)) {
+			transactions = append(transactions, t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool { return transactions[i].BookingDateTime.After(transactions[j].BookingDateTime) })
+	return transactions, nil
+}
+
+func (r *repositoryStub) eachTransaction(ctx context.Context, aspspId, cid, accountId string, from, to time.Time, fn func(transaction) error) error {
+	transactions, _ := r.findTransactions(ctx, aspspId, cid, accountId, from, to)
+	for i := len(transactions) - 1; i >= 0; i-- {
+		if err := fn(transactions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repositoryStub) findSyncBoundaries(ctx context.Context, cid, accountId string) (*time.Time, *time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lastBooked, oldestPending *time.Time
+	for _, t := range r.transactions {
+		bookingDateTime := t.BookingDateTime
+		switch {
+		case t.Cid != cid || t.AccountId != accountId:
+		case t.Status == "Pending" && (oldestPending == nil || bookingDateTime.Before(*oldestPending)):
+			oldestPending = &bookingDateTime
+		case t.Status != "Pending" && (lastBooked == nil || bookingDateTime.After(*lastBooked)):
+			lastBooked = &bookingDateTime
+		}
+	}
+	return lastBooked, oldestPending, nil
+}
+
+func (r *repositoryStub) saveAccount(ctx context.Context, account *account, balances []*balance, transactions []*transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := account.Cid + "/" + account.AccountId
+	r.accounts[key] = *account
+	r.balances[key] = nil
+	for _, b := range balances {
+		r.balances[key] = append(r.balances[key], *b)
+	}
+	for k, t := range r.transactions {
+		if t.Cid == account.Cid && t.AccountId == account.AccountId && t.Status == "Pending" {
+			delete(r.transactions, k)
+		}
+	}
+	for _, t := range transactions {
+		r.transactions[key+"/"+t.TransactionKey] = *t
+	}
+	return nil
+}
+
+func (r *repositoryStub) deleteAccountsExcept(ctx context.Context, cid string, accountIds []string) error {
+	return nil
+}
+
+//accountStub returns the payloads of an aspsp and records the transaction requests. The transactions of an account
+//are returned as one page, or as the pages of pages.
+type accountStub struct {
+	mu           sync.Mutex
+	accounts     string
+	balances     map[string]string
+	transactions map[string]string
+	pages        map[string][]string
+	errs         map[string]error
+	from         []time.Time
+}
+
+func (a *accountStub) Account(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (a *accountStub) Accounts(ctx context.Context, cid, aspspId string) (string, error) {
+	return a.accounts, nil
+}
+
+func (a *accountStub) Balances(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	return a.balances[accountId], a.errs[accountId]
+}
+
+func (a *accountStub) Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.from = append(a.from, from)
+	return a.transactions[accountId], nil
+}
+
+func (a *accountStub) TransactionPages(ctx context.Context, cid, aspspId, accountId string, from, to time.Time, fn func(payload string) error) error {
+	pages, found := a.pages[accountId]
+	if !found {
+		payload, err := a.Transactions(ctx, cid, aspspId, accountId, from, to)
+		if err != nil {
+			return err
+		}
+		pages = []string{payload}
+	}
+
+	for _, page := range pages {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	accountsPayload = `{"Data":{"Account":[{"AccountId":"a1","Currency":"GBP","Account":[{"SchemeName":"UK.OBIE.SortCodeAccountNumber","Identification":"80200110203345"}]}]}}`
+	balancesPayload = `{"Data":{"Balance":[{"AccountId":"a1","Amount":{"Amount":"100.00","Currency":"GBP"},"CreditDebitIndicator":"Credit","Type":"InterimBooked","DateTime":"2021-01-10T00:00:00Z"}]}}`
+)
+
+type accessLimitStub struct {
+	spent bool
+}
+
+func (a accessLimitStub) Allow(ctx context.Context, cid, resource string) (func(), error) {
+	return func() {}, nil
+}
+
+func (a accessLimitStub) Spent(ctx context.Context, cid, resource string) bool {
+	return a.spent
+}
+
+func newTestService(repo Repository, accountService *accountStub, now time.Time) *service {
+	s := NewService(repo, accountService, accessLimitStub{}, settings.AccountData{MaxAge: time.Hour, SyncOverlap: 48 * time.Hour}).(*service)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func Test_service_incrementalSync(t *testing.T) {
+	now := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := newRepositoryStub()
+	accountService := &accountStub{
+		accounts: accountsPayload,
+		balances: map[string]string{"a1": balancesPayload},
+		transactions: map[string]string{"a1": `{"Data":{"Transaction":[
+			{"TransactionId":"t1","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-08T10:00:00Z","Amount":{"Amount":"5.00","Currency":"GBP"}},
+			{"CreditDebitIndicator":"Debit","Status":"Pending","BookingDateTime":"2021-01-09T10:00:00Z","Amount":{"Amount":"7.50","Currency":"GBP"},"TransactionInformation":"Coffee"}]}}`},
+	}
+	s := newTestService(repo, accountService, now)
+
+	got, err := s.Accounts(context.Background(), "1", "danske")
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+	if len(got.Accounts) != 1 || len(got.Accounts[0].Balances) != 1 || got.Accounts[0].Identifiers[0].SortCode != "802001" {
+		t.Fatalf("Accounts() got = %+v", got)
+	}
+	if got.Stale || got.Syncing || !got.SyncDateTime.Equal(now) {
+		t.Errorf("Accounts() freshness = %+v, want fresh at %v", got.Freshness, now)
+	}
+
+	//the pending transaction is booked with an id, and t1 is fetched again in the overlap
+	accountService.transactions["a1"] = `{"Data":{"Transaction":[
+		{"TransactionId":"t1","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-08T10:00:00Z","Amount":{"Amount":"5.00","Currency":"GBP"}},
+		{"TransactionId":"t2","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-09T11:00:00Z","Amount":{"Amount":"7.50","Currency":"GBP"},"TransactionInformation":"Coffee"}]}}`
+	res, err := s.Sync(context.Background(), "1", "danske")
+	if err != nil || len(res.Failures) != 0 || res.Transactions != 2 {
+		t.Fatalf("Sync() got = %+v, error = %v", res, err)
+	}
+
+	wantFrom := []time.Time{{}, time.Date(2021, 1, 6, 10, 0, 0, 0, time.UTC)}
+	if len(accountService.from) != 2 || !accountService.from[0].Equal(wantFrom[0]) || !accountService.from[1].Equal(wantFrom[1]) {
+		t.Errorf("Transactions() from = %v, want %v", accountService.from, wantFrom)
+	}
+
+	transactions, err := s.Transactions(context.Background(), "1", "danske", "a1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Transactions() error = %v", err)
+	}
+	if len(transactions.Transactions) != 2 || transactions.Transactions[0].TransactionId != "t2" || transactions.Transactions[1].TransactionId != "t1" {
+		t.Errorf("Transactions() got = %+v, want t2 and t1", transactions.Transactions)
+	}
+	if transactions.Transactions[0].Amount.Value.String() != "-7.50" {
+		t.Errorf("Transactions() amount = %v, want -7.50", transactions.Transactions[0].Amount.Value)
+	}
+}
+
+func Test_service_Sync_pages(t *testing.T) {
+	repo := newRepositoryStub()
+	accountService := &accountStub{
+		accounts: accountsPayload,
+		balances: map[string]string{"a1": balancesPayload},
+		pages: map[string][]string{"a1": {
+			`{"Data":{"Transaction":[{"TransactionId":"t1","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-08T10:00:00Z","Amount":{"Amount":"5.00","Currency":"GBP"}}]},"Links":{"Next":"https://aspsp/accounts/a1/transactions?page=2"}}`,
+			`{"Data":{"Transaction":[{"TransactionId":"t2","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-07T10:00:00Z","Amount":{"Amount":"2.00","Currency":"GBP"}},
+				{"TransactionId":"t3","CreditDebitIndicator":"Credit","Status":"Booked","BookingDateTime":"2021-01-06T10:00:00Z","Amount":{"Amount":"9.00","Currency":"GBP"}}]}}`,
+		}},
+	}
+	s := newTestService(repo, accountService, time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	res, err := s.Sync(context.Background(), "1", "danske")
+	if err != nil || len(res.Failures) != 0 || res.Transactions != 3 {
+		t.Fatalf("Sync() got = %+v, error = %v", res, err)
+	}
+	for _, key := range []string{"t1", "t2", "t3"} {
+		if _, found := repo.transactions["1/a1/"+key]; !found {
+			t.Errorf("Sync() didn't store %v of the pages", key)
+		}
+	}
+}
+
+func Test_service_Sync_identicalTransactions(t *testing.T) {
+	purchase := `{"CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-08T10:00:00Z","Amount":{"Amount":"2.50","Currency":"GBP"},"TransactionInformation":"Coffee"}`
+	repo := newRepositoryStub()
+	accountService := &accountStub{
+		accounts:     accountsPayload,
+		balances:     map[string]string{"a1": balancesPayload},
+		transactions: map[string]string{"a1": `{"Data":{"Transaction":[` + purchase + `,` + purchase + `]}}`},
+	}
+	s := newTestService(repo, accountService, time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	//the purchases are fetched again in the overlap of the second sync, and neither is merged nor duplicated
+	for i := 0; i < 2; i++ {
+		if res, err := s.Sync(context.Background(), "1", "danske"); err != nil || len(res.Failures) != 0 {
+			t.Fatalf("Sync() got = %+v, error = %v", res, err)
+		}
+	}
+	if len(repo.transactions) != 2 {
+		t.Errorf("Sync() stored %v transactions, want 2", len(repo.transactions))
+	}
+	for _, row := range repo.transactions {
+		if got := row.normalised().Key(); got != row.TransactionKey {
+			t.Errorf("normalised() key = %v, want %v", got, row.TransactionKey)
+		}
+	}
+}
+
+func Test_service_transactionsFrom_pendingBeforeOverlap(t *testing.T) {
+	repo := newRepositoryStub()
+	repo.transactions["1/a1/t1"] = transaction{Cid: "1", AccountId: "a1", Status: "Booked", BookingDateTime: time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)}
+	repo.transactions["1/a1/p1"] = transaction{Cid: "1", AccountId: "a1", Status: "Pending", BookingDateTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newTestService(repo, &accountStub{}, time.Now())
+
+	from, err := s.transactionsFrom(context.Background(), "1", "a1")
+	if err != nil || !from.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("transactionsFrom() got = %v, %v, want the oldest pending", from, err)
+	}
+}
+
+func Test_service_staleDataSyncedInBackground(t *testing.T) {
+	synced := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := newRepositoryStub()
+	repo.accounts["1/a1"] = account{AspspId: "danske", Cid: "1", AccountId: "a1", Currency: "GBP", Identifiers: "[]", SyncDateTime: synced}
+	accountService := &accountStub{accounts: accountsPayload, balances: map[string]string{"a1": balancesPayload}, transactions: map[string]string{"a1": `{"Data":{}}`}}
+	s := newTestService(repo, accountService, synced.Add(2*time.Hour))
+
+	got, err := s.Accounts(context.Background(), "1", "danske")
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+	if !got.Stale || !got.Syncing || !got.SyncDateTime.Equal(synced) || len(got.Accounts[0].Balances) != 0 {
+		t.Errorf("Accounts() got = %+v, want the stale data while syncing", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if a, _ := repo.findAccount(context.Background(), "danske", "1", "a1"); !a.SyncDateTime.Equal(synced.Add(2 * time.Hour)) {
+		t.Errorf("background sync didn't update the account. syncDateTime = %v", a.SyncDateTime)
+	}
+}
+
+func Test_service_staleDataNotSyncedOverLimit(t *testing.T) {
+	synced := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	repo := newRepositoryStub()
+	repo.accounts["1/a1"] = account{AspspId: "danske", Cid: "1", AccountId: "a1", Currency: "GBP", Identifiers: "[]", SyncDateTime: synced}
+	accountService := &accountStub{accounts: accountsPayload, balances: map[string]string{"a1": balancesPayload}, transactions: map[string]string{"a1": `{"Data":{}}`}}
+	s := newTestService(repo, accountService, synced.Add(2*time.Hour))
+	s.accessLimit = accessLimitStub{spent: true}
+
+	got, err := s.Accounts(context.Background(), "1", "danske")
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+	if !got.Stale || got.Syncing || len(got.Accounts) != 1 {
+		t.Errorf("Accounts() got = %+v, want the stale data without a sync", got)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if a, _ := repo.findAccount(context.Background(), "danske", "1", "a1"); !a.SyncDateTime.Equal(synced) {
+		t.Errorf("the account was synced over the access limit. syncDateTime = %v", a.SyncDateTime)
+	}
+}
+
+func Test_service_Sync_partialFailure(t *testing.T) {
+	repo := newRepositoryStub()
+	accountService := &accountStub{
+		accounts:     `{"Data":{"Account":[{"AccountId":"a1","Currency":"GBP"},{"AccountId":"a2","Currency":"GBP"}]}}`,
+		balances:     map[string]string{"a1": balancesPayload},
+		transactions: map[string]string{"a1": `{"Data":{"Transaction":[]}}`},
+		errs:         map[string]error{"a2": api.NewAspspError(http.StatusInternalServerError, "")},
+	}
+	s := newTestService(repo, accountService, time.Now())
+
+	res, err := s.Sync(context.Background(), "1", "danske")
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if res.Accounts != 2 || len(res.Failures) != 1 || res.Failures[0].AccountId != "a2" || res.Failures[0].Error.Code != "502 BadGateway" {
+		t.Errorf("Sync() got = %+v", res)
+	}
+
+	_, err = s.Transactions(context.Background(), "1", "danske", "unknown", time.Time{}, time.Time{})
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Transactions() error = %v, want %v", err, ErrAccountNotFound)
+	}
+}
+
+func Test_service_Statement(t *testing.T) {
+	now := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	accountService := &accountStub{
+		accounts: accountsPayload,
+		balances: map[string]string{"a1": balancesPayload},
+		transactions: map[string]string{"a1": `{"Data":{"Transaction":[
+			{"TransactionId":"t2","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"2021-01-09T10:00:00Z","Amount":{"Amount":"7.50","Currency":"GBP"}},
+			{"TransactionId":"t1","CreditDebitIndicator":"Credit","Status":"Booked","BookingDateTime":"2021-01-08T10:00:00Z","Amount":{"Amount":"20.00","Currency":"GBP"}}]}}`},
+	}
+	s := newTestService(newRepositoryStub(), accountService, now)
+
+	st, err := s.Statement(context.Background(), "1", "danske", "a1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Statement() error = %v", err)
+	}
+	if st.OpeningBooked == nil || st.OpeningBooked.Value.String() != "87.50" || st.ClosingBooked.Value.String() != "100.00" {
+		t.Errorf("Statement() booked balances = %+v, %+v, want 87.50 and 100.00", st.OpeningBooked, st.ClosingBooked)
+	}
+	if !st.From.Equal(time.Date(2021, 1, 8, 10, 0, 0, 0, time.UTC)) || !st.To.Equal(now) {
+		t.Errorf("Statement() period = %v - %v", st.From, st.To)
+	}
+	if st.Freshness == nil || !st.Freshness.SyncDateTime.Equal(now) || st.Freshness.Stale {
+		t.Errorf("Statement() freshness = %+v, want fresh at %v", st.Freshness, now)
+	}
+
+	var ids []string
+	_ = st.Transactions(func(t normalise.Transaction) error {
+		ids = append(ids, t.TransactionId)
+		return nil
+	})
+	if len(ids) != 2 || ids[0] != "t1" || ids[1] != "t2" {
+		t.Errorf("Statement() transactions = %v, want the oldest first", ids)
+	}
+
+	if _, err := s.Statement(context.Background(), "1", "danske", "a9", time.Time{}, time.Time{}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Statement() error = %v, want ErrAccountNotFound", err)
+	}
+}
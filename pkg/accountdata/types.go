@@ -0,0 +1,210 @@
+package accountdata
+
+import (
+	"encoding/json"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accounts"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/aggregate"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Freshness tells how old the stored data is. SyncDateTime is the time of the oldest sync of the returned accounts.
+//It's the one of the statements, so the stored statements report it too.
+type Freshness = accounts.Freshness
+
+type Accounts struct {
+	Freshness
+	Accounts []aggregate.Account `json:"accounts"`
+}
+
+type Transactions struct {
+	Freshness
+	Transactions []normalise.Transaction `json:"transactions"`
+}
+
+//SyncResult is the outcome of a sync. The accounts whose balances or transactions couldn't be read are listed
+//in Failures and keep their previous data, so they're synced again with the next read.
+type SyncResult struct {
+	SyncDateTime time.Time           `json:"syncDateTime"`
+	Accounts     int                 `json:"accounts"`
+	Transactions int                 `json:"transactions"`
+	Failures     []aggregate.Failure `json:"failures"`
+}
+
+type account struct {
+	Id             int64     `db:"id"`
+	AspspId        string    `db:"aspsp_id"`
+	Cid            string    `db:"cid"`
+	AccountId      string    `db:"account_id"`
+	Currency       string    `db:"currency"`
+	AccountType    string    `db:"account_type"`
+	AccountSubType string    `db:"account_sub_type"`
+	Nickname       string    `db:"nickname"`
+	Identifiers    string    `db:"identifiers"`
+	Servicer       string    `db:"servicer"`
+	SyncDateTime   time.Time `db:"sync_date_time"`
+}
+
+type balance struct {
+	Id                 int64             `db:"id"`
+	AspspId            string            `db:"aspsp_id"`
+	Cid                string            `db:"cid"`
+	AccountId          string            `db:"account_id"`
+	BalanceType        string            `db:"balance_type"`
+	Amount             normalise.Decimal `db:"amount"`
+	Currency           string            `db:"currency"`
+	DateTime           time.Time         `db:"date_time"`
+	CreditLineIncluded bool              `db:"credit_line_included"`
+}
+
+//transaction is keyed by TransactionKey, which is the TransactionId or, if the aspsp doesn't send one, a hash of
+//the transaction and its occurrence, so the transactions which are fetched again in the overlap aren't duplicated.
+type transaction struct {
+	Id              int64              `db:"id"`
+	AspspId         string             `db:"aspsp_id"`
+	Cid             string             `db:"cid"`
+	AccountId       string             `db:"account_id"`
+	TransactionKey  string             `db:"transaction_key"`
+	TransactionId   string             `db:"transaction_id"`
+	Reference       string             `db:"reference"`
+	Status          string             `db:"status"`
+	BookingDateTime time.Time          `db:"booking_date_time"`
+	ValueDateTime   *time.Time         `db:"value_date_time"`
+	Amount          normalise.Decimal  `db:"amount"`
+	Currency        string             `db:"currency"`
+	Description     string             `db:"description"`
+	MerchantName    string             `db:"merchant_name"`
+	BalanceAmount   *normalise.Decimal `db:"balance_amount"`
+	BalanceCurrency *string            `db:"balance_currency"`
+	UpdateDateTime  time.Time          `db:"update_date_time"`
+}
+
+func newAccount(a normalise.Account, syncDateTime time.Time) (*account, error) {
+	identifiers, err := json.Marshal(a.Identifiers)
+	if err != nil {
+		return nil, err
+	}
+	var servicer []byte
+	if a.Servicer != nil {
+		if servicer, err = json.Marshal(a.Servicer); err != nil {
+			return nil, err
+		}
+	}
+
+	return &account{
+		AspspId:        a.AspspId,
+		Cid:            a.Cid,
+		AccountId:      a.AccountId,
+		Currency:       a.Currency,
+		AccountType:    a.Type,
+		AccountSubType: a.SubType,
+		Nickname:       a.Nickname,
+		Identifiers:    string(identifiers),
+		Servicer:       string(servicer),
+		SyncDateTime:   syncDateTime.UTC(),
+	}, nil
+}
+
+func (a account) normalised() (normalise.Account, error) {
+	normalised := normalise.Account{
+		Source:      normalise.Source{AspspId: a.AspspId, Cid: a.Cid},
+		AccountId:   a.AccountId,
+		Currency:    a.Currency,
+		Type:        a.AccountType,
+		SubType:     a.AccountSubType,
+		Nickname:    a.Nickname,
+		Identifiers: []normalise.Identifier{},
+	}
+	if err := json.Unmarshal([]byte(a.Identifiers), &normalised.Identifiers); err != nil {
+		return normalise.Account{}, err
+	}
+	if a.Servicer != "" {
+		normalised.Servicer = &normalise.Identifier{}
+		if err := json.Unmarshal([]byte(a.Servicer), normalised.Servicer); err != nil {
+			return normalise.Account{}, err
+		}
+	}
+
+	return normalised, nil
+}
+
+func newBalance(b normalise.Balance) *balance {
+	return &balance{
+		AspspId:            b.AspspId,
+		Cid:                b.Cid,
+		AccountId:          b.AccountId,
+		BalanceType:        b.Type,
+		Amount:             b.Amount.Value,
+		Currency:           b.Amount.Currency,
+		DateTime:           b.DateTime.UTC(),
+		CreditLineIncluded: b.CreditLineIncluded,
+	}
+}
+
+func (b balance) normalised() normalise.Balance {
+	return normalise.Balance{
+		Source:             normalise.Source{AspspId: b.AspspId, Cid: b.Cid},
+		AccountId:          b.AccountId,
+		Type:               b.BalanceType,
+		Amount:             normalise.Amount{Value: b.Amount, Currency: b.Currency},
+		DateTime:           b.DateTime.UTC(),
+		CreditLineIncluded: b.CreditLineIncluded,
+	}
+}
+
+func newTransaction(t normalise.Transaction, updateDateTime time.Time) *transaction {
+	row := &transaction{
+		AspspId:         t.AspspId,
+		Cid:             t.Cid,
+		AccountId:       t.AccountId,
+		TransactionKey:  t.Key(),
+		TransactionId:   t.TransactionId,
+		Reference:       t.Reference,
+		Status:          t.Status,
+		BookingDateTime: t.BookingDateTime.UTC(),
+		Amount:          t.Amount.Value,
+		Currency:        t.Amount.Currency,
+		Description:     t.Description,
+		MerchantName:    t.MerchantName,
+		UpdateDateTime:  updateDateTime.UTC(),
+	}
+	if t.ValueDateTime != nil {
+		valueDateTime := t.ValueDateTime.UTC()
+		row.ValueDateTime = &valueDateTime
+	}
+	if t.Balance != nil {
+		row.BalanceAmount = &t.Balance.Value
+		row.BalanceCurrency = &t.Balance.Currency
+	}
+
+	return row
+}
+
+func (t transaction) normalised() normalise.Transaction {
+	normalised := normalise.Transaction{
+		Source:          normalise.Source{AspspId: t.AspspId, Cid: t.Cid},
+		AccountId:       t.AccountId,
+		TransactionId:   t.TransactionId,
+		Reference:       t.Reference,
+		Status:          t.Status,
+		BookingDateTime: t.BookingDateTime.UTC(),
+		Amount:          normalise.Amount{Value: t.Amount, Currency: t.Currency},
+		Description:     t.Description,
+		MerchantName:    t.MerchantName,
+	}
+	//the occurrence of the identical transactions without an id is kept in their key
+	if i := strings.LastIndex(t.TransactionKey, "#"); t.TransactionId == "" && i >= 0 {
+		normalised.Occurrence, _ = strconv.Atoi(t.TransactionKey[i+1:])
+	}
+	if t.ValueDateTime != nil {
+		valueDateTime := t.ValueDateTime.UTC()
+		normalised.ValueDateTime = &valueDateTime
+	}
+	if t.BalanceAmount != nil && t.BalanceCurrency != nil {
+		normalised.Balance = &normalise.Amount{Value: *t.BalanceAmount, Currency: *t.BalanceCurrency}
+	}
+
+	return normalised
+}
@@ -0,0 +1,75 @@
+package accounts
+
+import (
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//ErrAccountNotFound is returned when the aspsp doesn't return the account of the statement.
+var ErrAccountNotFound = api.NewError(http.StatusNotFound, api.ResourceNotFound, "couldn't find the account")
+
+//exporter writes a statement in a format. The transactions are written as they're read, so the statement isn't
+//kept in memory.
+type exporter struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, st *Statement, columns []string) error
+}
+
+var exporters = map[string]exporter{
+	"csv":     {contentType: "text/csv; charset=utf-8", extension: "csv", write: writeCsv},
+	"ofx":     {contentType: "application/x-ofx", extension: "ofx", write: writeOfx},
+	"camt053": {contentType: "application/xml; charset=utf-8", extension: "xml", write: writeCamt053},
+}
+
+//csvColumns are the values of the CSV columns of a transaction.
+var csvColumns = map[string]func(t normalise.Transaction) string{
+	"transactionId":   func(t normalise.Transaction) string { return csvText(t.TransactionId) },
+	"bookingDateTime": func(t normalise.Transaction) string { return t.BookingDateTime.UTC().Format(time.RFC3339) },
+	"valueDateTime": func(t normalise.Transaction) string {
+		if t.ValueDateTime == nil {
+			return ""
+		}
+		return t.ValueDateTime.UTC().Format(time.RFC3339)
+	},
+	"status":       func(t normalise.Transaction) string { return csvText(t.Status) },
+	"amount":       func(t normalise.Transaction) string { return t.Amount.Value.String() },
+	"currency":     func(t normalise.Transaction) string { return csvText(t.Amount.Currency) },
+	"creditDebit":  func(t normalise.Transaction) string { return creditDebit(t.Amount.Value, "Credit", "Debit") },
+	"reference":    func(t normalise.Transaction) string { return csvText(t.Reference) },
+	"description":  func(t normalise.Transaction) string { return csvText(t.Description) },
+	"merchantName": func(t normalise.Transaction) string { return csvText(t.MerchantName) },
+	"balance": func(t normalise.Transaction) string {
+		if t.Balance == nil {
+			return ""
+		}
+		return t.Balance.Value.String()
+	},
+}
+
+//ParseCsvColumns parses the comma separated CSV columns, e.g. bookingDateTime,amount,currency,description
+func ParseCsvColumns(value string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if _, found := csvColumns[column]; !found {
+			return nil, fmt.Errorf("%q isn't a csv column", column)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+func creditDebit(value normalise.Decimal, credit, debit string) string {
+	if value.Unscaled < 0 {
+		return debit
+	}
+
+	return credit
+}
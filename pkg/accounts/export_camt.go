@@ -0,0 +1,201 @@
+package accounts
+
+import (
+	"encoding/xml"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+//camtBalanceCodes are the ISO 20022 codes of the OB balance types which can be in a statement.
+var camtBalanceCodes = map[string]string{
+	"ClosingAvailable": "CLAV",
+	"InterimAvailable": "ITAV",
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtDateTime struct {
+	DtTm string `xml:"DtTm"`
+}
+
+type camtAccount struct {
+	XMLName  xml.Name     `xml:"Acct"`
+	Iban     string       `xml:"Id>IBAN,omitempty"`
+	Other    *camtOtherId `xml:"Id>Othr,omitempty"`
+	Currency string       `xml:"Ccy,omitempty"`
+	Name     string       `xml:"Nm,omitempty"`
+}
+
+//camtOtherId is the account id which isn't an IBAN, e.g. the sort code and the account number.
+type camtOtherId struct {
+	Id     string `xml:"Id"`
+	Scheme string `xml:"SchmeNm>Prtry"`
+}
+
+type camtBalance struct {
+	XMLName     xml.Name     `xml:"Bal"`
+	Code        string       `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount   `xml:"Amt"`
+	CreditDebit string       `xml:"CdtDbtInd"`
+	Date        camtDateTime `xml:"Dt"`
+}
+
+type camtEntry struct {
+	XMLName     xml.Name      `xml:"Ntry"`
+	Amount      camtAmount    `xml:"Amt"`
+	CreditDebit string        `xml:"CdtDbtInd"`
+	Status      string        `xml:"Sts"`
+	BookingDate camtDateTime  `xml:"BookgDt"`
+	ValueDate   *camtDateTime `xml:"ValDt,omitempty"`
+	ServicerRef string        `xml:"AcctSvcrRef,omitempty"`
+	BankTxCode  struct{}      `xml:"BkTxCd"`
+	Details     *camtDetails  `xml:"NtryDtls>TxDtls,omitempty"`
+	Information string        `xml:"AddtlNtryInf,omitempty"`
+}
+
+type camtDetails struct {
+	Parties    *camtParties    `xml:"RltdPties,omitempty"`
+	Remittance *camtRemittance `xml:"RmtInf,omitempty"`
+}
+
+//camtParties has the merchant, which is the creditor of a debit, and the debtor of a credit.
+type camtParties struct {
+	Debtor   *camtParty `xml:"Dbtr,omitempty"`
+	Creditor *camtParty `xml:"Cdtr,omitempty"`
+}
+
+type camtRemittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+type camtParty struct {
+	Name string `xml:"Nm"`
+}
+
+//writeCamt053 writes the statement as an ISO 20022 camt.053.001.02 bank to customer statement. The booked balances are
+//OPBD and CLBD of the period, and the available balance of the aspsp is added if the period ends after it.
+func writeCamt053(w io.Writer, st *Statement, _ []string) error {
+	x := newXmlWriter(w, xml.Header)
+
+	x.start("Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace})
+	x.start("BkToCstmrStmt")
+	x.element(struct {
+		XMLName xml.Name `xml:"GrpHdr"`
+		MsgId   string   `xml:"MsgId"`
+		CreDtTm string   `xml:"CreDtTm"`
+	}{MsgId: camtId(), CreDtTm: camtDateTimeOf(st.CreationDateTime)})
+
+	x.start("Stmt")
+	x.value("Id", camtId())
+	x.value("CreDtTm", camtDateTimeOf(st.CreationDateTime))
+	x.element(struct {
+		XMLName xml.Name `xml:"FrToDt"`
+		From    string   `xml:"FrDtTm"`
+		To      string   `xml:"ToDtTm"`
+	}{From: camtDateTimeOf(st.From), To: camtDateTimeOf(st.To)})
+	x.element(newCamtAccount(st.Account))
+
+	if st.OpeningBooked != nil {
+		x.element(newCamtBalance("OPBD", *st.OpeningBooked, st.From))
+	}
+	if st.ClosingBooked != nil {
+		x.element(newCamtBalance("CLBD", *st.ClosingBooked, st.To))
+	}
+	if st.Available != nil {
+		x.element(newCamtBalance(camtBalanceCodes[st.Available.Type], st.Available.Amount, st.Available.DateTime))
+	}
+
+	if x.err == nil {
+		x.err = st.Transactions(func(t normalise.Transaction) error {
+			x.element(newCamtEntry(t))
+			return x.err
+		})
+	}
+	x.end("Stmt")
+	x.end("BkToCstmrStmt")
+	x.end("Document")
+
+	return errors.WithMessage(x.flush(), "error in writeCamt053()")
+}
+
+func newCamtAccount(account normalise.Account) camtAccount {
+	a := camtAccount{Currency: account.Currency, Name: truncate(account.Nickname, 70)}
+	for _, identifier := range account.Identifiers {
+		if identifier.Scheme == normalise.IBAN && identifier.Valid {
+			a.Iban = identifier.Identification
+			return a
+		}
+	}
+
+	a.Other = &camtOtherId{Id: account.AccountId, Scheme: "AccountId"}
+	for _, identifier := range account.Identifiers {
+		if identifier.Scheme == normalise.SortCodeAccountNumber {
+			a.Other.Id, a.Other.Scheme = identifier.Identification, normalise.SortCodeAccountNumber
+			break
+		}
+	}
+
+	return a
+}
+
+func newCamtBalance(code string, amount normalise.Amount, date time.Time) camtBalance {
+	return camtBalance{
+		Code:        code,
+		Amount:      camtAmount{Currency: amount.Currency, Value: amount.Value.Abs().String()},
+		CreditDebit: creditDebit(amount.Value, "CRDT", "DBIT"),
+		Date:        camtDateTime{DtTm: camtDateTimeOf(date)},
+	}
+}
+
+func newCamtEntry(t normalise.Transaction) camtEntry {
+	e := camtEntry{
+		Amount:      camtAmount{Currency: t.Amount.Currency, Value: t.Amount.Value.Abs().String()},
+		CreditDebit: creditDebit(t.Amount.Value, "CRDT", "DBIT"),
+		Status:      "BOOK",
+		BookingDate: camtDateTime{DtTm: camtDateTimeOf(t.BookingDateTime)},
+		ServicerRef: truncate(t.TransactionId, 35),
+		Information: truncate(t.Description, 500),
+	}
+	if t.Status == normalise.StatusPending {
+		e.Status = "PDNG"
+	}
+	if t.ValueDateTime != nil {
+		e.ValueDate = &camtDateTime{DtTm: camtDateTimeOf(*t.ValueDateTime)}
+	}
+
+	details := &camtDetails{}
+	if t.Reference != "" {
+		details.Remittance = &camtRemittance{Unstructured: truncate(t.Reference, 140)}
+	}
+	if t.MerchantName != "" {
+		merchant := &camtParty{Name: truncate(t.MerchantName, 140)}
+		if t.Amount.Value.Unscaled < 0 {
+			details.Parties = &camtParties{Creditor: merchant}
+		} else {
+			details.Parties = &camtParties{Debtor: merchant}
+		}
+	}
+	if details.Parties != nil || details.Remittance != nil {
+		e.Details = details
+	}
+
+	return e
+}
+
+//camtId returns a unique id of up to 35 characters, the max length of the ISO 20022 ids.
+func camtId() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+func camtDateTimeOf(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,41 @@
+package accounts
+
+import (
+	"encoding/csv"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+//writeCsv writes a header of the columns and a row of each transaction. The balances aren't written, since a CSV
+//has only the rows of the same columns.
+func writeCsv(w io.Writer, st *Statement, columns []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return errors.WithMessage(err, "error in writeCsv()")
+	}
+
+	row := make([]string, len(columns))
+	err := st.Transactions(func(t normalise.Transaction) error {
+		for i, column := range columns {
+			row[i] = csvColumns[column](t)
+		}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error in writeCsv()")
+	}
+
+	cw.Flush()
+	return errors.WithMessage(cw.Error(), "error in writeCsv()")
+}
+
+//csvText prefixes a quote to the texts of the aspsp which a spreadsheet would run as a formula, e.g. a description
+//of =HYPERLINK(...). The amounts aren't texts, so a negative amount is written as it is.
+func csvText(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
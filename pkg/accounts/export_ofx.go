@@ -0,0 +1,202 @@
+package accounts
+
+import (
+	"encoding/xml"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"io"
+	"time"
+)
+
+const (
+	ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+		`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+	ofxDateTimeLayout = "20060102150405.000[0:GMT]"
+)
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	XMLName  xml.Name  `xml:"SIGNONMSGSRSV1"`
+	Status   ofxStatus `xml:"SONRS>STATUS"`
+	DtServer string    `xml:"SONRS>DTSERVER"`
+	Language string    `xml:"SONRS>LANGUAGE"`
+}
+
+type ofxBankAccount struct {
+	XMLName  xml.Name `xml:"BANKACCTFROM"`
+	BankId   string   `xml:"BANKID"`
+	AcctId   string   `xml:"ACCTID"`
+	AcctType string   `xml:"ACCTTYPE"`
+}
+
+type ofxTransaction struct {
+	XMLName  xml.Name `xml:"STMTTRN"`
+	TrnType  string   `xml:"TRNTYPE"`
+	DtPosted string   `xml:"DTPOSTED"`
+	DtAvail  string   `xml:"DTAVAIL,omitempty"`
+	TrnAmt   string   `xml:"TRNAMT"`
+	FitId    string   `xml:"FITID"`
+	Name     string   `xml:"NAME,omitempty"`
+	Memo     string   `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	BalAmt string `xml:"BALAMT"`
+	DtAsOf string `xml:"DTASOF"`
+}
+
+//writeOfx writes the statement as an OFX 2.2 bank statement. OFX has only the posted transactions, so the pending
+//ones aren't written. LEDGERBAL is the closing booked balance, and it isn't written if it can't be worked out.
+func writeOfx(w io.Writer, st *Statement, _ []string) error {
+	x := newXmlWriter(w, ofxHeader)
+
+	x.start("OFX")
+	x.element(ofxSignOn{Status: ofxStatus{Severity: "INFO"}, DtServer: ofxDateTime(st.CreationDateTime), Language: "ENG"})
+	x.start("BANKMSGSRSV1")
+	x.start("STMTTRNRS")
+	x.value("TRNUID", "0")
+	x.element(struct {
+		XMLName xml.Name `xml:"STATUS"`
+		ofxStatus
+	}{ofxStatus: ofxStatus{Severity: "INFO"}})
+	x.start("STMTRS")
+	x.value("CURDEF", st.Account.Currency)
+	x.element(ofxAccount(st.Account))
+
+	x.start("BANKTRANLIST")
+	x.value("DTSTART", ofxDateTime(st.From))
+	x.value("DTEND", ofxDateTime(st.To))
+	if x.err == nil {
+		x.err = st.Transactions(func(t normalise.Transaction) error {
+			if t.Status == normalise.StatusPending {
+				return nil
+			}
+			x.element(newOfxTransaction(t))
+			return x.err
+		})
+	}
+	x.end("BANKTRANLIST")
+
+	if st.ClosingBooked != nil {
+		x.element(struct {
+			XMLName xml.Name `xml:"LEDGERBAL"`
+			ofxBalance
+		}{ofxBalance: ofxBalance{BalAmt: st.ClosingBooked.Value.String(), DtAsOf: ofxDateTime(st.To)}})
+	}
+	if st.Available != nil {
+		x.element(struct {
+			XMLName xml.Name `xml:"AVAILBAL"`
+			ofxBalance
+		}{ofxBalance: ofxBalance{BalAmt: st.Available.Amount.Value.String(), DtAsOf: ofxDateTime(st.Available.DateTime)}})
+	}
+	x.end("STMTRS")
+	x.end("STMTTRNRS")
+	x.end("BANKMSGSRSV1")
+	x.end("OFX")
+
+	return errors.WithMessage(x.flush(), "error in writeOfx()")
+}
+
+//ofxAccount returns the sort code and the account number of the account, or its IBAN or id if it has no UK identifier.
+func ofxAccount(account normalise.Account) ofxBankAccount {
+	a := ofxBankAccount{BankId: account.AspspId, AcctId: account.AccountId, AcctType: "CHECKING"}
+	if account.SubType == "Savings" {
+		a.AcctType = "SAVINGS"
+	}
+	for _, identifier := range account.Identifiers {
+		if identifier.SortCode != "" && identifier.AccountNumber != "" {
+			a.BankId, a.AcctId = identifier.SortCode, identifier.AccountNumber
+			return a
+		}
+		if identifier.Scheme == normalise.IBAN && identifier.Valid {
+			a.AcctId = identifier.Identification
+		}
+	}
+
+	return a
+}
+
+func newOfxTransaction(t normalise.Transaction) ofxTransaction {
+	o := ofxTransaction{
+		TrnType:  creditDebit(t.Amount.Value, "CREDIT", "DEBIT"),
+		DtPosted: ofxDateTime(t.BookingDateTime),
+		TrnAmt:   t.Amount.Value.String(),
+		FitId:    t.Key(),
+		Name:     truncate(t.MerchantName, 32),
+		Memo:     truncate(t.Description, 255),
+	}
+	if o.Name == "" {
+		o.Name = truncate(t.Reference, 32)
+	}
+	if t.ValueDateTime != nil {
+		o.DtAvail = ofxDateTime(*t.ValueDateTime)
+	}
+
+	return o
+}
+
+func ofxDateTime(t time.Time) string {
+	return t.UTC().Format(ofxDateTimeLayout)
+}
+
+//truncate cuts value to max characters, as the fields of the statement formats have a max length.
+func truncate(value string, max int) string {
+	if runes := []rune(value); len(runes) > max {
+		return string(runes[:max])
+	}
+
+	return value
+}
+
+//xmlWriter writes the elements of a statement as they're read. The first error is kept and the later writes are skipped.
+type xmlWriter struct {
+	enc *xml.Encoder
+	err error
+}
+
+//newXmlWriter returns the writer of a document which starts with header, e.g. xml.Header.
+func newXmlWriter(w io.Writer, header string) *xmlWriter {
+	_, err := io.WriteString(w, header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	return &xmlWriter{enc: enc, err: err}
+}
+
+func (x *xmlWriter) token(t xml.Token) {
+	if x.err == nil {
+		x.err = x.enc.EncodeToken(t)
+	}
+}
+
+func (x *xmlWriter) start(name string, attr ...xml.Attr) {
+	x.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attr})
+}
+
+func (x *xmlWriter) end(name string) {
+	x.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (x *xmlWriter) value(name, value string, attr ...xml.Attr) {
+	if x.err == nil {
+		x.err = x.enc.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}, Attr: attr})
+	}
+}
+
+func (x *xmlWriter) element(v interface{}) {
+	if x.err == nil {
+		x.err = x.enc.Encode(v)
+	}
+}
+
+func (x *xmlWriter) flush() error {
+	if x.err != nil {
+		return x.err
+	}
+
+	return x.enc.Flush()
+}
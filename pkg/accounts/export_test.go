@@ -0,0 +1,291 @@
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testNow     = time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	testAccount = normalise.Account{
+		Source:    normalise.Source{AspspId: "danske", Cid: "10"},
+		AccountId: "1", Currency: "GBP", SubType: "CurrentAccount",
+		Identifiers: []normalise.Identifier{normalise.NewIdentifier("UK.OBIE.SortCodeAccountNumber", "80200110203345", "Mr Kevin", "")},
+	}
+)
+
+func amount(value string) normalise.Amount {
+	d, _ := normalise.ParseDecimal(value)
+	return normalise.Amount{Value: d, Currency: "GBP"}
+}
+
+func testTransactions() []normalise.Transaction {
+	valueDateTime := time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)
+	return []normalise.Transaction{
+		{TransactionId: "t1", Status: normalise.StatusBooked, BookingDateTime: time.Date(2021, 1, 2, 9, 0, 0, 0, time.UTC),
+			ValueDateTime: &valueDateTime, Amount: amount("100.00"), Reference: "SALARY", Description: "Salary, January"},
+		{Status: normalise.StatusBooked, BookingDateTime: time.Date(2021, 1, 5, 9, 0, 0, 0, time.UTC),
+			Amount: amount("-20.50"), MerchantName: "Coffee & Co", Description: "Card payment"},
+		{TransactionId: "t3", Status: normalise.StatusPending, BookingDateTime: time.Date(2021, 1, 9, 9, 0, 0, 0, time.UTC),
+			Amount: amount("-5.00"), Description: "Pending payment"},
+	}
+}
+
+func iterate(transactions []normalise.Transaction) TransactionIterator {
+	return func(fn func(normalise.Transaction) error) error {
+		for _, t := range transactions {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	current := []normalise.Balance{
+		{Type: "InterimAvailable", Amount: amount("1000.00"), DateTime: time.Date(2021, 1, 10, 8, 0, 0, 0, time.UTC)},
+		{Type: "InterimBooked", Amount: amount("979.50"), DateTime: time.Date(2021, 1, 10, 8, 0, 0, 0, time.UTC)},
+	}
+	withBalance := testTransactions()
+	balance := amount("500.00")
+	withBalance[0].Balance = &balance
+	latestFirst := []normalise.Transaction{withBalance[2], withBalance[1], withBalance[0]}
+
+	tests := []struct {
+		name          string
+		to            time.Time
+		transactions  []normalise.Transaction
+		wantFrom      time.Time
+		wantOpening   string
+		wantClosing   string
+		wantAvailable bool
+	}{
+		{"current_balance", time.Time{}, testTransactions(), time.Date(2021, 1, 2, 9, 0, 0, 0, time.UTC), "900.00", "979.50", true},
+		{"transaction_balance", time.Time{}, withBalance, time.Date(2021, 1, 2, 9, 0, 0, 0, time.UTC), "400.00", "479.50", true},
+		{"transaction_balance_latest_first", time.Time{}, latestFirst, time.Date(2021, 1, 2, 9, 0, 0, 0, time.UTC), "400.00", "479.50", true},
+		{"past_period_unknown", time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC), testTransactions(), time.Date(2021, 1, 2, 9, 0, 0, 0, time.UTC), "", "", false},
+		{"no_transactions", time.Time{}, nil, testNow, "979.50", "979.50", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := NewStatement(testAccount, current, time.Time{}, tt.to, testNow, iterate(tt.transactions))
+			if err != nil {
+				t.Fatalf("NewStatement() error = %v", err)
+			}
+			if !st.From.Equal(tt.wantFrom) {
+				t.Errorf("NewStatement() From = %v, want %v", st.From, tt.wantFrom)
+			}
+			if got := amountString(st.OpeningBooked); got != tt.wantOpening {
+				t.Errorf("NewStatement() OpeningBooked = %v, want %v", got, tt.wantOpening)
+			}
+			if got := amountString(st.ClosingBooked); got != tt.wantClosing {
+				t.Errorf("NewStatement() ClosingBooked = %v, want %v", got, tt.wantClosing)
+			}
+			if (st.Available != nil) != tt.wantAvailable {
+				t.Errorf("NewStatement() Available = %v, want %v", st.Available, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func amountString(a *normalise.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.Value.String()
+}
+
+func testStatement(t *testing.T) *Statement {
+	current := []normalise.Balance{{Type: "InterimBooked", Amount: amount("979.50"), DateTime: time.Date(2021, 1, 10, 8, 0, 0, 0, time.UTC)}}
+	st, err := NewStatement(testAccount, current, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}, testNow, iterate(testTransactions()))
+	if err != nil {
+		t.Fatalf("NewStatement() error = %v", err)
+	}
+	return st
+}
+
+func Test_writeCsv(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeCsv(&b, testStatement(t), []string{"bookingDateTime", "amount", "creditDebit", "status", "description"}); err != nil {
+		t.Fatalf("writeCsv() error = %v", err)
+	}
+
+	want := "bookingDateTime,amount,creditDebit,status,description\n" +
+		"2021-01-02T09:00:00Z,100.00,Credit,Booked,\"Salary, January\"\n" +
+		"2021-01-05T09:00:00Z,-20.50,Debit,Booked,Card payment\n" +
+		"2021-01-09T09:00:00Z,-5.00,Debit,Pending,Pending payment\n"
+	if b.String() != want {
+		t.Errorf("writeCsv() got = %v, want %v", b.String(), want)
+	}
+}
+
+func Test_csvText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Card payment", "Card payment"},
+		{"", ""},
+		{"=HYPERLINK(\"https://attacker\")", "'=HYPERLINK(\"https://attacker\")"},
+		{"+44 20", "'+44 20"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.value); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseCsvColumns(t *testing.T) {
+	if columns, err := ParseCsvColumns("amount, currency"); err != nil || len(columns) != 2 || columns[1] != "currency" {
+		t.Errorf("ParseCsvColumns() got = %v, %v", columns, err)
+	}
+	if _, err := ParseCsvColumns("amount,iban"); err == nil {
+		t.Errorf("ParseCsvColumns() error = nil, want an error for the unknown column")
+	}
+}
+
+func Test_writeOfx(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeOfx(&b, testStatement(t), nil); err != nil {
+		t.Fatalf("writeOfx() error = %v", err)
+	}
+
+	values := xmlValues(t, b.String())
+	checks := map[string]string{
+		"CURDEF":   "GBP",
+		"BANKID":   "802001",
+		"ACCTID":   "10203345",
+		"ACCTTYPE": "CHECKING",
+		"DTSTART":  "20210101000000.000[0:GMT]",
+		"DTEND":    "20210110120000.000[0:GMT]",
+		"STMTTRN":  "2",
+		"TRNAMT":   "100.00,-20.50",
+		"TRNTYPE":  "CREDIT,DEBIT",
+		"NAME":     "SALARY,Coffee & Co",
+		"BALAMT":   "979.50",
+	}
+	for element, want := range checks {
+		if got := values[element]; got != want {
+			t.Errorf("writeOfx() %v = %v, want %v", element, got, want)
+		}
+	}
+	if !strings.Contains(b.String(), `<?OFX OFXHEADER="200" VERSION="220"`) {
+		t.Errorf("writeOfx() has no OFX 2.2 header")
+	}
+}
+
+func Test_writeCamt053(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeCamt053(&b, testStatement(t), nil); err != nil {
+		t.Fatalf("writeCamt053() error = %v", err)
+	}
+
+	values := xmlValues(t, b.String())
+	checks := map[string]string{
+		"Ntry":      "3",
+		"Cd":        "OPBD,CLBD",
+		"Amt":       "900.00,979.50,100.00,20.50,5.00",
+		"CdtDbtInd": "CRDT,CRDT,CRDT,DBIT,DBIT",
+		"Sts":       "BOOK,BOOK,PDNG",
+		"FrDtTm":    "2021-01-01T00:00:00Z",
+		"ToDtTm":    "2021-01-10T12:00:00Z",
+		"Prtry":     "SortCodeAccountNumber",
+		"Ustrd":     "SALARY",
+		"Nm":        "Coffee & Co",
+	}
+	for element, want := range checks {
+		if got := values[element]; got != want {
+			t.Errorf("writeCamt053() %v = %v, want %v", element, got, want)
+		}
+	}
+	if !strings.Contains(b.String(), `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">`) {
+		t.Errorf("writeCamt053() has no camt.053 document")
+	}
+}
+
+//xmlValues parses the document and returns the comma separated texts of each element. The elements which only
+//have child elements are counted instead.
+func xmlValues(t *testing.T, document string) map[string]string {
+	values, counts := map[string][]string{}, map[string]int{}
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	var text string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the document isn't well formed: %v", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			text = ""
+			counts[token.Name.Local]++
+		case xml.CharData:
+			text = strings.TrimSpace(string(token))
+		case xml.EndElement:
+			if text != "" {
+				values[token.Name.Local] = append(values[token.Name.Local], text)
+			}
+			text = ""
+		}
+	}
+
+	res := map[string]string{}
+	for name, count := range counts {
+		if v, found := values[name]; found {
+			res[name] = strings.Join(v, ",")
+		} else {
+			res[name] = strconv.Itoa(count)
+		}
+	}
+	return res
+}
+
+type statementReaderStub struct {
+	statement *Statement
+}
+
+func (s statementReaderStub) Statement(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*Statement, error) {
+	return s.statement, nil
+}
+
+func Test_export_freshness(t *testing.T) {
+	stored, err := NewStatement(testAccount, nil, time.Time{}, time.Time{}, testNow, iterate(testTransactions()))
+	if err != nil {
+		t.Fatalf("NewStatement() error = %v", err)
+	}
+	stored.Freshness = &Freshness{SyncDateTime: testNow.Add(-2 * time.Hour), Stale: true, Syncing: true}
+	fromAspsp, _ := NewStatement(testAccount, nil, time.Time{}, time.Time{}, testNow, iterate(testTransactions()))
+
+	e := echo.New()
+	RegisterExportHandler(e, statementReaderStub{statement: fromAspsp}, statementReaderStub{statement: stored}, []string{"amount"})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/danske/accounts/1/export/cid/10?source=stored", nil))
+	if rec.Header().Get("X-Sync-Date-Time") != "2021-01-10T10:00:00Z" || rec.Header().Get("X-Stale") != "true" || rec.Header().Get("X-Syncing") != "true" {
+		t.Errorf("export() stored headers = %v, want the freshness of the stored data", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/danske/accounts/1/export/cid/10", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Sync-Date-Time") != "" {
+		t.Errorf("export() aspsp status = %v, headers = %v, want no freshness", rec.Code, rec.Header())
+	}
+}
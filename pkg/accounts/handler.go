@@ -1,46 +1,239 @@
 package accounts
 
 import (
-	"database/sql"
-	"errors"
+	"fmt"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func RegisterHandler(e *echo.Echo, accountService Service) {
-	e.GET("/:aspspId/accounts/cid/:cid", callAccounts(accountService))
-	e.GET("/:aspspId/accounts/:accountId/cid/:cid", callAccounts(accountService))
+	for _, v := range api.Versions {
+		g := e.Group(v.Prefix)
+		g.GET("/:aspspId/accounts/cid/:cid", callAccounts(accountService, v), v.Middleware...)
+		g.GET("/:aspspId/accounts/:accountId/cid/:cid", callAccounts(accountService, v), v.Middleware...)
+		g.GET("/:aspspId/accounts/:accountId/balances/cid/:cid", callBalances(accountService, v), v.Middleware...)
+		g.GET("/:aspspId/accounts/:accountId/transactions/cid/:cid", callTransactions(accountService, v), v.Middleware...)
+	}
+
+	//the normalised resources are only served by v2, since v1 has no typed responses
+	g := e.Group("/v2/:aspspId/normalised")
+	g.GET("/accounts/cid/:cid", normalisedAccounts(accountService))
+	g.GET("/accounts/:accountId/cid/:cid", normalisedAccounts(accountService))
+	g.GET("/accounts/:accountId/balances/cid/:cid", normalisedBalances(accountService))
+	g.GET("/accounts/:accountId/transactions/cid/:cid", normalisedTransactions(accountService))
+}
+
+//RegisterExportHandler registers the statement exports of the accounts, which are only served by v2. The statements
+//are read from the aspsp, or from the stored account data with source=stored.
+func RegisterExportHandler(e *echo.Echo, aspsp, stored StatementReader, csvColumns []string) {
+	readers := map[string]StatementReader{"aspsp": aspsp, "stored": stored}
+	e.GET("/v2/:aspspId/accounts/:accountId/export/cid/:cid", export(readers, csvColumns))
+}
+
+func callAccounts(s Service, v api.Version) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := accounts(c, s)
+		if err != nil {
+			return err
+		}
+
+		return v.Respond(c, res)
+	}
+}
+
+func callBalances(s Service, v api.Version) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := balances(c, s)
+		if err != nil {
+			return err
+		}
+
+		return v.Respond(c, res)
+	}
+}
+
+func callTransactions(s Service, v api.Version) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := transactions(c, s)
+		if err != nil {
+			return err
+		}
+
+		return v.Respond(c, res)
+	}
+}
+
+func normalisedAccounts(s Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := accounts(c, s)
+		if err != nil {
+			return err
+		}
+
+		accounts, err := normalise.Accounts(source(c), res)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, accounts)
+	}
+}
+
+func normalisedBalances(s Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := balances(c, s)
+		if err != nil {
+			return err
+		}
+
+		balances, err := normalise.Balances(source(c), res)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, balances)
+	}
+}
+
+func normalisedTransactions(s Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := transactions(c, s)
+		if err != nil {
+			return err
+		}
+
+		transactions, err := normalise.Transactions(source(c), res)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, transactions)
+	}
 }
 
-func callAccounts(s Service) echo.HandlerFunc {
+func export(readers map[string]StatementReader, csvColumns []string) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		rid := c.Response().Header().Get(echo.HeaderXRequestID)
-		aspspId := c.Param("aspspId")
-		if aspspId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "aspspId can't be empty"))
+		aspspId, cid, err := params(c)
+		if err != nil {
+			return err
 		}
 
-		cid := c.Param("cid")
-		if aspspId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "cid can't be empty"))
+		format := c.QueryParam("format")
+		if format == "" {
+			format = "csv"
+		}
+		exporter, found := exporters[format]
+		if !found {
+			return api.NewFieldError(api.FieldInvalid, "format", "format must be one of csv, ofx, camt053")
+		}
+		source := c.QueryParam("source")
+		if source == "" {
+			source = "aspsp"
+		}
+		reader, found := readers[source]
+		if !found {
+			return api.NewFieldError(api.FieldInvalid, "source", "source must be one of aspsp, stored")
+		}
+		columns := csvColumns
+		if value := c.QueryParam("columns"); value != "" {
+			if columns, err = ParseCsvColumns(value); err != nil {
+				return api.NewFieldError(api.FieldInvalid, "columns", err.Error())
+			}
+		}
+		from, err := api.BookingDateTimeParam(c, "fromBookingDateTime")
+		if err != nil {
+			return err
+		}
+		to, err := api.BookingDateTimeParam(c, "toBookingDateTime")
+		if err != nil {
+			return err
 		}
 
 		accountId := c.Param("accountId")
-		var res string
-		var err error
-		if accountId == "" {
-			res, err = s.Accounts(cid, aspspId)
-		} else {
-			res, err = s.Account(cid, aspspId, accountId)
+		st, err := reader.Statement(c.Request().Context(), cid, aspspId, accountId, from, to)
+		if err != nil {
+			return err
 		}
 
-		if errors.Is(err, sql.ErrNoRows) {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, err.Error()))
-		} else if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+		filename := fmt.Sprintf("statement-%v-%v-%v.%v", accountId, st.From.Format("20060102"), st.To.Format("20060102"), exporter.extension)
+		c.Response().Header().Set(echo.HeaderContentType, exporter.contentType)
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		if st.Freshness != nil {
+			c.Response().Header().Set(api.XSyncDateTime, st.Freshness.SyncDateTime.Format(time.RFC3339))
+			c.Response().Header().Set(api.XStale, strconv.FormatBool(st.Freshness.Stale))
+			c.Response().Header().Set(api.XSyncing, strconv.FormatBool(st.Freshness.Syncing))
 		}
+		c.Response().WriteHeader(http.StatusOK)
+		if err := exporter.write(c.Response(), st, columns); err != nil {
+			//the status has been sent, so the statement is cut short and the error can only be logged
+			logger.Error("couldn't write the statement", logger.String("rid", c.Response().Header().Get(echo.HeaderXRequestID)),
+				logger.String("format", format), logger.Err(err))
+		}
+
+		return nil
+	}
+}
 
-		return c.JSON(http.StatusOK, res)
+func accounts(c echo.Context, s Service) (string, error) {
+	aspspId, cid, err := params(c)
+	if err != nil {
+		return "", err
 	}
+
+	accountId := c.Param("accountId")
+	if accountId == "" {
+		return s.Accounts(c.Request().Context(), cid, aspspId)
+	}
+
+	return s.Account(c.Request().Context(), cid, aspspId, accountId)
+}
+
+func balances(c echo.Context, s Service) (string, error) {
+	aspspId, cid, err := params(c)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Balances(c.Request().Context(), cid, aspspId, c.Param("accountId"))
+}
+
+func transactions(c echo.Context, s Service) (string, error) {
+	aspspId, cid, err := params(c)
+	if err != nil {
+		return "", err
+	}
+
+	from, err := api.BookingDateTimeParam(c, "fromBookingDateTime")
+	if err != nil {
+		return "", err
+	}
+	to, err := api.BookingDateTimeParam(c, "toBookingDateTime")
+	if err != nil {
+		return "", err
+	}
+
+	return s.Transactions(c.Request().Context(), cid, aspspId, c.Param("accountId"), from, to)
+}
+
+func params(c echo.Context) (aspspId, cid string, err error) {
+	aspspId = c.Param("aspspId")
+	if aspspId == "" {
+		return "", "", api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty")
+	}
+
+	cid = c.Param("cid")
+	if cid == "" {
+		return "", "", api.NewFieldError(api.FieldMissing, "cid", "cid can't be empty")
+	}
+
+	return aspspId, cid, nil
+}
+
+func source(c echo.Context) normalise.Source {
+	return normalise.Source{AspspId: c.Param("aspspId"), Cid: c.Param("cid")}
 }
@@ -1,54 +1,182 @@
 package accounts
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accesslimit"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/authmanager"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
 	"github.com/pkg/errors"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 type Service interface {
-	Account(cid, aspspId, accountId string) (string, error)
-	Accounts(cid, aspspId string) (string, error)
+	Account(ctx context.Context, cid, aspspId, accountId string) (string, error)
+	Accounts(ctx context.Context, cid, aspspId string) (string, error)
+	Balances(ctx context.Context, cid, aspspId, accountId string) (string, error)
+	//Transactions returns the transactions booked between from and to. The zero times aren't sent, so the aspsp's
+	//defaults are used.
+	Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (string, error)
+	//TransactionPages calls fn with each page of the transactions booked between from and to, following the Links.Next
+	//of the pages until the last one, and stops at the first error.
+	TransactionPages(ctx context.Context, cid, aspspId, accountId string, from, to time.Time, fn func(payload string) error) error
 }
 
+//maxTransactionPages stops following Links.Next of the aspsps which keep returning one.
+const maxTransactionPages = 1000
+
+var errTooManyPages = errors.New("the aspsp returned more than 1000 pages of transactions")
+
 type service struct {
 	authManager authmanager.AuthManager
 	cfg         cfg.Service
+	accessLimit accesslimit.Service
 }
 
-func NewService(authManager authmanager.AuthManager, cfg cfg.Service) Service {
+func NewService(authManager authmanager.AuthManager, cfg cfg.Service, accessLimit accesslimit.Service) Service {
 	return &service{
 		authManager: authManager,
 		cfg:         cfg,
+		accessLimit: accessLimit,
 	}
 }
 
-func (s service) Account(cid, aspspId, accountId string) (string, error) {
+func (s service) Account(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	ctx, span := tracing.Start(ctx, "accounts.Account", tracing.KindInternal)
+	defer span.End()
+
 	endpointAccounts, err := s.cfg.FindByConfigName(aspspId, api.EndpointAccounts)
 	if err != nil {
 		return "", err
 	}
 	endpointAccounts = endpointAccounts + "/" + accountId
 
-	return s.processCall(cid, aspspId, endpointAccounts)
+	return s.processCall(ctx, cid, aspspId, "accounts/"+accountId, endpointAccounts, nil)
 }
 
-func (s service) Accounts(cid, aspspId string) (string, error) {
+func (s service) Accounts(ctx context.Context, cid, aspspId string) (string, error) {
+	ctx, span := tracing.Start(ctx, "accounts.Accounts", tracing.KindInternal)
+	defer span.End()
+
 	endpointAccounts, err := s.cfg.FindByConfigName(aspspId, api.EndpointAccounts)
 	if err != nil {
 		return "", errors.WithMessage(err, "error in Accounts()")
 	}
 
-	return s.processCall(cid, aspspId, endpointAccounts)
+	return s.processCall(ctx, cid, aspspId, "accounts", endpointAccounts, nil)
 }
 
-func (s service) processCall(cid, aspspId, endpointAccounts string) (string, error) {
-	resourceAccessToken, err := s.authManager.GetAuthorisedTokenByCid(aspspId, cid)
+func (s service) Balances(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	ctx, span := tracing.Start(ctx, "accounts.Balances", tracing.KindInternal)
+	defer span.End()
+
+	endpointAccounts, err := s.cfg.FindByConfigName(aspspId, api.EndpointAccounts)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in Balances()")
+	}
+	endpointAccounts = endpointAccounts + "/" + accountId + "/balances"
+
+	return s.processCall(ctx, cid, aspspId, "accounts/"+accountId+"/balances", endpointAccounts, nil)
+}
+
+func (s service) Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (string, error) {
+	ctx, span := tracing.Start(ctx, "accounts.Transactions", tracing.KindInternal)
+	defer span.End()
+
+	endpointAccounts, err := s.cfg.FindByConfigName(aspspId, api.EndpointAccounts)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in Transactions()")
+	}
+	endpointAccounts = endpointAccounts + "/" + accountId + "/transactions"
+
+	return s.processCall(ctx, cid, aspspId, "accounts/"+accountId+"/transactions", endpointAccounts, transactionParameters(from, to))
+}
+
+func (s service) TransactionPages(ctx context.Context, cid, aspspId, accountId string, from, to time.Time, fn func(payload string) error) error {
+	ctx, span := tracing.Start(ctx, "accounts.TransactionPages", tracing.KindInternal)
+	defer span.End()
+
+	endpointAccounts, err := s.cfg.FindByConfigName(aspspId, api.EndpointAccounts)
+	if err != nil {
+		return errors.WithMessage(err, "error in TransactionPages()")
+	}
+
+	//the next pages are part of the same request, so they aren't counted by the access limit again
+	resource := "accounts/" + accountId + "/transactions"
+	endpoint, parameters := endpointAccounts+"/"+accountId+"/transactions", transactionParameters(from, to)
+	for page := 1; ; page++ {
+		payload, err := s.processCall(ctx, cid, aspspId, resource, endpoint, parameters)
+		if err != nil {
+			return err
+		}
+		if err := fn(payload); err != nil {
+			return err
+		}
+
+		next, err := nextPage(endpointAccounts, payload)
+		if err != nil || next == nil {
+			return err
+		}
+		if page == maxTransactionPages {
+			return errors.WithMessage(errTooManyPages, "error in TransactionPages()")
+		}
+		endpoint, parameters, resource = next.String(), nil, ""
+	}
+}
+
+func transactionParameters(from, to time.Time) url.Values {
+	parameters := url.Values{}
+	if !from.IsZero() {
+		parameters.Set("fromBookingDateTime", from.Format(api.BookingDateTimeLayout))
+	}
+	if !to.IsZero() {
+		parameters.Set("toBookingDateTime", to.Format(api.BookingDateTimeLayout))
+	}
+
+	return parameters
+}
+
+//nextPage returns the Links.Next of the page, or nil on the last page. The link has to be on the host of
+//ENDPOINT_ACCOUNTS, as the resource access token is sent to it.
+func nextPage(endpointAccounts, payload string) (*url.URL, error) {
+	var page struct {
+		Links struct {
+			Next string `json:"Next"`
+		} `json:"Links"`
+	}
+	if err := json.Unmarshal([]byte(payload), &page); err != nil || page.Links.Next == "" {
+		return nil, nil
+	}
+
+	next, err := url.Parse(page.Links.Next)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in nextPage(). invalid Links.Next")
+	}
+	endpoint, err := url.Parse(endpointAccounts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in nextPage()")
+	}
+	next = endpoint.ResolveReference(next)
+	if next.Scheme != endpoint.Scheme || next.Host != endpoint.Host {
+		return nil, fmt.Errorf("error in nextPage(). Links.Next %v isn't on the host of %v", next, api.EndpointAccounts)
+	}
+
+	return next, nil
+}
+
+//processCall requests the resource, e.g. accounts/{accountId}/balances, from endpointAccounts. The requests made
+//without the PSU present are rejected once the resource has been requested the allowed times of the day.
+//The failed requests are released, so they don't spend the limit.
+//The resource is empty for the next pages of a request, which aren't limited.
+func (s service) processCall(ctx context.Context, cid, aspspId, resource, endpointAccounts string, parameters url.Values) (string, error) {
+	resourceAccessToken, err := s.authManager.GetAuthorisedTokenByCid(ctx, aspspId, cid)
 	if err != nil {
 		return "", err
 	}
@@ -58,33 +186,41 @@ func (s service) processCall(cid, aspspId, endpointAccounts string) (string, err
 		return "", err
 	}
 
-	httpClient, err := client.NewSecureHttpClient(endpointAccounts, s.setHeader(resourceAccessToken, fapiFinancialId))
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.EndpointAccounts, endpointAccounts, s.setHeader(ctx, resourceAccessToken, fapiFinancialId))
 	if err != nil {
 		return "", errors.WithMessage(err, "error in processCall()")
 	}
 
-	resp, err := httpClient.Get(nil)
+	release := func() {}
+	if resource != "" {
+		if release, err = s.accessLimit.Allow(ctx, cid, resource); err != nil {
+			return "", errors.WithMessage(err, "error in processCall()")
+		}
+	}
+
+	resp, err := httpClient.Get(ctx, parameters)
 	if err != nil {
+		release()
 		return "", errors.WithMessage(err, "error in processCall()")
 	}
+	client.RecordInteraction(ctx, resp)
 
 	switch resp.StatusCode {
 	case 200, 201:
 		return resp.Body, nil
 	//TODO case 401 is an anomaly and needs to be taken care either by revoking the consent or refreshing the token
 	default:
-		return "", fmt.Errorf("unexpected result from the accounts service. resp: %v", *resp)
+		//only the requests which the aspsp served are counted
+		release()
+		return "", errors.WithMessage(api.NewAspspError(resp.StatusCode, resp.Body), "error in processCall()")
 	}
 }
 
-func (s service) setHeader(resourceAccessToken, fapiFinancialId string) http.Header {
-	header := http.Header{}
-	header.Set(api.Accept, api.ApplicationJson)
+func (s service) setHeader(ctx context.Context, resourceAccessToken, fapiFinancialId string) http.Header {
+	header := client.NewFapiHeader(ctx, fapiFinancialId)
 	header.Set(api.Authorization, "Bearer "+resourceAccessToken)
 	header.Set(api.ContentType, api.ApplicationJson)
-	header.Set(api.CacheControl, "no-cache")
 	header.Set(api.XIdempotencyKey, uuid.New().String())
-	header.Set(api.XFapiFinancialId, fapiFinancialId)
 
 	return header
 }
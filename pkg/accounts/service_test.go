@@ -0,0 +1,175 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/pem"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configStub map[string]string
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if value, found := c[configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+type authManagerStub struct{}
+
+func (a authManagerStub) GetAuthorisedTokenByCid(ctx context.Context, aspspId, cid string) (string, error) {
+	return "access-token", nil
+}
+
+type accessLimitStub struct {
+	resources []string
+	released  []string
+}
+
+func (a *accessLimitStub) Allow(ctx context.Context, cid, resource string) (func(), error) {
+	a.resources = append(a.resources, resource)
+	return func() { a.released = append(a.released, resource) }, nil
+}
+
+func (a *accessLimitStub) Spent(ctx context.Context, cid, resource string) bool {
+	return false
+}
+
+//newAspsp serves the pages of the transactions over TLS. Each page links to the next one until the last.
+func newAspsp(t *testing.T, pages map[string]string) configStub {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, found := pages[r.URL.RequestURI()]
+		if !found || r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	t.Cleanup(server.Close)
+
+	caCert := filepath.Join(t.TempDir(), "server.cer")
+	if err := ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("couldn't write the server cert. err: %v", err)
+	}
+
+	return configStub{
+		"ENDPOINT_ACCOUNTS":   server.URL + "/accounts",
+		"FAPI_FINANCIAL_ID":   "financial",
+		"TLS_CA_CERT_PEM":     caCert,
+		"TLS_CLIENT_CERT_PEM": "../../internal/client/testdata/test_cert.pem",
+		"TLS_CLIENT_KEY_PEM":  "../../internal/client/testdata/test_key.pem",
+	}
+}
+
+func Test_service_TransactionPages(t *testing.T) {
+	pages := map[string]string{
+		"/accounts/a1/transactions?fromBookingDateTime=2021-01-01T00:00:00": `{"Data":{"Transaction":[]},"Links":{"Next":"/accounts/a1/transactions?cursor=a%2Bb"}}`,
+		"/accounts/a1/transactions?cursor=a%2Bb":                            `{"Data":{"Transaction":[]},"Links":{"Next":"/accounts/a1/transactions?page=3"}}`,
+		"/accounts/a1/transactions?page=3":                                  `{"Data":{"Transaction":[]},"Links":{"Self":"/accounts/a1/transactions?page=3"}}`,
+	}
+	cfg := newAspsp(t, pages)
+	accessLimit := &accessLimitStub{}
+	s := NewService(authManagerStub{}, cfg, accessLimit)
+
+	var got []string
+	err := s.TransactionPages(context.Background(), "1", "paged", "a1", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}, func(payload string) error {
+		got = append(got, payload)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TransactionPages() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("TransactionPages() pages = %v, want 3", len(got))
+	}
+	if len(accessLimit.resources) != 1 || accessLimit.resources[0] != "accounts/a1/transactions" {
+		t.Errorf("TransactionPages() limited resources = %v, want the first page only", accessLimit.resources)
+	}
+	if len(accessLimit.released) != 0 {
+		t.Errorf("TransactionPages() released = %v, want the served request kept", accessLimit.released)
+	}
+}
+
+func Test_service_releasesFailedRequests(t *testing.T) {
+	cfg := newAspsp(t, map[string]string{})
+	accessLimit := &accessLimitStub{}
+	s := NewService(authManagerStub{}, cfg, accessLimit)
+
+	if _, err := s.Balances(context.Background(), "1", "failed", "a1"); err == nil {
+		t.Fatalf("Balances() error = nil, want the 404 of the aspsp")
+	}
+	if len(accessLimit.released) != 1 || accessLimit.released[0] != "accounts/a1/balances" {
+		t.Errorf("Balances() released = %v, want the failed request released", accessLimit.released)
+	}
+}
+
+func Test_nextPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+		wantErr bool
+	}{
+		{"relative", `{"Links":{"Next":"/accounts/a1/transactions?page=2"}}`, "https://aspsp/accounts/a1/transactions?page=2", false},
+		{"absolute", `{"Links":{"Next":"https://aspsp/accounts/a1/transactions?page=2"}}`, "https://aspsp/accounts/a1/transactions?page=2", false},
+		{"last_page", `{"Links":{"Self":"https://aspsp/accounts/a1/transactions"}}`, "", false},
+		{"other_host", `{"Links":{"Next":"https://attacker/accounts/a1/transactions?page=2"}}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextPage("https://aspsp/accounts", tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil && tt.want != "") || (got != nil && got.String() != tt.want) {
+				t.Errorf("nextPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_statementReader_Statement(t *testing.T) {
+	transaction := func(id, bookingDateTime, amount string) string {
+		return `{"TransactionId":"` + id + `","CreditDebitIndicator":"Debit","Status":"Booked","BookingDateTime":"` + bookingDateTime +
+			`","Amount":{"Amount":"` + amount + `","Currency":"GBP"}}`
+	}
+	cfg := newAspsp(t, map[string]string{
+		"/accounts/a1": `{"Data":{"Account":[{"AccountId":"a1","Currency":"GBP","Account":[{"SchemeName":"UK.OBIE.SortCodeAccountNumber","Identification":"80200110203345"}]}]}}`,
+		"/accounts/a1/balances": `{"Data":{"Balance":[{"AccountId":"a1","Amount":{"Amount":"100.00","Currency":"GBP"},"CreditDebitIndicator":"Credit",
+			"Type":"InterimBooked","DateTime":"2021-01-10T00:00:00Z"}]}}`,
+		"/accounts/a1/transactions?fromBookingDateTime=2021-01-01T00:00:00": `{"Data":{"Transaction":[` + transaction("t3", "2021-01-09T10:00:00Z", "3.00") + `,` +
+			transaction("t2", "2021-01-08T10:00:00Z", "2.00") + `]},"Links":{"Next":"/accounts/a1/transactions?page=2"}}`,
+		"/accounts/a1/transactions?page=2": `{"Data":{"Transaction":[` + transaction("t1", "2021-01-07T10:00:00Z", "1.00") + `]}}`,
+	})
+	accessLimit := &accessLimitStub{}
+	reader := NewStatementReader(NewService(authManagerStub{}, cfg, accessLimit))
+
+	st, err := reader.Statement(context.Background(), "1", "statement", "a1", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatalf("Statement() error = %v", err)
+	}
+	if st.OpeningBooked == nil || st.OpeningBooked.Value.String() != "106.00" || st.ClosingBooked.Value.String() != "100.00" {
+		t.Errorf("Statement() opening = %v, closing = %v, want 106.00 and 100.00", st.OpeningBooked, st.ClosingBooked)
+	}
+
+	var ids []string
+	err = st.Transactions(func(t normalise.Transaction) error {
+		ids = append(ids, t.TransactionId)
+		return nil
+	})
+	if err != nil || strings.Join(ids, ",") != "t3,t2,t1" {
+		t.Errorf("Transactions() = %v, error = %v, want the transactions of both pages", ids, err)
+	}
+	if got := strings.Join(accessLimit.resources, ","); got != "accounts/a1,accounts/a1/balances,accounts/a1/transactions" {
+		t.Errorf("Statement() limited resources = %v, want the transactions fetched once", got)
+	}
+}
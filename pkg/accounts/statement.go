@@ -0,0 +1,209 @@
+package accounts
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//Statement is the account with its balances and transactions between From and To, which is exported in the statement formats.
+type Statement struct {
+	Account          normalise.Account
+	From             time.Time
+	To               time.Time
+	CreationDateTime time.Time
+	//OpeningBooked and ClosingBooked are the booked balances at From and To. They're nil if they can't be worked out,
+	//i.e. the period ends before the balance of the aspsp and the transactions have no balance.
+	OpeningBooked *normalise.Amount
+	ClosingBooked *normalise.Amount
+	//Available is the available balance of the aspsp. It's only set if the period ends after it.
+	Available *normalise.Balance
+	//Transactions are the booked and pending transactions of the period.
+	Transactions TransactionIterator
+	//Freshness is the freshness of the stored account data. It's nil for the statements of the aspsp.
+	Freshness *Freshness
+}
+
+//Freshness tells how old the stored data is. Stale data is still returned while it's synced again in the background,
+//which is reported with Syncing.
+type Freshness struct {
+	SyncDateTime time.Time `json:"syncDateTime"`
+	Stale        bool      `json:"stale"`
+	Syncing      bool      `json:"syncing"`
+}
+
+//TransactionIterator calls fn with the transactions and stops at the first error. The stored transactions are read
+//again from the db by each call, oldest first, so a long period isn't kept in memory. The ones of the aspsp are fetched
+//once and kept in the order of its pages.
+type TransactionIterator func(fn func(normalise.Transaction) error) error
+
+type StatementReader interface {
+	//Statement returns the statement of the account for the transactions booked between from and to.
+	//The zero from is the oldest transaction and the zero to is now.
+	Statement(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*Statement, error)
+}
+
+//NewStatement returns the statement of the account. The transactions are read once to find the period and the booked
+//balances, which are worked out back from the booked balance of the aspsp, or from the latest balance of the transactions.
+func NewStatement(account normalise.Account, balances []normalise.Balance, from, to, now time.Time, transactions TransactionIterator) (*Statement, error) {
+	st := &Statement{Account: account, From: from, To: to, CreationDateTime: now.UTC(), Transactions: transactions}
+	if st.To.IsZero() {
+		st.To = st.CreationDateTime
+	}
+
+	var booked normalise.Decimal
+	latest := latestBalance{}
+	err := transactions(func(t normalise.Transaction) error {
+		if st.From.IsZero() || t.BookingDateTime.Before(st.From) {
+			st.From = t.BookingDateTime
+		}
+		if t.Status == normalise.StatusPending {
+			return nil
+		}
+
+		booked = booked.Add(t.Amount.Value)
+		latest.add(t)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in NewStatement()")
+	}
+	if st.From.IsZero() {
+		st.From = st.To
+	}
+	st.From, st.To = st.From.UTC(), st.To.UTC()
+
+	switch current := currentBalance(balances, "ClosingBooked", "InterimBooked"); {
+	case latest.balance != nil:
+		st.ClosingBooked = latest.closing()
+	case current != nil && !st.To.Before(current.DateTime):
+		st.ClosingBooked = &current.Amount
+	}
+	if st.ClosingBooked != nil {
+		st.OpeningBooked = &normalise.Amount{Value: st.ClosingBooked.Value.Add(booked.Neg()), Currency: st.ClosingBooked.Currency}
+	}
+	if current := currentBalance(balances, "ClosingAvailable", "InterimAvailable"); current != nil && !st.To.Before(current.DateTime) {
+		st.Available = current
+	}
+
+	return st, nil
+}
+
+//latestBalance is the balance of the latest booked transaction which has one, and the booked amounts after it.
+//The transactions can come in any order, so the amounts which are after the latest balance so far are kept until
+//a later balance comes.
+type latestBalance struct {
+	bookingDateTime time.Time
+	balance         *normalise.Amount
+	after           []normalise.Transaction
+}
+
+func (l *latestBalance) add(t normalise.Transaction) {
+	if l.balance != nil && !t.BookingDateTime.After(l.bookingDateTime) {
+		return
+	}
+	if t.Balance == nil {
+		l.after = append(l.after, normalise.Transaction{BookingDateTime: t.BookingDateTime, Amount: t.Amount})
+		return
+	}
+
+	l.bookingDateTime, l.balance = t.BookingDateTime, t.Balance
+	after := l.after[:0]
+	for _, a := range l.after {
+		if a.BookingDateTime.After(l.bookingDateTime) {
+			after = append(after, a)
+		}
+	}
+	l.after = after
+}
+
+func (l latestBalance) closing() *normalise.Amount {
+	value := l.balance.Value
+	for _, a := range l.after {
+		value = value.Add(a.Amount.Value)
+	}
+
+	return &normalise.Amount{Value: value, Currency: l.balance.Currency}
+}
+
+//currentBalance returns the first balance of the types, in the order of the types.
+func currentBalance(balances []normalise.Balance, types ...string) *normalise.Balance {
+	for _, balanceType := range types {
+		for i := range balances {
+			if balances[i].Type == balanceType {
+				return &balances[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+type statementReader struct {
+	service Service
+	now     func() time.Time
+}
+
+//NewStatementReader returns the statements which are fetched from the aspsp.
+func NewStatementReader(service Service) StatementReader {
+	return &statementReader{service: service, now: time.Now}
+}
+
+func (r statementReader) Statement(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (*Statement, error) {
+	ctx, span := tracing.Start(ctx, "accounts.Statement", tracing.KindInternal)
+	defer span.End()
+
+	source := normalise.Source{AspspId: aspspId, Cid: cid}
+	payload, err := r.service.Account(ctx, cid, aspspId, accountId)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := normalise.Accounts(source, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, ErrAccountNotFound
+	}
+
+	payload, err = r.service.Balances(ctx, cid, aspspId, accountId)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := normalise.Balances(source, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	//the pages are fetched once, so the export counts once against the access limit and the balances are worked out
+	//from the same transactions as the ones which are written
+	var transactions []normalise.Transaction
+	occurrences := normalise.Occurrences{}
+	err = r.service.TransactionPages(ctx, cid, aspspId, accountId, from, to, func(payload string) error {
+		page, err := normalise.Transactions(source, payload)
+		if err != nil {
+			return err
+		}
+		for _, t := range page {
+			occurrences.Number(&t)
+			if (from.IsZero() || !t.BookingDateTime.Before(from)) && (to.IsZero() || !t.BookingDateTime.After(to)) {
+				transactions = append(transactions, t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStatement(accounts[0], balances, from, to, r.now(), func(fn func(normalise.Transaction) error) error {
+		for _, t := range transactions {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package admin
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+//RegisterHandler registers the circuit states of the aspsps, which are only served to the admin tokens.
+func RegisterHandler(e *echo.Echo) {
+	g := e.Group("/admin/circuits", configmanager.RequireAdmin())
+	g.GET("", getCircuits())
+}
+
+func getCircuits() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, client.CircuitStates())
+	}
+}
@@ -0,0 +1,34 @@
+package aggregate
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/session"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+//RegisterHandler registers the aggregated views, which are only served by v2 since v1 has no typed responses.
+func RegisterHandler(e *echo.Echo, sessionService session.Service, service Service) {
+	e.GET("/v2/internal/aggregate/accounts", aggregateAccounts(sessionService, service))
+}
+
+func aggregateAccounts(sessionService session.Service, service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bearerToken, err := api.BearerToken(c.Request().Header.Get(api.Authorization))
+		if err != nil {
+			return err
+		}
+
+		sessionData, err := sessionService.FindByInternalAccessToken(c.Request().Context(), bearerToken)
+		if err != nil {
+			return err
+		}
+
+		res, err := service.Accounts(c.Request().Context(), sessionData.UserId, sessionData.TppId)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,134 @@
+package aggregate
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/accounts"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+	"strconv"
+	"sync"
+)
+
+type Service interface {
+	//Accounts reads the accounts and the balances of every active consent of the PSU concurrently.
+	//Only the failure of finding the consents is returned as an error, the failures of the aspsps are reported in Accounts.
+	Accounts(ctx context.Context, userId, tppId string) (*Accounts, error)
+}
+
+type service struct {
+	consentService consent.ServiceRead
+	accountService accounts.Service
+}
+
+func NewService(consentService consent.ServiceRead, accountService accounts.Service) Service {
+	return &service{
+		consentService: consentService,
+		accountService: accountService,
+	}
+}
+
+//consentResult is the outcome of the calls of a consent.
+type consentResult struct {
+	accounts []Account
+	failures []Failure
+}
+
+func (s service) Accounts(ctx context.Context, userId, tppId string) (*Accounts, error) {
+	ctx, span := tracing.Start(ctx, "aggregate.Accounts", tracing.KindInternal)
+	defer span.End()
+
+	consents, err := s.consentService.FindAuthorisedConsentByUserIdAndTppId(ctx, userId, tppId)
+	if err != nil {
+		return nil, err
+	}
+	if len(consents) == 0 {
+		return nil, consent.ErrConsentNotFound
+	}
+
+	//each consent writes its own result, so the merged view keeps the order of the consents
+	results := make([]consentResult, len(consents))
+	var wg sync.WaitGroup
+	for i, c := range consents {
+		wg.Add(1)
+		go func(i int, source normalise.Source) {
+			defer wg.Done()
+			results[i] = s.consentAccounts(client.ForkInteraction(ctx), source)
+		}(i, normalise.Source{AspspId: c.AspspId, Cid: strconv.FormatInt(c.ConsentTid, 10)})
+	}
+	wg.Wait()
+
+	merged := &Accounts{Accounts: []Account{}, Failures: []Failure{}}
+	for _, result := range results {
+		merged.Accounts = append(merged.Accounts, result.accounts...)
+		merged.Failures = append(merged.Failures, result.failures...)
+	}
+
+	return merged, nil
+}
+
+//consentAccounts reads the accounts of a consent, and then the balances of each account concurrently.
+//The accounts are read first, so the resource access token is refreshed once and the balances find it in the cache.
+func (s service) consentAccounts(ctx context.Context, source normalise.Source) consentResult {
+	payload, err := s.accountService.Accounts(ctx, source.Cid, source.AspspId)
+	if err != nil {
+		return consentResult{failures: []Failure{failure(source, "", err)}}
+	}
+	normalised, err := normalise.Accounts(source, payload)
+	if err != nil {
+		return consentResult{failures: []Failure{failure(source, "", err)}}
+	}
+
+	result := consentResult{accounts: make([]Account, len(normalised))}
+	failures := make([]*Failure, len(normalised))
+	var wg sync.WaitGroup
+	for i, account := range normalised {
+		result.accounts[i] = Account{Account: account, Balances: []normalise.Balance{}}
+		wg.Add(1)
+		go func(i int, accountId string) {
+			defer wg.Done()
+			balances, err := s.balances(client.ForkInteraction(ctx), source, accountId)
+			if err != nil {
+				f := failure(source, accountId, err)
+				failures[i] = &f
+				return
+			}
+			result.accounts[i].Balances = balances
+		}(i, account.AccountId)
+	}
+	wg.Wait()
+
+	for _, f := range failures {
+		if f != nil {
+			result.failures = append(result.failures, *f)
+		}
+	}
+
+	return result
+}
+
+func (s service) balances(ctx context.Context, source normalise.Source, accountId string) ([]normalise.Balance, error) {
+	payload, err := s.accountService.Balances(ctx, source.Cid, source.AspspId, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalise.Balances(source, payload)
+}
+
+//failure reports err as it'd be sent by the aspsp's own endpoint. The cause is only logged.
+func failure(source normalise.Source, accountId string, err error) Failure {
+	e := api.AsError(err)
+	logger.Warn("aggregated call failed", logger.String("aspspId", source.AspspId), logger.String("cid", source.Cid),
+		logger.String("accountId", accountId), logger.Err(err))
+
+	return Failure{
+		AspspId:   source.AspspId,
+		Cid:       source.Cid,
+		AccountId: accountId,
+		Error:     e.Response(""),
+	}
+}
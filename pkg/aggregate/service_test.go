@@ -0,0 +1,107 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type consentStub struct {
+	consent.ServiceRead
+	consents []consent.ActiveConsent
+	err      error
+}
+
+func (c *consentStub) FindAuthorisedConsentByUserIdAndTppId(ctx context.Context, userId, tppId string) ([]consent.ActiveConsent, error) {
+	return c.consents, c.err
+}
+
+//accountStub returns the payloads by aspspId and by aspspId/accountId. It waits until every consent has called
+//Accounts, so it fails the test if the consents aren't called concurrently.
+type accountStub struct {
+	payloads map[string]string
+	errs     map[string]error
+	started  sync.WaitGroup
+}
+
+func (a *accountStub) Account(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (a *accountStub) Accounts(ctx context.Context, cid, aspspId string) (string, error) {
+	a.started.Done()
+	a.started.Wait()
+	return a.payloads[aspspId], a.errs[aspspId]
+}
+
+func (a *accountStub) Balances(ctx context.Context, cid, aspspId, accountId string) (string, error) {
+	client.InteractionFrom(ctx).Cid = cid
+	return a.payloads[aspspId+"/"+accountId], a.errs[aspspId+"/"+accountId]
+}
+
+func (a *accountStub) Transactions(ctx context.Context, cid, aspspId, accountId string, from, to time.Time) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (a *accountStub) TransactionPages(ctx context.Context, cid, aspspId, accountId string, from, to time.Time, fn func(payload string) error) error {
+	return errors.New("not implemented")
+}
+
+func Test_service_Accounts(t *testing.T) {
+	accountStub := &accountStub{
+		payloads: map[string]string{
+			"danske":    `{"Data":{"Account":[{"AccountId":"d1","Currency":"GBP"},{"AccountId":"d2","Currency":"GBP"}]}}`,
+			"danske/d1": `{"Data":{"Balance":[{"AccountId":"d1","Amount":{"Amount":"10.00","Currency":"GBP"},"CreditDebitIndicator":"Credit","Type":"InterimAvailable","DateTime":"2021-01-01T00:00:00Z"}]}}`,
+			"ozone":     `{"Data":{"Account":[{"AccountId":"o1","Currency":"EUR"}]}}`,
+		},
+		errs: map[string]error{
+			"danske/d2":   api.NewAspspError(http.StatusInternalServerError, ""),
+			"ozone/o1":    api.NewAspspError(http.StatusNotFound, ""),
+			"unavailable": &client.UnavailableError{AspspId: "unavailable", Reason: "circuit is open"},
+		},
+	}
+	consentStub := &consentStub{consents: []consent.ActiveConsent{{ConsentTid: 1, AspspId: "danske"}, {ConsentTid: 2, AspspId: "ozone"}, {ConsentTid: 3, AspspId: "unavailable"}}}
+	accountStub.started.Add(len(consentStub.consents))
+
+	got, err := NewService(consentStub, accountStub).Accounts(context.Background(), "user-1", "tpp-1")
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+
+	if len(got.Accounts) != 3 || got.Accounts[0].AccountId != "d1" || got.Accounts[1].AccountId != "d2" || got.Accounts[2].AccountId != "o1" {
+		t.Fatalf("Accounts() accounts = %+v", got.Accounts)
+	}
+	if len(got.Accounts[0].Balances) != 1 || got.Accounts[0].Balances[0].Amount.Value.String() != "10.00" || got.Accounts[0].Cid != "1" {
+		t.Errorf("Accounts() d1 = %+v", got.Accounts[0])
+	}
+	if got.Accounts[1].Balances == nil || len(got.Accounts[1].Balances) != 0 {
+		t.Errorf("Accounts() d2 balances = %+v, want empty", got.Accounts[1].Balances)
+	}
+
+	want := []Failure{
+		{AspspId: "danske", Cid: "1", AccountId: "d2", Error: &api.ErrorResponse{Code: "502 BadGateway"}},
+		{AspspId: "ozone", Cid: "2", AccountId: "o1", Error: &api.ErrorResponse{Code: "404 NotFound"}},
+		{AspspId: "unavailable", Cid: "3", Error: &api.ErrorResponse{Code: "503 ServiceUnavailable"}},
+	}
+	if len(got.Failures) != len(want) {
+		t.Fatalf("Accounts() failures = %+v, want %+v", got.Failures, want)
+	}
+	for i, f := range got.Failures {
+		if f.AspspId != want[i].AspspId || f.Cid != want[i].Cid || f.AccountId != want[i].AccountId || f.Error.Code != want[i].Error.Code {
+			t.Errorf("Accounts() failure = %+v, want %+v", f, want[i])
+		}
+	}
+}
+
+func Test_service_Accounts_noConsent(t *testing.T) {
+	_, err := NewService(&consentStub{}, &accountStub{}).Accounts(context.Background(), "user-1", "tpp-1")
+	if !errors.Is(err, consent.ErrConsentNotFound) {
+		t.Errorf("Accounts() error = %v, want %v", err, consent.ErrConsentNotFound)
+	}
+}
@@ -0,0 +1,26 @@
+package aggregate
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/normalise"
+)
+
+//Accounts is the merged view of the accounts of a PSU at every aspsp it has an active consent with.
+//The aspsps or the accounts which couldn't be read are listed in Failures, and the others are still returned.
+type Accounts struct {
+	Accounts []Account `json:"accounts"`
+	Failures []Failure `json:"failures"`
+}
+
+type Account struct {
+	normalise.Account
+	Balances []normalise.Balance `json:"balances"`
+}
+
+//Failure is a failed call of a consent. AccountId is set if only the balances of the account couldn't be read.
+type Failure struct {
+	AspspId   string             `json:"aspspId"`
+	Cid       string             `json:"cid"`
+	AccountId string             `json:"accountId,omitempty"`
+	Error     *api.ErrorResponse `json:"error"`
+}
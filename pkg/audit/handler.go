@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//RegisterHandler registers the audit trail, which is only served to the admin tokens since it has the outbound
+//requests of every tpp.
+func RegisterHandler(e *echo.Echo, service Service) {
+	g := e.Group("/admin/audit", configmanager.RequireAdmin())
+	g.GET("", findAudits(service))
+}
+
+func findAudits(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filter := Filter{
+			AspspId:       c.QueryParam("aspspId"),
+			Cid:           c.QueryParam("cid"),
+			InteractionId: c.QueryParam("interactionId"),
+		}
+
+		var err error
+		if value := c.QueryParam("from"); value != "" {
+			if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+				return api.NewFieldError(api.FieldInvalid, "from", "from should be in RFC3339 format")
+			}
+		}
+		if value := c.QueryParam("to"); value != "" {
+			if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+				return api.NewFieldError(api.FieldInvalid, "to", "to should be in RFC3339 format")
+			}
+		}
+		if value := c.QueryParam("limit"); value != "" {
+			if filter.Limit, err = strconv.Atoi(value); err != nil {
+				return api.NewFieldError(api.FieldInvalid, "limit", "limit should be a number")
+			}
+		}
+
+		audits, err := service.FindAudits(filter)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, audits)
+	}
+}
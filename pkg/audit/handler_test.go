@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"context"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{name: "admin", claims: jwt.MapClaims{"role": "admin", "sub": "operator"}, want: http.StatusOK},
+		{name: "tpp", claims: jwt.MapClaims{"role": "tpp", "sub": "tpp"}, want: http.StatusForbidden},
+		{name: "no role", claims: jwt.MapClaims{"sub": "tpp"}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewService(&repositoryStub{})
+			defer service.Stop(context.Background())
+
+			e := echo.New()
+			e.HTTPErrorHandler = func(err error, c echo.Context) {
+				_ = c.NoContent(api.AsError(err).Status)
+			}
+			e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.Set(api.JwtClaims, tt.claims)
+					return next(c)
+				}
+			})
+			RegisterHandler(e, service)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/audit", nil))
+			if rec.Code != tt.want {
+				t.Errorf("GET /admin/audit status = %v, want %v", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+type Repository interface {
+	saveAudit(audit *Audit) error
+	findAudits(filter Filter) ([]Audit, error)
+	deleteOlderThan(t time.Time) (int64, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r repository) saveAudit(audit *Audit) error {
+	_, err := r.db.NamedExec(`INSERT INTO audit_table(aspsp_id, cid, interaction_id, method, url, request_header, request_body, status_code, response_body, latency_ms, error, create_date_time) 
+												VALUES (:aspsp_id, :cid, :interaction_id, :method, :url, :request_header, :request_body, :status_code, :response_body, :latency_ms, :error, :create_date_time)`, audit)
+	if err != nil {
+		return errors.WithMessage(err, "error in saveAudit()")
+	}
+
+	return nil
+}
+
+func (r repository) findAudits(filter Filter) ([]Audit, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AspspId != "" {
+		addCondition("aspsp_id=$%d", filter.AspspId)
+	}
+	if filter.Cid != "" {
+		addCondition("cid=$%d", filter.Cid)
+	}
+	if filter.InteractionId != "" {
+		addCondition("interaction_id=$%d", filter.InteractionId)
+	}
+	if !filter.From.IsZero() {
+		addCondition("create_date_time>=$%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("create_date_time<=$%d", filter.To)
+	}
+
+	query := "SELECT * from audit_table"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY create_date_time DESC, id DESC LIMIT $%d", len(args))
+
+	var audits []Audit
+	if err := r.db.Select(&audits, query, args...); err != nil {
+		return nil, errors.WithMessage(err, "error in findAudits()")
+	}
+
+	return audits, nil
+}
+
+func (r repository) deleteOlderThan(t time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE from audit_table where create_date_time<$1", t)
+	if err != nil {
+		return 0, errors.WithMessage(err, "error in deleteOlderThan()")
+	}
+
+	return result.RowsAffected()
+}
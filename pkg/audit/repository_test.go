@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
+	"testing"
+	"time"
+)
+
+func Test_repository_saveAndFindAudits(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection())
+
+	interactionId := uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Second)
+	audits := []*Audit{
+		{AspspId: "danske", Cid: "10", InteractionId: interactionId, Method: "GET", Url: "https://aspsp/accounts", StatusCode: 200, CreateDateTime: now.Add(-time.Hour)},
+		{AspspId: "danske", Cid: "10", InteractionId: interactionId, Method: "GET", Url: "https://aspsp/accounts", StatusCode: 503, CreateDateTime: now},
+		{AspspId: "danske", Cid: "10", InteractionId: interactionId, Method: "GET", Url: "https://aspsp/accounts", StatusCode: 200, CreateDateTime: now.AddDate(0, 0, -100)},
+	}
+	for _, audit := range audits {
+		if err := repo.saveAudit(audit); err != nil {
+			t.Fatalf("saveAudit() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		filter     Filter
+		wantCount  int
+		wantStatus int
+	}{
+		{"find_by_interaction_id_latest_first", Filter{InteractionId: interactionId, Limit: 10}, 3, 503},
+		{"find_by_date_range", Filter{InteractionId: interactionId, From: now.Add(-2 * time.Hour), To: now.Add(-time.Minute), Limit: 10}, 1, 200},
+		{"limit", Filter{InteractionId: interactionId, Limit: 1}, 1, 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.findAudits(tt.filter)
+			if err != nil {
+				t.Fatalf("findAudits() error = %v", err)
+			}
+			if len(got) != tt.wantCount || got[0].StatusCode != tt.wantStatus {
+				t.Errorf("findAudits() got = %v, want %v entries with first status %v", got, tt.wantCount, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, err := repo.deleteOlderThan(now.AddDate(0, 0, -90)); err != nil {
+		t.Fatalf("deleteOlderThan() error = %v", err)
+	}
+	got, err := repo.findAudits(Filter{InteractionId: interactionId, Limit: 10})
+	if err != nil || len(got) != 2 {
+		t.Errorf("deleteOlderThan() should delete the entries older than the retention. got = %v, err = %v", got, err)
+	}
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+	queueSize    = 1000
+)
+
+type Service interface {
+	//Record queues the entry of an outbound request. It is dropped if the queue is full, so a slow database
+	//never holds the calls to the aspsps.
+	Record(entry client.AuditEntry)
+	FindAudits(filter Filter) ([]Audit, error)
+	//Rotate deletes the entries older than retention.
+	Rotate(retention time.Duration) (int64, error)
+	StartRotation(retention, interval time.Duration)
+	//Stop stops the rotation and saves the queued entries. The entries recorded after Stop are dropped.
+	Stop(ctx context.Context) error
+}
+
+type service struct {
+	repo      Repository
+	queue     chan client.AuditEntry
+	stop      chan struct{}
+	persisted chan struct{}
+	stopOnce  *sync.Once
+}
+
+func NewService(r Repository) Service {
+	s := &service{
+		repo:      r,
+		queue:     make(chan client.AuditEntry, queueSize),
+		stop:      make(chan struct{}),
+		persisted: make(chan struct{}),
+		stopOnce:  &sync.Once{},
+	}
+	go s.persist()
+
+	return s
+}
+
+func (s service) Record(entry client.AuditEntry) {
+	select {
+	case s.queue <- entry:
+	default:
+		logger.Warn("audit queue is full. entry is dropped", logger.String("aspspId", entry.AspspId), logger.String("interactionId", entry.InteractionId))
+	}
+}
+
+func (s service) persist() {
+	defer close(s.persisted)
+
+	for {
+		select {
+		case entry := <-s.queue:
+			s.save(entry)
+		case <-s.stop:
+			for {
+				select {
+				case entry := <-s.queue:
+					s.save(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s service) save(entry client.AuditEntry) {
+	audit := &Audit{
+		AspspId:        entry.AspspId,
+		Cid:            entry.Cid,
+		InteractionId:  entry.InteractionId,
+		Method:         entry.Method,
+		Url:            entry.Url,
+		RequestHeader:  entry.RequestHeader,
+		RequestBody:    entry.RequestBody,
+		StatusCode:     entry.StatusCode,
+		ResponseBody:   entry.ResponseBody,
+		LatencyMs:      entry.LatencyMs,
+		Error:          entry.Error,
+		CreateDateTime: entry.CreateDateTime.UTC(),
+	}
+	if err := s.repo.saveAudit(audit); err != nil {
+		logger.Error("couldn't save the audit entry", logger.String("interactionId", entry.InteractionId), logger.Err(err))
+	}
+}
+
+func (s service) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	select {
+	case <-s.persisted:
+		return nil
+	case <-ctx.Done():
+		return errors.WithMessage(ctx.Err(), "error in Stop(). queued audit entries couldn't be saved")
+	}
+}
+
+func (s service) FindAudits(filter Filter) ([]Audit, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	} else if filter.Limit > maxLimit {
+		filter.Limit = maxLimit
+	}
+	//create_date_time is kept in UTC
+	filter.From, filter.To = filter.From.UTC(), filter.To.UTC()
+
+	audits, err := s.repo.findAudits(filter)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in FindAudits()")
+	}
+
+	return audits, nil
+}
+
+func (s service) Rotate(retention time.Duration) (int64, error) {
+	deleted, err := s.repo.deleteOlderThan(time.Now().UTC().Add(-retention))
+	if err != nil {
+		return 0, errors.WithMessage(err, "error in Rotate()")
+	}
+
+	return deleted, nil
+}
+
+func (s service) StartRotation(retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if deleted, err := s.Rotate(retention); err != nil {
+				logger.Error("couldn't rotate the audit entries", logger.Err(err))
+			} else if deleted > 0 {
+				logger.Info("old audit entries are deleted", logger.Any("deleted", deleted), logger.String("retention", retention.String()))
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
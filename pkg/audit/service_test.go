@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"sync"
+	"testing"
+	"time"
+)
+
+type repositoryStub struct {
+	mu    sync.Mutex
+	saved []*Audit
+}
+
+func (r *repositoryStub) saveAudit(audit *Audit) error {
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = append(r.saved, audit)
+	return nil
+}
+
+func (r *repositoryStub) findAudits(filter Filter) ([]Audit, error) {
+	return nil, nil
+}
+
+func (r *repositoryStub) deleteOlderThan(t time.Time) (int64, error) {
+	return 0, nil
+}
+
+func Test_service_Stop(t *testing.T) {
+	repo := &repositoryStub{}
+	s := NewService(repo)
+	s.StartRotation(time.Hour, time.Hour)
+
+	for i := 0; i < 50; i++ {
+		s.Record(client.AuditEntry{AspspId: "test", CreateDateTime: time.Now()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.saved) != 50 {
+		t.Errorf("Stop() saved %v entries, want 50", len(repo.saved))
+	}
+}
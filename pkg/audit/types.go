@@ -0,0 +1,29 @@
+package audit
+
+import "time"
+
+type Audit struct {
+	Id             int64     `db:"id" json:"id"`
+	AspspId        string    `db:"aspsp_id" json:"aspspId"`
+	Cid            string    `db:"cid" json:"cid"`
+	InteractionId  string    `db:"interaction_id" json:"interactionId"`
+	Method         string    `db:"method" json:"method"`
+	Url            string    `db:"url" json:"url"`
+	RequestHeader  string    `db:"request_header" json:"requestHeader"`
+	RequestBody    string    `db:"request_body" json:"requestBody"`
+	StatusCode     int       `db:"status_code" json:"statusCode"`
+	ResponseBody   string    `db:"response_body" json:"responseBody"`
+	LatencyMs      int64     `db:"latency_ms" json:"latencyMs"`
+	Error          string    `db:"error" json:"error"`
+	CreateDateTime time.Time `db:"create_date_time" json:"createDateTime"`
+}
+
+//Filter of the audit query. Empty fields aren't filtered.
+type Filter struct {
+	AspspId       string
+	Cid           string
+	InteractionId string
+	From          time.Time
+	To            time.Time
+	Limit         int
+}
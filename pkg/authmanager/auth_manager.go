@@ -1,18 +1,27 @@
 package authmanager
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
-	"github.com/labstack/gommon/log"
 	"github.com/pkg/errors"
+	"net/http"
 	"time"
 )
 
+//ErrConsentRevoked is returned when the consent has expired or doesn't have an authorised token. The consent is revoked.
+var ErrConsentRevoked = api.NewError(http.StatusBadRequest, api.ResourceInvalidConsentStatus, "consent has expired or doesn't have an authorised token. it has been revoked")
+
 type AuthManager interface {
-	GetAuthorisedTokenByCid(aspspId, cid string) (string, error)
+	GetAuthorisedTokenByCid(ctx context.Context, aspspId, cid string) (string, error)
 }
 
 type authManager struct {
@@ -31,15 +40,30 @@ func NewAuthManager(consentServiceRead consent.ServiceRead, consentServiceWrite
 	}
 }
 
-func (s authManager) GetAuthorisedTokenByCid(aspspId, cid string) (string, error) {
+//GetAuthorisedTokenByCid returns a valid resource access token of the consent and refreshes it if it's expired.
+//The last PSU authorisation time of the consent is set on the request's interaction for x-fapi-auth-date.
+func (s authManager) GetAuthorisedTokenByCid(ctx context.Context, aspspId, cid string) (string, error) {
+	ctx, span := tracing.Start(ctx, "authmanager.GetAuthorisedTokenByCid", tracing.KindInternal)
+	defer span.End()
+
+	client.InteractionFrom(ctx).Cid = cid
 	if value, found := s.chRedis.Get(cid); found {
+		if authDate, found := s.chRedis.Get(AuthDateCacheId(cid)); found {
+			setAuthDate(ctx, authDate.(string))
+		}
 		return value.(string), nil
 	}
 
-	consentResp, err := s.consentServiceRead.FindConsentByCidAndStatus(cid, api.Authorised)
-	if err != nil || consentResp.AspspId != aspspId {
+	consentResp, err := s.consentServiceRead.FindConsentByCidAndStatus(ctx, cid, api.Authorised)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", consent.ErrConsentNotFound.Wrap(err)
+	} else if err != nil {
 		return "", errors.WithMessagef(err, "couldn't retrieve the consentResp. cid: %v aspspId: %v", cid, aspspId)
 	}
+	if consentResp.AspspId != aspspId {
+		return "", consent.ErrConsentNotFound.Wrap(fmt.Errorf("consent doesn't belong to the aspsp. cid: %v aspspId: %v", cid, aspspId))
+	}
+	setAuthDate(ctx, consentResp.ConsentStatusUpdateDateTime)
 
 	var consentExpirationDateTime time.Time
 	consentExpirationDateTime, err = time.Parse(time.RFC3339, consentResp.ConsentExpirationDateTime)
@@ -49,12 +73,14 @@ func (s authManager) GetAuthorisedTokenByCid(aspspId, cid string) (string, error
 
 	//if no authorised authorisedToken, revoke consentResp
 	if consentExpirationDateTime.Before(time.Now()) || consentResp.Tokens == nil || len(consentResp.Tokens) < 1 {
-		err := s.consentServiceWrite.ChangeConsentStateByCid(cid, api.Revoked)
+		err := s.consentServiceWrite.ChangeConsentStateByCid(ctx, cid, api.Revoked)
 		if err != nil {
-			log.Errorf("unexpected error while revoking the consentResp for cid: %v. consentResp revoking will be tried with the new request. err: %v", cid, err)
+			logger.Error("unexpected error while revoking the consent. it will be tried with the next request", logger.String("cid", cid), logger.Err(err))
+		} else {
+			consent.RecordStatusTransition(api.Authorised, api.Revoked)
 		}
 
-		return "", fmt.Errorf("consentResp expired or doesn't have authorised token, it has been revoked. cid: %v", cid)
+		return "", ErrConsentRevoked.Wrap(fmt.Errorf("cid: %v", cid))
 	}
 
 	authorisedToken := consentResp.Tokens[0]
@@ -64,23 +90,26 @@ func (s authManager) GetAuthorisedTokenByCid(aspspId, cid string) (string, error
 		return "", errors.WithMessage(err, "error in GetAuthorisedTokenByCid()")
 	}
 
-	log.Infof("Checking Resource Token expiry time. resourceAccessToken: %v, consentId: %v", *authorisedToken.ResourceAccessToken, consentResp.ConsentId)
+	logger.Debug("checking resource token expiry time", logger.String("cid", cid), logger.String("consentId", consentResp.ConsentId))
 	timeNow := time.Now()
 	if tokenExpirationDateTime.After(timeNow) {
 		//token is valid. cache it again then return the resource access token
 		tokenExpiresInSecond := tokenExpirationDateTime.Sub(timeNow).Seconds()
 		_ = s.chRedis.Set(cid, *authorisedToken.ResourceAccessToken, time.Duration(tokenExpiresInSecond))
+		_ = s.chRedis.Set(AuthDateCacheId(cid), consentResp.ConsentStatusUpdateDateTime, time.Duration(tokenExpiresInSecond))
 		return *authorisedToken.ResourceAccessToken, nil
 	} else {
-		log.Infof("Resource token has been expired. resourceAccessToken: %v. Requesting a new resource token for the existing consentResp. resourceRefreshToken: %v",
-			*authorisedToken.ResourceAccessToken, *authorisedToken.ResourceRefreshToken)
+		logger.Info("resource token has been expired. requesting a new resource token for the existing consent",
+			logger.String("cid", cid), logger.String("consentId", consentResp.ConsentId))
 
 		refreshToken := *authorisedToken.ResourceRefreshToken
 		//authorisedToken expired call refresh authorisedToken
-		tokenResp, err := s.tokenService.RefreshAccessToken(aspspId, api.ScopeAccounts, refreshToken)
+		tokenResp, err := s.tokenService.RefreshAccessToken(ctx, aspspId, api.ScopeAccounts, refreshToken)
 		if err != nil {
+			tokenRefreshes.Inc(aspspId, "failure")
 			return "", errors.WithMessage(err, "error in GetAuthorisedTokenByCid()")
 		}
+		tokenRefreshes.Inc(aspspId, "success")
 
 		tokenExpiresInSecond := tokenResp.ExpiresIn - 300
 		tokenExpirationDateTime := api.ObTime(time.Now().Add(time.Second * time.Duration(tokenExpiresInSecond)))
@@ -99,26 +128,41 @@ func (s authManager) GetAuthorisedTokenByCid(aspspId, cid string) (string, error
 			ConsentTid:              authorisedToken.ConsentTid,
 		}
 
-		err = s.consentServiceWrite.InvalidateAuthorisedTokenByConsentTid(*authorisedToken.ConsentTid, api.Expired)
+		err = s.consentServiceWrite.InvalidateAuthorisedTokenByConsentTid(ctx, *authorisedToken.ConsentTid, api.Expired)
 		if err != nil {
 			return "", errors.WithMessage(err, "error in GetAuthorisedTokenByCid()")
 		}
 
-		log.Info("All authorised tokens set to EXPIRED. consentTid:", *authorisedToken.ConsentTid)
-		err = s.consentServiceWrite.SaveToken(newToken)
+		logger.Info("all authorised tokens set to EXPIRED", logger.Any("consentTid", *authorisedToken.ConsentTid))
+		err = s.consentServiceWrite.SaveToken(ctx, newToken)
 		if err != nil {
 			return "", errors.WithMessage(err, "error in GetAuthorisedTokenByCid()")
 		}
 
-		log.Info("Resource access token refreshed successfully. refreshAccessToken:", tokenResp.AccessToken)
+		logger.Info("resource access token refreshed successfully", logger.String("cid", cid))
 
 		err = s.chRedis.Set(cid, tokenResp.AccessToken, time.Duration(tokenExpiresInSecond))
+		_ = s.chRedis.Set(AuthDateCacheId(cid), consentResp.ConsentStatusUpdateDateTime, time.Duration(tokenExpiresInSecond))
 		if err == nil {
-			log.Info("Resource access token cached successfully")
+			logger.Debug("resource access token cached successfully", logger.String("cid", cid))
 		} else {
-			log.Error("Resource access token couldn't be cached. this will be tried again with the next request")
+			logger.Error("resource access token couldn't be cached. this will be tried again with the next request", logger.String("cid", cid), logger.Err(err))
 		}
 
 		return tokenResp.AccessToken, nil
 	}
 }
+
+var tokenRefreshes = metrics.NewCounterVec("token_refresh_total",
+	"Resource access token refreshes by result.", "aspsp_id", "result")
+
+//AuthDateCacheId is the cache id of the consent's last PSU authorisation time, which is cached next to its token.
+func AuthDateCacheId(cid string) string {
+	return cid + "_auth_date"
+}
+
+func setAuthDate(ctx context.Context, authDate string) {
+	if authDateTime, err := time.Parse(time.RFC3339, authDate); err == nil {
+		client.InteractionFrom(ctx).AuthDate = authDateTime
+	}
+}
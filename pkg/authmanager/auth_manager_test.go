@@ -1,6 +1,7 @@
 package authmanager
 
 import (
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
@@ -33,7 +34,7 @@ func Test_manager_GetAuthorisedTokenByCid(t *testing.T) {
 			fields{
 				serviceRead:  consent.NewServiceRead(consent.NewRepositoryRead(store.LoadDBConnection())),
 				serviceWrite: consent.NewServiceWrite(consent.NewRepositoryWrite(store.LoadDBConnection())),
-				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())),
+				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)),
 				ch:           cache.LoadInMemory(),
 			},
 			args{aspspId: "danske", cid: "1"},
@@ -45,7 +46,7 @@ func Test_manager_GetAuthorisedTokenByCid(t *testing.T) {
 			fields{
 				serviceRead:  consent.NewServiceRead(consent.NewRepositoryRead(store.LoadDBConnection())),
 				serviceWrite: consent.NewServiceWrite(consent.NewRepositoryWrite(store.LoadDBConnection())),
-				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())),
+				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)),
 				ch:           cache.LoadInMemory(),
 			},
 			args{aspspId: "danske", cid: "2"},
@@ -61,7 +62,7 @@ func Test_manager_GetAuthorisedTokenByCid(t *testing.T) {
 				tokenService:        tt.fields.tokenService,
 				chRedis:             tt.fields.ch,
 			}
-			got, err := s.GetAuthorisedTokenByCid(tt.args.aspspId, tt.args.cid)
+			got, err := s.GetAuthorisedTokenByCid(context.Background(), tt.args.aspspId, tt.args.cid)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FindAuthorisedTokenByCid() error = %v, wantErr %v", err, tt.wantErr)
 				return
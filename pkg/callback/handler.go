@@ -1,8 +1,7 @@
 package callback
 
 import (
-	"database/sql"
-	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/labstack/echo/v4"
 	"net/http"
 )
@@ -14,25 +13,18 @@ func RegisterHandler(e *echo.Echo, callbackService Service) {
 func processCallBack(service Service) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := c.QueryParam("code")
+		if code == "" {
+			return api.NewFieldError(api.FieldMissing, "code", "code can't be empty")
+		}
 		state := c.QueryParam("state")
+		if state == "" {
+			return api.NewFieldError(api.FieldMissing, "state", "state can't be empty")
+		}
 
-		var httpStatusCode int
-		var message string
-		err := service.ProcessCallBack(code, state)
-		if errors.Is(err, sql.ErrNoRows) {
-			httpStatusCode = http.StatusNotFound
-		} else {
-			switch err {
-			case sql.ErrNoRows:
-			case nil:
-				httpStatusCode = http.StatusOK
-				message = "Consent has been authorized successfully."
-			default:
-				httpStatusCode = http.StatusInternalServerError
-				message = "An unexpected error has occurred. " + err.Error()
-			}
+		if err := service.ProcessCallBack(c.Request().Context(), code, state); err != nil {
+			return err
 		}
 
-		return c.JSON(httpStatusCode, message)
+		return c.JSON(http.StatusOK, "Consent has been authorized successfully.")
 	}
 }
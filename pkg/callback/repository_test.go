@@ -1,6 +1,7 @@
 package callback
 
 import (
+	"context"
 	"github.com/jmoiron/sqlx"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
@@ -43,7 +44,7 @@ func Test_repository_saveResourceAccessAndRefreshToken(t *testing.T) {
 			r := repository{
 				db: tt.fields.db,
 			}
-			if err := r.saveResourceAccessAndRefreshToken(tt.args.parameters); (err != nil) != tt.wantErr {
+			if err := r.saveResourceAccessAndRefreshToken(context.Background(), tt.args.parameters); (err != nil) != tt.wantErr {
 				t.Errorf("saveResourceAccessAndRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -1,19 +1,28 @@
 package callback
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/authmanager"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/consent"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
-	"github.com/labstack/gommon/log"
 	"github.com/pkg/errors"
+	"net/http"
 	"strconv"
 	"time"
 )
 
+//ErrInvalidConsentStatus is returned when the consent of the callback isn't awaiting the authorisation, e.g. the code has been used.
+var ErrInvalidConsentStatus = api.NewError(http.StatusBadRequest, api.ResourceInvalidConsentStatus, "consent is not in AwaitingAuthorisation status")
+
 type Service interface {
-	ProcessCallBack(code, state string) error
+	ProcessCallBack(ctx context.Context, code, state string) error
 }
 
 type service struct {
@@ -32,18 +41,24 @@ func NewService(repository Repository, consentServiceRead consent.ServiceRead, t
 	}
 }
 
-func (s service) ProcessCallBack(code, state string) error {
-	cons, err := s.consentServiceRead.FindByTrackingId(state)
-	if err != nil {
-		return err
+func (s service) ProcessCallBack(ctx context.Context, code, state string) error {
+	ctx, span := tracing.Start(ctx, "callback.ProcessCallBack", tracing.KindInternal)
+	defer span.End()
+
+	cons, err := s.consentServiceRead.FindByTrackingId(ctx, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return consent.ErrConsentNotFound.Wrap(err)
+	} else if err != nil {
+		return errors.WithMessage(err, "error in ProcessCallBack()")
 	}
 
 	if cons.ConsentStatus == api.AwaitingAuthorisation {
 		if cons.Tokens == nil {
-			log.Errorf("Unsatisfied token list in Consent. referenceId: %v", code)
+			logger.Error("unsatisfied token list in consent", logger.String("referenceId", state))
 		}
 
-		tokenResponse, err := s.tokenService.GetResourceAccessRefreshToken(cons.AspspId, code)
+		client.InteractionFrom(ctx).Cid = strconv.FormatInt(cons.Id, 10)
+		tokenResponse, err := s.tokenService.GetResourceAccessRefreshToken(ctx, cons.AspspId, code)
 		if err != nil {
 			return errors.WithMessage(err, "error in ProcessCallBack()")
 		}
@@ -51,6 +66,7 @@ func (s service) ProcessCallBack(code, state string) error {
 		tokenExpiresInSecond := tokenResponse.ExpiresIn - 300
 		tokenExpirationDateTime := api.ObTime(time.Now().Add(time.Second * time.Duration(tokenExpiresInSecond)))
 
+		authDate := api.ObTime(time.Now())
 		updateParameters := map[string]interface{}{
 			"id":                      cons.Id,
 			"resourceAccessToken":     tokenResponse.AccessToken,
@@ -58,23 +74,26 @@ func (s service) ProcessCallBack(code, state string) error {
 			"status":                  api.Authorised,
 			"expiresIn":               tokenResponse.ExpiresIn,
 			"tokenExpirationDateTime": tokenExpirationDateTime,
-			"updateTime":              api.ObTime(time.Now()),
+			"updateTime":              authDate,
 		}
 
-		err = s.repository.saveResourceAccessAndRefreshToken(updateParameters)
+		err = s.repository.saveResourceAccessAndRefreshToken(ctx, updateParameters)
 		if err != nil {
 			return errors.WithMessage(err, "error in ProcessCallBack()")
 		}
 
-		log.Info("Resource access token has been saved successfully")
+		logger.Info("resource access token has been saved successfully", logger.Any("cid", cons.Id))
+		consent.RecordStatusTransition(cons.ConsentStatus, api.Authorised)
 
-		err = s.chInRedis.Set(strconv.FormatInt(cons.Id, 10), tokenResponse.AccessToken, time.Duration(tokenExpiresInSecond))
+		cid := strconv.FormatInt(cons.Id, 10)
+		err = s.chInRedis.Set(cid, tokenResponse.AccessToken, time.Duration(tokenExpiresInSecond))
+		_ = s.chInRedis.Set(authmanager.AuthDateCacheId(cid), authDate, time.Duration(tokenExpiresInSecond))
 		if err == nil {
-			log.Info("Resource access token has been cached successfully")
+			logger.Debug("resource access token has been cached successfully", logger.String("cid", cid))
 		}
 
 		return nil
 	} else {
-		return fmt.Errorf("consent is not in AwaitingAuthorisation status. referenceId: %v", state)
+		return ErrInvalidConsentStatus.Wrap(fmt.Errorf("referenceId: %v status: %v", state, cons.ConsentStatus))
 	}
 }
@@ -0,0 +1,67 @@
+package clientauth
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/pkg/errors"
+	"net/url"
+)
+
+const (
+	clientId            = "client_id"
+	clientAssertionType = "client_assertion_type"
+	clientAssertion     = "client_assertion"
+)
+
+//SetParameters sets the client authentication parameters of a token request for the aspsp's TOKEN_ENDPOINT_AUTH_METHOD.
+//tls_client_auth only needs client_id, private_key_jwt needs a client assertion for audience in addition.
+func SetParameters(cfg client.Config, aspspId, clientIdValue, audience string, parameters url.Values) error {
+	parameters.Set(clientId, clientIdValue)
+
+	authMethod, err := client.FindConfig(cfg, aspspId, api.TokenEndpointAuthMethod, "")
+	if err != nil {
+		return errors.WithMessage(err, "error in SetParameters()")
+	}
+	if authMethod != api.PrivateKeyJwt {
+		return nil
+	}
+
+	signingMethod, err := SigningMethod(cfg, aspspId)
+	if err != nil {
+		return errors.WithMessage(err, "error in SetParameters()")
+	}
+
+	claims := jwt.MapClaims{
+		"iss": clientIdValue,
+		"sub": clientIdValue,
+		"aud": audience,
+		"jti": uuid.New().String(),
+		"iat": security.CreateTokenTime(0),
+		"exp": security.CreateTokenTime(5),
+	}
+	assertion, err := security.GenerateJwtWithClaims(claims, signingMethod)
+	if err != nil {
+		return errors.WithMessage(err, "error in SetParameters()")
+	}
+
+	parameters.Set(clientAssertionType, api.ClientAssertionTypeJwtBearer)
+	parameters.Set(clientAssertion, assertion)
+
+	return nil
+}
+
+//SigningMethod returns the algorithm to sign request objects and client assertions for the aspsp.
+//It is negotiated from REQUEST_OBJECT_SIGNING_ALG, which keeps the aspsp's request_object_signing_alg_values_supported.
+//The config is resolved once per aspsp with its transport. A missing config falls back to the default algorithm,
+//but a failed lookup is returned rather than negotiating with a config which may exist.
+func SigningMethod(cfg client.Config, aspspId string) (jwt.SigningMethod, error) {
+	supportedValues, err := client.FindConfig(cfg, aspspId, api.RequestObjectSigningAlg, "")
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in SigningMethod()")
+	}
+
+	return security.NegotiateSigningMethod(supportedValues)
+}
@@ -0,0 +1,106 @@
+package clientauth
+
+import (
+	"database/sql"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/pkg/errors"
+	"net/url"
+	"testing"
+)
+
+//configStub returns the configs of the aspsps, keyed by aspspId_configName, or err for all of them if it's set.
+type configStub struct {
+	configs map[string]string
+	err     error
+}
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	if value, found := c.configs[aspspId+"_"+configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+func initTest(t *testing.T) {
+	security.SetSigner(security.NewFileSigner("../../internal/security/testdata/test_key.pem", "kid"))
+	t.Cleanup(func() { security.SetSigner(nil) })
+}
+
+func Test_SigningMethod(t *testing.T) {
+	initTest(t)
+
+	tests := []struct {
+		name    string
+		aspspId string
+		cfg     configStub
+		want    string
+		wantErr bool
+	}{
+		{"not_configured_defaults", "signing_not_configured", configStub{}, "PS256", false},
+		{"configured", "signing_configured", configStub{configs: map[string]string{"signing_configured_REQUEST_OBJECT_SIGNING_ALG": "RS256"}}, "RS256", false},
+		{"lookup_failed", "signing_lookup_failed", configStub{err: errors.New("connection refused")}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SigningMethod(tt.cfg, tt.aspspId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SigningMethod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Alg() != tt.want {
+				t.Errorf("SigningMethod() = %v, want %v", got.Alg(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_SetParameters(t *testing.T) {
+	initTest(t)
+
+	tests := []struct {
+		name          string
+		aspspId       string
+		cfg           configStub
+		wantAssertion bool
+		wantErr       bool
+	}{
+		{"tls_client_auth", "auth_tls", configStub{configs: map[string]string{"auth_tls_TOKEN_ENDPOINT_AUTH_METHOD": "tls_client_auth"}}, false, false},
+		{"private_key_jwt", "auth_jwt", configStub{configs: map[string]string{"auth_jwt_TOKEN_ENDPOINT_AUTH_METHOD": api.PrivateKeyJwt}}, true, false},
+		{"lookup_failed", "auth_failed", configStub{err: errors.New("connection refused")}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parameters := url.Values{}
+			err := SetParameters(tt.cfg, tt.aspspId, "client", "https://aspsp/token", parameters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetParameters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if parameters.Get(clientId) != "client" {
+				t.Errorf("SetParameters() client_id = %v, want client", parameters.Get(clientId))
+			}
+			if got := parameters.Get(clientAssertion) != ""; got != tt.wantAssertion {
+				t.Fatalf("SetParameters() client_assertion = %v, want %v", parameters.Get(clientAssertion), tt.wantAssertion)
+			}
+			if !tt.wantAssertion {
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			token, _, err := new(jwt.Parser).ParseUnverified(parameters.Get(clientAssertion), claims)
+			if err != nil || token.Method.Alg() != "PS256" || claims["iss"] != "client" || claims["aud"] != "https://aspsp/token" {
+				t.Errorf("SetParameters() client_assertion = %v, claims = %v, error = %v", token, claims, err)
+			}
+			if parameters.Get(clientAssertionType) != api.ClientAssertionTypeJwtBearer {
+				t.Errorf("SetParameters() client_assertion_type = %v", parameters.Get(clientAssertionType))
+			}
+		})
+	}
+}
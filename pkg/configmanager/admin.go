@@ -0,0 +1,291 @@
+package configmanager
+
+import (
+	"context"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAspspNotFound  = api.NewError(http.StatusNotFound, api.ResourceNotFound, "aspsp not found")
+	ErrAspspExists    = api.NewError(http.StatusConflict, api.FieldInvalid, "aspsp already exists")
+	ErrConfigNotFound = api.NewError(http.StatusNotFound, api.ResourceNotFound, "config not found")
+)
+
+//RequiredConfigs are the configs every aspsp needs for the token and authorisation flows.
+//CLIENT_ID isn't required while the aspsp has ENDPOINT_REGISTER, as it's set by the dynamic client registration.
+var RequiredConfigs = []string{api.EndpointOauth2, api.EndpointAuthorize, api.ClientId, api.FapiFinancialId}
+
+var aspspIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,50}$`)
+
+//maxConfigValueLength bounds the config values, e.g. the registration access tokens, which are often longer than 255
+//characters and are even longer once they're encrypted.
+const maxConfigValueLength = 4096
+
+//AdminService manages the aspsps in config_table. Each change is recorded with who made it,
+//and the cached configs of the aspsp are invalidated on every instance.
+type AdminService interface {
+	FindAspspIds(ctx context.Context) ([]string, error)
+	FindAspsp(ctx context.Context, aspspId string) (*Aspsp, error)
+	CreateAspsp(ctx context.Context, aspsp Aspsp, changedBy string) error
+	UpdateAspsp(ctx context.Context, aspsp Aspsp, changedBy string) error
+	DeleteAspsp(ctx context.Context, aspspId, changedBy string) error
+	SetConfig(ctx context.Context, aspspId, configName, configValue, changedBy string) error
+	//SetConfigs sets the given configs and keeps the others.
+	SetConfigs(ctx context.Context, aspspId string, configs map[string]string, changedBy string) error
+	DeleteConfig(ctx context.Context, aspspId, configName, changedBy string) error
+	FindHistory(ctx context.Context, aspspId string) ([]History, error)
+}
+
+type adminService struct {
+	ch         cache.Cache
+	repository Repository
+}
+
+//NewAdminService takes the cache of configmanager.Service, so its entries are invalidated on change.
+func NewAdminService(repository Repository, ch cache.Cache) AdminService {
+	return &adminService{
+		repository: repository,
+		ch:         ch,
+	}
+}
+
+func (s adminService) FindAspspIds(ctx context.Context) ([]string, error) {
+	return s.repository.findAspspIds(ctx)
+}
+
+func (s adminService) FindAspsp(ctx context.Context, aspspId string) (*Aspsp, error) {
+	configs, err := s.findConfigs(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, ErrAspspNotFound
+	}
+
+	return &Aspsp{AspspId: aspspId, Configs: configs}, nil
+}
+
+func (s adminService) CreateAspsp(ctx context.Context, aspsp Aspsp, changedBy string) error {
+	current, err := s.findConfigs(ctx, aspsp.AspspId)
+	if err != nil {
+		return err
+	}
+	if len(current) > 0 {
+		return ErrAspspExists
+	}
+
+	return s.apply(ctx, aspsp.AspspId, current, aspsp.Configs, changedBy)
+}
+
+//UpdateAspsp replaces all configs of the aspsp. The configs which aren't given are deleted, and the masked secrets,
+//e.g. of the aspsp which was read from the admin API, are kept.
+func (s adminService) UpdateAspsp(ctx context.Context, aspsp Aspsp, changedBy string) error {
+	current, err := s.findExisting(ctx, aspsp.AspspId)
+	if err != nil {
+		return err
+	}
+
+	desired := copyConfigs(aspsp.Configs)
+	for name, value := range desired {
+		if currentValue, found := current[name]; found && value == maskedValue && isSecret(name) {
+			desired[name] = currentValue
+		}
+	}
+
+	return s.apply(ctx, aspsp.AspspId, current, desired, changedBy)
+}
+
+func (s adminService) DeleteAspsp(ctx context.Context, aspspId, changedBy string) error {
+	current, err := s.findExisting(ctx, aspspId)
+	if err != nil {
+		return err
+	}
+
+	return s.save(ctx, aspspId, diff(aspspId, current, map[string]string{}, changedBy))
+}
+
+func (s adminService) SetConfig(ctx context.Context, aspspId, configName, configValue, changedBy string) error {
+	return s.SetConfigs(ctx, aspspId, map[string]string{configName: configValue}, changedBy)
+}
+
+func (s adminService) SetConfigs(ctx context.Context, aspspId string, configs map[string]string, changedBy string) error {
+	current, err := s.findExisting(ctx, aspspId)
+	if err != nil {
+		return err
+	}
+
+	desired := copyConfigs(current)
+	for configName, configValue := range configs {
+		desired[configName] = configValue
+	}
+
+	return s.apply(ctx, aspspId, current, desired, changedBy)
+}
+
+func (s adminService) DeleteConfig(ctx context.Context, aspspId, configName, changedBy string) error {
+	current, err := s.findExisting(ctx, aspspId)
+	if err != nil {
+		return err
+	}
+	if _, found := current[configName]; !found {
+		return ErrConfigNotFound
+	}
+
+	desired := copyConfigs(current)
+	delete(desired, configName)
+
+	return s.apply(ctx, aspspId, current, desired, changedBy)
+}
+
+func (s adminService) FindHistory(ctx context.Context, aspspId string) ([]History, error) {
+	return s.repository.findHistory(ctx, aspspId)
+}
+
+func (s adminService) findConfigs(ctx context.Context, aspspId string) (map[string]string, error) {
+	configs, err := s.repository.findConfigs(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(configs))
+	for _, config := range configs {
+		values[config.ConfigName] = config.ConfigValue
+	}
+
+	return values, nil
+}
+
+func (s adminService) findExisting(ctx context.Context, aspspId string) (map[string]string, error) {
+	current, err := s.findConfigs(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, ErrAspspNotFound
+	}
+
+	return current, nil
+}
+
+func (s adminService) apply(ctx context.Context, aspspId string, current, desired map[string]string, changedBy string) error {
+	if err := validate(aspspId, desired); err != nil {
+		return err
+	}
+
+	return s.save(ctx, aspspId, diff(aspspId, current, desired, changedBy))
+}
+
+func (s adminService) save(ctx context.Context, aspspId string, changes []History) error {
+	ctx, span := tracing.Start(ctx, "configmanager.save", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := s.repository.saveChanges(ctx, changes); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	event := ChangeEvent{AspspId: aspspId}
+	for _, change := range changes {
+		event.ConfigNames = append(event.ConfigNames, change.ConfigName)
+	}
+	invalidate(s.ch, event)
+	publishChange(event)
+	logger.Info("aspsp configs have been changed", logger.String("aspspId", aspspId),
+		logger.Int("changes", len(changes)), logger.String("changedBy", changes[0].ChangedBy))
+
+	return nil
+}
+
+//validate checks the aspsp id, the required configs and that the endpoints are absolute urls.
+func validate(aspspId string, configs map[string]string) error {
+	verr := &ValidationError{}
+	if !aspspIdRegexp.MatchString(aspspId) {
+		verr.Errors = append(verr.Errors, "aspspId should be 1-50 letters, digits, _ or -")
+	}
+	for _, name := range RequiredConfigs {
+		if name == api.ClientId && configs[api.EndpointRegister] != "" {
+			continue
+		}
+		if strings.TrimSpace(configs[name]) == "" {
+			verr.Errors = append(verr.Errors, name+" is required")
+		}
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := configs[name]
+		switch {
+		case name == "" || len(name) > 255:
+			verr.Errors = append(verr.Errors, "config name should be 1-255 characters")
+		case len(value) > maxConfigValueLength:
+			verr.Errors = append(verr.Errors, fmt.Sprintf("%v can't be longer than %v characters", name, maxConfigValueLength))
+		case strings.HasPrefix(name, "ENDPOINT_") && value != "":
+			if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+				verr.Errors = append(verr.Errors, name+" should be an absolute url")
+			}
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+//diff returns the changes from current to desired, sorted by config name.
+func diff(aspspId string, current, desired map[string]string, changedBy string) []History {
+	now := time.Now().UTC()
+	var changes []History
+	for name, value := range desired {
+		oldValue, found := current[name]
+		switch {
+		case !found:
+			changes = append(changes, History{ConfigName: name, Action: ActionCreate, NewValue: value})
+		case oldValue != value:
+			changes = append(changes, History{ConfigName: name, Action: ActionUpdate, OldValue: oldValue, NewValue: value})
+		}
+	}
+	for name, oldValue := range current {
+		if _, found := desired[name]; !found {
+			changes = append(changes, History{ConfigName: name, Action: ActionDelete, OldValue: oldValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ConfigName < changes[j].ConfigName })
+	for i := range changes {
+		changes[i].AspspId = aspspId
+		changes[i].ChangedBy = changedBy
+		changes[i].CreateDateTime = now
+	}
+
+	return changes
+}
+
+func copyConfigs(configs map[string]string) map[string]string {
+	copied := make(map[string]string, len(configs))
+	for name, value := range configs {
+		copied[name] = value
+	}
+
+	return copied
+}
@@ -0,0 +1,206 @@
+package configmanager
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"reflect"
+	"testing"
+)
+
+type repositoryStub struct {
+	Repository
+	configs []Config
+	changes []History
+}
+
+func (r *repositoryStub) findConfigs(ctx context.Context, aspspId string) ([]Config, error) {
+	return r.configs, nil
+}
+
+func (r *repositoryStub) saveChanges(ctx context.Context, changes []History) error {
+	r.changes = changes
+	return nil
+}
+
+var requiredConfigs = map[string]string{
+	"ENDPOINT_OAUTH2":    "https://aspsp/token",
+	"ENDPOINT_AUTHORIZE": "https://aspsp/authorize",
+	"CLIENT_ID":          "client",
+	"FAPI_FINANCIAL_ID":  "financial",
+}
+
+func stubConfigs(aspspId string, values map[string]string) []Config {
+	var configs []Config
+	for name, value := range values {
+		configs = append(configs, Config{AspspId: aspspId, ConfigName: name, ConfigValue: value})
+	}
+
+	return configs
+}
+
+func Test_adminService_SetConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		configs     []Config
+		configName  string
+		configValue string
+		wantErr     error
+		wantChanges []string
+	}{
+		{"update_config", stubConfigs("test", requiredConfigs), "CLIENT_ID", "new_client", nil, []string{"UPDATE CLIENT_ID"}},
+		{"create_config", stubConfigs("test", requiredConfigs), "ISS", "iss", nil, []string{"CREATE ISS"}},
+		{"unchanged_config", stubConfigs("test", requiredConfigs), "CLIENT_ID", "client", nil, nil},
+		{"required_config_empty", stubConfigs("test", requiredConfigs), "CLIENT_ID", "", &ValidationError{Errors: []string{"CLIENT_ID is required"}}, nil},
+		{"invalid_endpoint", stubConfigs("test", requiredConfigs), "ENDPOINT_ACCOUNTS", "aspsp/accounts", &ValidationError{Errors: []string{"ENDPOINT_ACCOUNTS should be an absolute url"}}, nil},
+		{"aspsp_not_found", nil, "CLIENT_ID", "client", ErrAspspNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &repositoryStub{configs: tt.configs}
+			ch := cache.LoadInMemory()
+			_ = ch.Set("test_"+tt.configName, "cached", cache.NoExpiration)
+			s := NewAdminService(repo, ch)
+
+			err := s.SetConfig(context.Background(), "test", tt.configName, tt.configValue, "operator")
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("SetConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, change := range repo.changes {
+				got = append(got, change.Action+" "+change.ConfigName)
+				if change.ChangedBy != "operator" || change.AspspId != "test" {
+					t.Errorf("SetConfig() change = %+v, want changedBy operator and aspspId test", change)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantChanges) {
+				t.Errorf("SetConfig() changes = %v, want %v", got, tt.wantChanges)
+			}
+			if _, found := ch.Get("test_" + tt.configName); found == (len(tt.wantChanges) > 0) {
+				t.Errorf("SetConfig() cache entry found = %v, want invalidated only on change", found)
+			}
+		})
+	}
+}
+
+func Test_adminService_CreateAspsp(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []Config
+		aspsp   Aspsp
+		wantErr bool
+	}{
+		{"create_success", nil, Aspsp{AspspId: "new", Configs: requiredConfigs}, false},
+		{"aspsp_exists", stubConfigs("new", requiredConfigs), Aspsp{AspspId: "new", Configs: requiredConfigs}, true},
+		{"missing_required", nil, Aspsp{AspspId: "new", Configs: map[string]string{"CLIENT_ID": "client"}}, true},
+		{"invalid_aspsp_id", nil, Aspsp{AspspId: "new aspsp", Configs: requiredConfigs}, true},
+		{"pending_registration", nil, Aspsp{AspspId: "new", Configs: map[string]string{
+			"ENDPOINT_OAUTH2":    "https://aspsp/token",
+			"ENDPOINT_AUTHORIZE": "https://aspsp/authorize",
+			"ENDPOINT_REGISTER":  "https://aspsp/register",
+			"FAPI_FINANCIAL_ID":  "financial",
+		}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &repositoryStub{configs: tt.configs}
+			s := NewAdminService(repo, cache.LoadInMemory())
+			if err := s.CreateAspsp(context.Background(), tt.aspsp, "operator"); (err != nil) != tt.wantErr {
+				t.Errorf("CreateAspsp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(repo.changes) != len(requiredConfigs) {
+				t.Errorf("CreateAspsp() changes = %v, want %v", len(repo.changes), len(requiredConfigs))
+			}
+		})
+	}
+}
+
+func Test_History_masked(t *testing.T) {
+	tests := []struct {
+		name    string
+		history History
+		want    History
+	}{
+		{"secret_config", History{ConfigName: "AUTHENTICATION_TOKEN", OldValue: "old", NewValue: "new"},
+			History{ConfigName: "AUTHENTICATION_TOKEN", OldValue: "[REDACTED]", NewValue: "[REDACTED]"}},
+		{"secret_config_deleted", History{ConfigName: "CLIENT_SECRET", OldValue: "old"},
+			History{ConfigName: "CLIENT_SECRET", OldValue: "[REDACTED]"}},
+		{"plain_config", History{ConfigName: "CLIENT_ID", OldValue: "old", NewValue: "new"},
+			History{ConfigName: "CLIENT_ID", OldValue: "old", NewValue: "new"}},
+		{"token_endpoint_auth_method", History{ConfigName: "TOKEN_ENDPOINT_AUTH_METHOD", OldValue: "client_secret_basic", NewValue: "private_key_jwt"},
+			History{ConfigName: "TOKEN_ENDPOINT_AUTH_METHOD", OldValue: "client_secret_basic", NewValue: "private_key_jwt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.history.masked(); got != tt.want {
+				t.Errorf("masked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Aspsp_masked(t *testing.T) {
+	aspsp := Aspsp{AspspId: "test", Configs: map[string]string{"CLIENT_ID": "client", "CLIENT_SECRET": "secret", "REGISTRATION_ACCESS_TOKEN": ""}}
+	want := Aspsp{AspspId: "test", Configs: map[string]string{"CLIENT_ID": "client", "CLIENT_SECRET": "[REDACTED]", "REGISTRATION_ACCESS_TOKEN": ""}}
+
+	if got := aspsp.masked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("masked() = %v, want %v", got, want)
+	}
+	if aspsp.Configs["CLIENT_SECRET"] != "secret" {
+		t.Errorf("masked() changed the configs of the aspsp")
+	}
+}
+
+func Test_isSecret(t *testing.T) {
+	tests := []struct {
+		configName string
+		want       bool
+	}{
+		{"CLIENT_SECRET", true},
+		{"REGISTRATION_ACCESS_TOKEN", true},
+		{"AUTHENTICATION_TOKEN", true},
+		{"OB_SIGN_KEY_PASSPHRASE", true},
+		{"REDIS_PASSWORD", true},
+		{"TOKEN_ENDPOINT_AUTH_METHOD", false},
+		{"TOKEN_ENDPOINT_AUTH_METHODS_SUPPORTED", false},
+		{"CLIENT_ID", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.configName, func(t *testing.T) {
+			if got := isSecret(tt.configName); got != tt.want {
+				t.Errorf("isSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_adminService_UpdateAspsp_keepsMaskedSecrets(t *testing.T) {
+	current := copyConfigs(requiredConfigs)
+	current["CLIENT_SECRET"] = "secret"
+	repo := &repositoryStub{configs: stubConfigs("test", current)}
+	s := NewAdminService(repo, cache.LoadInMemory())
+
+	desired := Aspsp{AspspId: "test", Configs: copyConfigs(current)}.masked()
+	desired.Configs["CLIENT_ID"] = "new_client"
+	if err := s.UpdateAspsp(context.Background(), desired, "operator"); err != nil {
+		t.Fatalf("UpdateAspsp() error = %v", err)
+	}
+	if len(repo.changes) != 1 || repo.changes[0].ConfigName != "CLIENT_ID" {
+		t.Errorf("UpdateAspsp() changes = %v, want only CLIENT_ID", repo.changes)
+	}
+}
+
+func Test_invalidate(t *testing.T) {
+	ch := cache.LoadInMemory()
+	_ = ch.Set("changed_CLIENT_ID", "client", cache.NoExpiration)
+	_ = ch.Set("changed_ISS", "iss", cache.NoExpiration)
+
+	invalidate(ch, ChangeEvent{AspspId: "changed", ConfigNames: []string{"CLIENT_ID"}})
+
+	if _, found := ch.Get("changed_CLIENT_ID"); found {
+		t.Errorf("invalidate() changed config is still cached")
+	}
+	if _, found := ch.Get("changed_ISS"); !found {
+		t.Errorf("invalidate() unchanged config shouldn't be dropped")
+	}
+}
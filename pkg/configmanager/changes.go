@@ -0,0 +1,53 @@
+package configmanager
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+)
+
+//ChangesChannel is the redis channel which the config changes are published to.
+const ChangesChannel = "configmanager_changes"
+
+//ChangeEvent tells the instances which configs of an aspsp have changed.
+type ChangeEvent struct {
+	AspspId     string   `json:"aspspId"`
+	ConfigNames []string `json:"configNames"`
+}
+
+//invalidate drops the cached configs of the event, and the transport and circuit breaker built from them.
+func invalidate(ch cache.Cache, event ChangeEvent) {
+	for _, configName := range event.ConfigNames {
+		_ = ch.Delete(configCacheId(event.AspspId, configName))
+	}
+	client.Reset(event.AspspId)
+}
+
+//publishChange notifies the other instances. A failure is only logged, as they pick up the change when
+//their cached configs expire.
+func publishChange(event ChangeEvent) {
+	message, err := json.Marshal(event)
+	if err == nil {
+		err = cache.Publish(ChangesChannel, string(message))
+	}
+	if err != nil {
+		logger.Warn("couldn't publish the config change. other instances apply it when CONFIG_CACHE_TTL expires",
+			logger.String("aspspId", event.AspspId), logger.Err(err))
+	}
+}
+
+//ListenChanges invalidates the configs which are changed on any instance, until ctx is done.
+func ListenChanges(ctx context.Context, ch cache.Cache) {
+	cache.Subscribe(ctx, ChangesChannel, func(message string) {
+		var event ChangeEvent
+		if err := json.Unmarshal([]byte(message), &event); err != nil {
+			logger.Warn("invalid config change event", logger.Err(err))
+			return
+		}
+
+		invalidate(ch, event)
+		logger.Debug("config change has been applied", logger.String("aspspId", event.AspspId))
+	})
+}
@@ -0,0 +1,158 @@
+package configmanager
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+//AdminRole is the role claim the internal token needs to change the aspsp configs.
+const AdminRole = "admin"
+
+var (
+	errAdminRole   = api.NewError(http.StatusForbidden, api.HeaderInvalid, "admin role is required")
+	errSubClaim    = api.NewError(http.StatusForbidden, api.HeaderInvalid, "sub claim is required")
+	errInvalidBody = api.NewError(http.StatusBadRequest, api.FieldInvalid, "invalid request body")
+)
+
+func RegisterHandler(e *echo.Echo, service AdminService) {
+	g := e.Group("/admin/aspsps", RequireAdmin())
+	g.GET("", findAspspIds(service))
+	g.POST("", createAspsp(service))
+	g.GET("/:aspspId", findAspsp(service))
+	g.PUT("/:aspspId", updateAspsp(service))
+	g.DELETE("/:aspspId", deleteAspsp(service))
+	g.PUT("/:aspspId/configs/:configName", setConfig(service))
+	g.DELETE("/:aspspId/configs/:configName", deleteConfig(service))
+	g.GET("/:aspspId/history", findHistory(service))
+}
+
+//RequireAdmin lets the request through if the verified internal token has the admin role.
+//The sub claim of the token is recorded as the author of the changes.
+func RequireAdmin() echo.MiddlewareFunc {
+	return func(handler echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, _ := c.Get(api.JwtClaims).(jwt.MapClaims)
+			if role, _ := claims["role"].(string); role != AdminRole {
+				return errAdminRole
+			}
+			if sub, _ := claims["sub"].(string); sub == "" {
+				return errSubClaim
+			}
+
+			return handler(c)
+		}
+	}
+}
+
+//ChangedBy returns the sub claim of the admin token.
+func ChangedBy(c echo.Context) string {
+	claims, _ := c.Get(api.JwtClaims).(jwt.MapClaims)
+	sub, _ := claims["sub"].(string)
+
+	return sub
+}
+
+func findAspspIds(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		aspspIds, err := service.FindAspspIds(c.Request().Context())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, aspspIds)
+	}
+}
+
+func findAspsp(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		aspsp, err := service.FindAspsp(c.Request().Context(), c.Param("aspspId"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, aspsp.masked())
+	}
+}
+
+func createAspsp(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var aspsp Aspsp
+		if err := c.Bind(&aspsp); err != nil {
+			return errInvalidBody.Wrap(err)
+		}
+
+		if err := service.CreateAspsp(c.Request().Context(), aspsp, ChangedBy(c)); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, aspsp)
+	}
+}
+
+func updateAspsp(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var aspsp Aspsp
+		if err := c.Bind(&aspsp); err != nil {
+			return errInvalidBody.Wrap(err)
+		}
+		aspsp.AspspId = c.Param("aspspId")
+
+		if err := service.UpdateAspsp(c.Request().Context(), aspsp, ChangedBy(c)); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, aspsp)
+	}
+}
+
+func deleteAspsp(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := service.DeleteAspsp(c.Request().Context(), c.Param("aspspId"), ChangedBy(c)); err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func setConfig(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var body struct {
+			ConfigValue string `json:"configValue"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return errInvalidBody.Wrap(err)
+		}
+
+		err := service.SetConfig(c.Request().Context(), c.Param("aspspId"), c.Param("configName"), body.ConfigValue, ChangedBy(c))
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func deleteConfig(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := service.DeleteConfig(c.Request().Context(), c.Param("aspspId"), c.Param("configName"), ChangedBy(c))
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func findHistory(service AdminService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		history, err := service.FindHistory(c.Request().Context(), c.Param("aspspId"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, history)
+	}
+}
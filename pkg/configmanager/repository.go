@@ -1,20 +1,30 @@
 package configmanager
 
 import (
+	"context"
 	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"github.com/pkg/errors"
 )
 
 type Repository interface {
 	findByConfigName(aspspId, configName string) (string, error)
+	findAspspIds(ctx context.Context) ([]string, error)
+	findConfigs(ctx context.Context, aspspId string) ([]Config, error)
+	saveChanges(ctx context.Context, changes []History) error
+	findHistory(ctx context.Context, aspspId string) ([]History, error)
 }
 
 type repository struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	cipher security.Cipher
 }
 
-func NewRepository(db *sqlx.DB) Repository {
-	return &repository{db: db}
+//NewRepository encrypts the values of the secret configs, e.g. CLIENT_SECRET, with cipher. They're stored in
+//plaintext if cipher is nil.
+func NewRepository(db *sqlx.DB, cipher security.Cipher) Repository {
+	return &repository{db: db, cipher: cipher}
 }
 
 func (r repository) findByConfigName(aspspId, configName string) (string, error) {
@@ -24,5 +34,112 @@ func (r repository) findByConfigName(aspspId, configName string) (string, error)
 		return "", errors.WithMessage(err, "error in findByConfigName()")
 	}
 
-	return configValue, nil
+	return r.decrypt(configValue)
+}
+
+func (r repository) findAspspIds(ctx context.Context) ([]string, error) {
+	ctx, span := store.StartSpan(ctx, "findAspspIds")
+	defer span.End()
+
+	aspspIds := []string{}
+	if err := r.db.SelectContext(ctx, &aspspIds, "SELECT DISTINCT aspsp_id from config_table ORDER BY aspsp_id"); err != nil {
+		return nil, errors.WithMessage(err, "error in findAspspIds()")
+	}
+
+	return aspspIds, nil
+}
+
+func (r repository) findConfigs(ctx context.Context, aspspId string) ([]Config, error) {
+	ctx, span := store.StartSpan(ctx, "findConfigs")
+	defer span.End()
+
+	var configs []Config
+	if err := r.db.SelectContext(ctx, &configs, "SELECT * from config_table where aspsp_id=$1 ORDER BY config_name", aspspId); err != nil {
+		return nil, errors.WithMessage(err, "error in findConfigs()")
+	}
+	for i := range configs {
+		value, err := r.decrypt(configs[i].ConfigValue)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "error in findConfigs() while decrypting %v", configs[i].ConfigName)
+		}
+		configs[i].ConfigValue = value
+	}
+
+	return configs, nil
+}
+
+//saveChanges applies the changes to config_table and records them in config_history_table in a single transaction.
+func (r repository) saveChanges(ctx context.Context, changes []History) error {
+	ctx, span := store.StartSpan(ctx, "saveChanges")
+	defer span.End()
+
+	tx := r.db.MustBeginTx(ctx, nil)
+
+	for _, change := range changes {
+		value, err := r.encrypt(change.ConfigName, change.NewValue)
+		if err != nil {
+			tx.Rollback()
+			return errors.WithMessagef(err, "error in saveChanges() while encrypting %v", change.ConfigName)
+		}
+
+		switch change.Action {
+		case ActionCreate:
+			_, err = tx.ExecContext(ctx, "INSERT INTO config_table(config_name, config_value, aspsp_id) VALUES ($1, $2, $3)",
+				change.ConfigName, value, change.AspspId)
+		case ActionUpdate:
+			_, err = tx.ExecContext(ctx, "UPDATE config_table SET config_value=$1 where config_name=$2 and aspsp_id=$3",
+				value, change.ConfigName, change.AspspId)
+		case ActionDelete:
+			_, err = tx.ExecContext(ctx, "DELETE from config_table where config_name=$1 and aspsp_id=$2",
+				change.ConfigName, change.AspspId)
+		}
+		if err != nil {
+			tx.Rollback()
+			return errors.WithMessagef(err, "error in saveChanges() while applying %v of %v", change.Action, change.ConfigName)
+		}
+
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO config_history_table(aspsp_id, config_name, action, old_value, new_value, changed_by, create_date_time) 
+												VALUES (:aspsp_id, :config_name, :action, :old_value, :new_value, :changed_by, :create_date_time)`, change.masked())
+		if err != nil {
+			tx.Rollback()
+			return errors.WithMessage(err, "error in saveChanges() while inserting the history")
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		return errors.WithMessage(err, "error in saveChanges() while committing transactions")
+	}
+
+	return nil
+}
+
+func (r repository) findHistory(ctx context.Context, aspspId string) ([]History, error) {
+	ctx, span := store.StartSpan(ctx, "findHistory")
+	defer span.End()
+
+	history := []History{}
+	err := r.db.SelectContext(ctx, &history, "SELECT * from config_history_table where aspsp_id=$1 ORDER BY create_date_time DESC, id DESC", aspspId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in findHistory()")
+	}
+
+	return history, nil
+}
+
+//encrypt returns the value which is stored in config_table. Only the secret configs are encrypted.
+func (r repository) encrypt(configName, value string) (string, error) {
+	if r.cipher == nil || value == "" || !isSecret(configName) {
+		return value, nil
+	}
+
+	return r.cipher.Encrypt(value)
+}
+
+func (r repository) decrypt(value string) (string, error) {
+	if r.cipher == nil {
+		return value, nil
+	}
+
+	return r.cipher.Decrypt(value)
 }
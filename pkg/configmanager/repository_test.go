@@ -1,13 +1,17 @@
 package configmanager
 
 import (
+	"context"
+	"encoding/base64"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	_ "github.com/mattn/go-sqlite3"
 	"testing"
+	"time"
 )
 
 func Test_repository_FindByConfigName(t *testing.T) {
-	repo := NewRepository(store.LoadDBConnection())
+	repo := NewRepository(store.LoadDBConnection(), nil)
 
 	type args struct {
 		aspspId    string
@@ -43,3 +47,74 @@ func Test_repository_FindByConfigName(t *testing.T) {
 		})
 	}
 }
+
+func Test_repository_saveChangesAndFindHistory(t *testing.T) {
+	repo := NewRepository(store.LoadDBConnection(), nil)
+	ctx := context.Background()
+
+	changes := []History{
+		{AspspId: "admin_test", ConfigName: "CLIENT_ID", Action: ActionCreate, NewValue: "client", ChangedBy: "operator", CreateDateTime: time.Now()},
+		{AspspId: "admin_test", ConfigName: "CLIENT_SECRET", Action: ActionCreate, NewValue: "secret", ChangedBy: "operator", CreateDateTime: time.Now()},
+	}
+	if err := repo.saveChanges(ctx, changes); err != nil {
+		t.Fatalf("saveChanges() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = repo.saveChanges(ctx, []History{
+			{AspspId: "admin_test", ConfigName: "CLIENT_ID", Action: ActionDelete, OldValue: "client", ChangedBy: "operator", CreateDateTime: time.Now()},
+			{AspspId: "admin_test", ConfigName: "CLIENT_SECRET", Action: ActionDelete, OldValue: "secret", ChangedBy: "operator", CreateDateTime: time.Now()},
+		})
+	})
+
+	configs, err := repo.findConfigs(ctx, "admin_test")
+	if err != nil || len(configs) != 2 || configs[1].ConfigValue != "secret" {
+		t.Errorf("findConfigs() = %v, error = %v", configs, err)
+	}
+
+	history, err := repo.findHistory(ctx, "admin_test")
+	if err != nil || len(history) < 2 {
+		t.Fatalf("findHistory() = %v, error = %v", history, err)
+	}
+	for _, h := range history {
+		if h.ConfigName == "CLIENT_SECRET" && h.NewValue != "[REDACTED]" {
+			t.Errorf("findHistory() secret value = %v, want it masked", h.NewValue)
+		}
+	}
+}
+
+func Test_repository_encryptsSecrets(t *testing.T) {
+	cipher, err := security.NewCipher(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	db := store.LoadDBConnection()
+	repo := NewRepository(db, cipher)
+	ctx := context.Background()
+
+	changes := []History{
+		{AspspId: "encrypt_test", ConfigName: "CLIENT_ID", Action: ActionCreate, NewValue: "client", ChangedBy: "operator", CreateDateTime: time.Now()},
+		{AspspId: "encrypt_test", ConfigName: "CLIENT_SECRET", Action: ActionCreate, NewValue: "secret", ChangedBy: "operator", CreateDateTime: time.Now()},
+	}
+	if err := repo.saveChanges(ctx, changes); err != nil {
+		t.Fatalf("saveChanges() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE from config_table where aspsp_id=$1", "encrypt_test")
+	})
+
+	var stored []Config
+	if err := db.Select(&stored, "SELECT * from config_table where aspsp_id=$1 ORDER BY config_name", "encrypt_test"); err != nil {
+		t.Fatalf("select error = %v", err)
+	}
+	if len(stored) != 2 || stored[0].ConfigValue != "client" || stored[1].ConfigValue == "secret" {
+		t.Errorf("config_table = %v, want only CLIENT_SECRET encrypted", stored)
+	}
+
+	if got, err := repo.findByConfigName("encrypt_test", "CLIENT_SECRET"); err != nil || got != "secret" {
+		t.Errorf("findByConfigName() = %v, error = %v, want secret", got, err)
+	}
+	configs, err := repo.findConfigs(ctx, "encrypt_test")
+	if err != nil || len(configs) != 2 || configs[1].ConfigValue != "secret" {
+		t.Errorf("findConfigs() = %v, error = %v", configs, err)
+	}
+}
@@ -3,6 +3,7 @@ package configmanager
 import (
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/pkg/errors"
+	"time"
 )
 
 type Service interface {
@@ -12,17 +13,25 @@ type Service interface {
 type service struct {
 	ch         cache.Cache
 	repository Repository
+	ttl        time.Duration
 }
 
-func NewService(repository Repository, ch cache.Cache) Service {
+//NewService caches the configs for ttl. The changes made on other instances are invalidated by ListenChanges,
+//so the ttl only bounds how long a missed change is served.
+func NewService(repository Repository, ch cache.Cache, ttl time.Duration) Service {
 	return &service{
 		repository: repository,
 		ch:         ch,
+		ttl:        ttl,
 	}
 }
 
+func configCacheId(aspspId, configName string) string {
+	return aspspId + "_" + configName
+}
+
 func (s service) FindByConfigName(aspspId, configName string) (string, error) {
-	cacheId := aspspId + "_" + configName
+	cacheId := configCacheId(aspspId, configName)
 	if value, found := s.ch.Get(cacheId); found {
 		return value.(string), nil
 	}
@@ -32,6 +41,15 @@ func (s service) FindByConfigName(aspspId, configName string) (string, error) {
 		return "", errors.WithMessage(err, "error in FindByConfigName()")
 	}
 
-	_ = s.ch.Set(cacheId, configValue, cache.NoExpiration)
+	_ = s.ch.Set(cacheId, configValue, s.expiration())
 	return configValue, nil
 }
+
+//expiration converts the ttl to the unit of the in-memory cache, which is minutes.
+func (s service) expiration() time.Duration {
+	if s.ttl < time.Minute {
+		return cache.NoExpiration
+	}
+
+	return time.Duration(s.ttl / time.Minute)
+}
@@ -4,10 +4,11 @@ import (
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"testing"
+	"time"
 )
 
 func Test_service_FindByConfigName(t *testing.T) {
-	repo := NewRepository(store.LoadDBConnection())
+	repo := NewRepository(store.LoadDBConnection(), nil)
 
 	type fields struct {
 		repo Repository
@@ -52,3 +53,22 @@ func Test_service_FindByConfigName(t *testing.T) {
 		})
 	}
 }
+
+func Test_service_expiration(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"ttl_in_minutes", 10 * time.Minute, 10},
+		{"no_ttl", 0, cache.NoExpiration},
+		{"ttl_below_a_minute", 30 * time.Second, cache.NoExpiration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (service{ttl: tt.ttl}).expiration(); got != tt.want {
+				t.Errorf("expiration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package configmanager
+
+import (
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Aspsp is an aspsp with its config_name/config_value pairs of config_table.
+type Aspsp struct {
+	AspspId string            `json:"aspspId"`
+	Configs map[string]string `json:"configs"`
+}
+
+type Config struct {
+	Id          int64  `db:"id"`
+	AspspId     string `db:"aspsp_id"`
+	ConfigName  string `db:"config_name"`
+	ConfigValue string `db:"config_value"`
+}
+
+//Actions of the config changes.
+const (
+	ActionCreate = "CREATE"
+	ActionUpdate = "UPDATE"
+	ActionDelete = "DELETE"
+)
+
+//History is a change of a config and who made it.
+type History struct {
+	Id             int64     `db:"id" json:"id"`
+	AspspId        string    `db:"aspsp_id" json:"aspspId"`
+	ConfigName     string    `db:"config_name" json:"configName"`
+	Action         string    `db:"action" json:"action"`
+	OldValue       string    `db:"old_value" json:"oldValue"`
+	NewValue       string    `db:"new_value" json:"newValue"`
+	ChangedBy      string    `db:"changed_by" json:"changedBy"`
+	CreateDateTime time.Time `db:"create_date_time" json:"createDateTime"`
+}
+
+//secretSuffixes are the endings of the secret config names whose values aren't kept in the history and are encrypted
+//in config_table, e.g. CLIENT_SECRET and REGISTRATION_ACCESS_TOKEN. A suffix rather than a substring is matched, so
+//TOKEN_ENDPOINT_AUTH_METHOD stays in plaintext.
+var secretSuffixes = []string{"_SECRET", "_TOKEN", "_PASSWORD", "_PASSPHRASE"}
+
+func isSecret(configName string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(configName, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//maskedValue replaces the values of secret configs.
+const maskedValue = "[REDACTED]"
+
+//masked returns the history with the values of secret configs masked.
+func (h History) masked() History {
+	if isSecret(h.ConfigName) {
+		if h.OldValue != "" {
+			h.OldValue = maskedValue
+		}
+		if h.NewValue != "" {
+			h.NewValue = maskedValue
+		}
+	}
+
+	return h
+}
+
+//masked returns the aspsp with the values of secret configs masked, like the history.
+func (a Aspsp) masked() Aspsp {
+	configs := make(map[string]string, len(a.Configs))
+	for name, value := range a.Configs {
+		if value != "" && isSecret(name) {
+			value = maskedValue
+		}
+		configs[name] = value
+	}
+
+	return Aspsp{AspspId: a.AspspId, Configs: configs}
+}
+
+//ValidationError lists the missing and invalid configs of an aspsp.
+type ValidationError struct {
+	Errors []string `json:"errors"`
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configs: %v", strings.Join(v.Errors, ", "))
+}
+
+func (v *ValidationError) ApiError() *api.Error {
+	details := make([]api.ErrorDetail, 0, len(v.Errors))
+	for _, message := range v.Errors {
+		details = append(details, api.ErrorDetail{ErrorCode: api.FieldInvalid, Message: message})
+	}
+
+	return api.NewError(http.StatusBadRequest, api.FieldInvalid, v.Error()).WithDetails(details...)
+}
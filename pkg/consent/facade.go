@@ -2,12 +2,14 @@ package consent
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"github.com/google/uuid"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/clientauth"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
 	"github.com/pkg/errors"
@@ -16,9 +18,9 @@ import (
 )
 
 type Facade interface {
-	CreateConsent(sessionReferenceId, trackingId, aspspId string, consent *ObReadConsent) (string, error)
-	GetConsent(cid, aspspId string) (string, error)
-	DeleteConsent(consentId string) (string, error)
+	CreateConsent(ctx context.Context, sessionReferenceId, trackingId, aspspId string, consent *ObReadConsent) (string, error)
+	GetConsent(ctx context.Context, cid, aspspId string) (string, error)
+	DeleteConsent(ctx context.Context, consentId string) (string, error)
 }
 
 type facade struct {
@@ -37,14 +39,19 @@ func NewFacade(serviceRead ServiceRead, serviceWrite ServiceWrite, tokenService
 	}
 }
 
-func (f facade) CreateConsent(sessionReferenceId, trackingId, aspspId string, obConsent *ObReadConsent) (string, error) {
-	consentResp, err := f.serviceRead.FindByTrackingId(trackingId)
-	if (err != nil && !errors.Is(err, sql.ErrNoRows)) || consentResp != nil {
-		return "", fmt.Errorf("reference has already been used. please try a new one")
-	}
+func (f facade) CreateConsent(ctx context.Context, sessionReferenceId, trackingId, aspspId string, obConsent *ObReadConsent) (string, error) {
+	ctx, span := tracing.Start(ctx, "consent.CreateConsent", tracing.KindInternal)
+	defer span.End()
 
 	var errMessage = "error in CreateConsent()"
-	obAccessToken, err := f.tokenService.GetAccessToken(aspspId, api.ScopeAccounts)
+	consentResp, err := f.serviceRead.FindByTrackingId(ctx, trackingId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", errors.WithMessage(err, errMessage)
+	}
+	if consentResp != nil {
+		return "", ErrReferenceUsed
+	}
+	obAccessToken, err := f.tokenService.GetAccessToken(ctx, aspspId, api.ScopeAccounts)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
@@ -57,15 +64,16 @@ func (f facade) CreateConsent(sessionReferenceId, trackingId, aspspId string, ob
 	endpointAccountAccessConsent, _ := f.cfg.FindByConfigName(aspspId, api.EndpointAccountAccessConsent)
 	fapiFinancialId, _ := f.cfg.FindByConfigName(aspspId, api.FapiFinancialId)
 
-	httpClient, err := client.NewSecureHttpClient(endpointAccountAccessConsent, f.setHeader(obAccessToken, fapiFinancialId))
+	httpClient, err := client.NewSecureHttpClient(f.cfg, aspspId, api.EndpointAccountAccessConsent, endpointAccountAccessConsent, f.setHeader(ctx, obAccessToken, fapiFinancialId))
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Post(bytes.NewBuffer(consentJson))
+	resp, err := httpClient.Post(ctx, bytes.NewBuffer(consentJson))
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
+	client.RecordInteraction(ctx, resp)
 
 	if resp.StatusCode == 200 || resp.StatusCode == 201 {
 		var responseData ObReadConsentResponse
@@ -103,7 +111,7 @@ func (f facade) CreateConsent(sessionReferenceId, trackingId, aspspId string, ob
 			Tokens:                         tokens,
 		}
 
-		err = f.serviceWrite.SaveConsent(consentDetail)
+		err = f.serviceWrite.SaveConsent(ctx, consentDetail)
 		if err != nil {
 			return "", errors.WithMessage(err, errMessage)
 		}
@@ -129,21 +137,30 @@ func (f facade) CreateConsent(sessionReferenceId, trackingId, aspspId string, ob
 			return "", errors.WithMessage(err, errMessage)
 		}
 
-		authRedirectUrl, err := authorizeConsentId(clientId, iss, aud, endpointAuthorize, api.ScopeAccounts, trackingId, uuid.New().String(), consentId, redirectUrl)
+		signingMethod, err := clientauth.SigningMethod(f.cfg, aspspId)
+		if err != nil {
+			return "", errors.WithMessage(err, errMessage)
+		}
+
+		authRedirectUrl, err := authorizeConsentId(ctx, f.cfg, aspspId, clientId, iss, aud, endpointAuthorize, api.ScopeAccounts, trackingId, uuid.New().String(), consentId, redirectUrl, signingMethod)
 		if err != nil {
 			return "", errors.WithMessage(err, errMessage)
 		}
 
 		return authRedirectUrl, nil
 	} else {
-		return "", fmt.Errorf("unexpected result from the token facade. response: %v", *resp)
+		return "", errors.WithMessage(api.NewAspspError(resp.StatusCode, resp.Body), errMessage)
 	}
 }
 
-func (f facade) GetConsent(cid, aspspId string) (string, error) {
-	consentResp, err := f.serviceRead.FindByCid(cid)
-	if err == sql.ErrNoRows {
-		return "", err
+func (f facade) GetConsent(ctx context.Context, cid, aspspId string) (string, error) {
+	ctx, span := tracing.Start(ctx, "consent.GetConsent", tracing.KindInternal)
+	defer span.End()
+
+	client.InteractionFrom(ctx).Cid = cid
+	consentResp, err := f.serviceRead.FindByCid(ctx, cid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrConsentNotFound.Wrap(err)
 	}
 
 	var errMessage = "error in GetConsent()"
@@ -152,7 +169,7 @@ func (f facade) GetConsent(cid, aspspId string) (string, error) {
 	}
 	consentId := consentResp.ConsentId
 
-	accessToken, err := f.tokenService.GetAccessToken(aspspId, api.ScopeAccounts)
+	accessToken, err := f.tokenService.GetAccessToken(ctx, aspspId, api.ScopeAccounts)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
@@ -160,35 +177,36 @@ func (f facade) GetConsent(cid, aspspId string) (string, error) {
 	endpointAccountAccessConsent, _ := f.cfg.FindByConfigName(aspspId, api.EndpointAccountAccessConsent)
 	fapiFinancialId, _ := f.cfg.FindByConfigName(aspspId, api.FapiFinancialId)
 
-	httpClient, err := client.NewSecureHttpClient(endpointAccountAccessConsent+"/"+consentId,
-		f.setHeader(accessToken, fapiFinancialId))
+	httpClient, err := client.NewSecureHttpClient(f.cfg, aspspId, api.EndpointAccountAccessConsent, endpointAccountAccessConsent+"/"+consentId,
+		f.setHeader(ctx, accessToken, fapiFinancialId))
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Get(nil)
+	resp, err := httpClient.Get(ctx, nil)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
+	client.RecordInteraction(ctx, resp)
 
 	if resp.StatusCode == 200 {
 		return resp.Body, err
 	} else {
-		return "", fmt.Errorf("unexpected result from the token facade. response: %v", *resp)
+		return "", errors.WithMessage(api.NewAspspError(resp.StatusCode, resp.Body), errMessage)
 	}
 }
 
-func (f facade) DeleteConsent(consentId string) (string, error) {
+func (f facade) DeleteConsent(ctx context.Context, consentId string) (string, error) {
+	ctx, span := tracing.Start(ctx, "consent.DeleteConsent", tracing.KindInternal)
+	defer span.End()
+
 	panic("implement me")
 }
 
-func (f facade) setHeader(obAccessToken, xFapiFinancialId string) http.Header {
-	header := http.Header{}
+func (f facade) setHeader(ctx context.Context, obAccessToken, xFapiFinancialId string) http.Header {
+	header := client.NewFapiHeader(ctx, xFapiFinancialId)
 	header.Set(api.Authorization, "Bearer "+obAccessToken)
-	header.Set(api.Accept, api.ApplicationJson)
 	header.Set(api.ContentType, api.ApplicationJson)
-	header.Set(api.CacheControl, "no-cache")
-	header.Set(api.XFapiFinancialId, xFapiFinancialId)
 	header.Set(api.XIdempotencyKey, uuid.New().String())
 
 	return header
@@ -1,129 +1,124 @@
 package consent
 
 import (
-	"database/sql"
 	"encoding/json"
-	"fmt"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
 	"github.com/kaanaktas/openbanking-accountinformation/pkg/session"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/gommon/log"
 	"net/http"
-	"strings"
+	"time"
 )
 
+var errInvalidConsent = api.NewError(http.StatusBadRequest, api.FieldInvalid, "invalid request. couldn't retrieve the consent details")
+
 func RegisterHandler(e *echo.Echo, sessionService session.Service, service ServiceRead, facadeService Facade) {
-	e.GET("/:aspspId/internal/consent/active", retrieveActiveConsent(sessionService, service))
-	e.POST("/:aspspId/account-access-consents/reference/:trackingId", createConsent(sessionService, facadeService))
-	e.GET("/:aspspId/account-access-consents/:cid", getConsent(facadeService))
+	for _, v := range api.Versions {
+		g := e.Group(v.Prefix)
+		g.GET("/:aspspId/internal/consent/active", retrieveActiveConsent(sessionService, service, v), v.Middleware...)
+		g.POST("/:aspspId/account-access-consents/reference/:trackingId", createConsent(sessionService, facadeService, v), v.Middleware...)
+		g.GET("/:aspspId/account-access-consents/:cid", getConsent(facadeService, v), v.Middleware...)
+	}
 }
 
-func retrieveActiveConsent(sessionService session.Service, service ServiceRead) echo.HandlerFunc {
+func retrieveActiveConsent(sessionService session.Service, service ServiceRead, v api.Version) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		rid := c.Response().Header().Get(echo.HeaderXRequestID)
 		aspspId := c.Param("aspspId")
 		if aspspId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "aspspId can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty")
 		}
 
-		bearerToken, err := extractBearerToken(c.Request().Header.Get(api.Authorization))
+		bearerToken, err := api.BearerToken(c.Request().Header.Get(api.Authorization))
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
-		sessionResp, err := sessionService.FindByInternalAccessToken(bearerToken)
+		sessionResp, err := sessionService.FindByInternalAccessToken(c.Request().Context(), bearerToken)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
-		if sessionResp == nil {
-			return c.JSON(http.StatusNotFound, api.JsonResponse(rid, "couldn't find the session"))
-		}
-
-		consents, err := service.FindAuthorisedConsentByUserIdAndTppId(sessionResp.UserId, sessionResp.TppId)
+		consents, err := service.FindAuthorisedConsentByUserIdAndTppId(c.Request().Context(), sessionResp.UserId, sessionResp.TppId)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
 		if consents == nil {
-			return c.JSON(http.StatusNotFound, api.JsonResponse(rid, "couldn't find any consent"))
+			return ErrConsentNotFound
+		}
+		if v.Typed {
+			return c.JSON(http.StatusOK, consents)
 		}
 
 		jsonPayload, err := json.Marshal(consents)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
-		return c.JSON(http.StatusOK, string(jsonPayload))
+		return api.JsonString(c, string(jsonPayload))
 	}
 }
 
-func createConsent(sessionService session.Service, proxy Facade) echo.HandlerFunc {
+func createConsent(sessionService session.Service, proxy Facade, v api.Version) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		rid := c.Response().Header().Get(echo.HeaderXRequestID)
 		aspspId := c.Param("aspspId")
 		if aspspId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "aspspId can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty")
 		}
 		trackingId := c.Param("trackingId")
 		if trackingId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "reference can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "reference", "reference can't be empty")
 		}
 
-		bearerToken, err := extractBearerToken(c.Request().Header.Get(api.Authorization))
+		bearerToken, err := api.BearerToken(c.Request().Header.Get(api.Authorization))
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
-		sessionData, err := sessionService.FindByInternalAccessToken(bearerToken)
+		sessionData, err := sessionService.FindByInternalAccessToken(c.Request().Context(), bearerToken)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
 		consent := &ObReadConsent{}
 		if err := c.Bind(consent); err != nil {
-			log.Error(err)
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "invalid request. couldn't retrieve the consent details"))
+			logger.Error("couldn't bind the consent", logger.String("rid", rid), logger.Err(err))
+			return errInvalidConsent.Wrap(err)
+		}
+		if err := consent.Validate(time.Now()); err != nil {
+			return err
 		}
 
-		res, err := proxy.CreateConsent(sessionData.ReferenceId, trackingId, aspspId, consent)
+		res, err := proxy.CreateConsent(c.Request().Context(), sessionData.ReferenceId, trackingId, aspspId, consent)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
+		}
+		if v.Typed {
+			return c.JSON(http.StatusOK, AuthorisationRedirect{RedirectUrl: res})
 		}
 
-		return c.JSON(http.StatusOK, res)
+		return api.JsonString(c, res)
 	}
 }
 
-func getConsent(proxy Facade) echo.HandlerFunc {
+func getConsent(proxy Facade, v api.Version) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		rid := c.Response().Header().Get(echo.HeaderXRequestID)
 		aspspId := c.Param("aspspId")
 		if aspspId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "aspspId can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "aspspId", "aspspId can't be empty")
 		}
 
 		cid := c.Param("cid")
 		if cid == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "cid can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "cid", "cid can't be empty")
 		}
 
-		res, err := proxy.GetConsent(cid, aspspId)
-		switch err {
-		case sql.ErrNoRows:
-			return c.JSON(http.StatusNotFound, api.JsonResponse(rid, "couldn't find the consent"))
-		case nil:
-			return c.JSON(http.StatusOK, res)
-		default:
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+		res, err := proxy.GetConsent(c.Request().Context(), cid, aspspId)
+		if err != nil {
+			return err
 		}
-	}
-}
 
-func extractBearerToken(authorizationHeader string) (string, error) {
-	if strings.HasPrefix(authorizationHeader, "Bearer") {
-		return authorizationHeader[7:], nil
-	} else {
-		return "", fmt.Errorf("unexpected error while extracting the bearerToken")
+		return v.Respond(c, res)
 	}
 }
@@ -2,8 +2,8 @@ package consent
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
@@ -13,7 +13,7 @@ import (
 	"net/url"
 )
 
-func authorizeConsentId(clientId, iss, aud, endpointAuthorize, scope, state, nonce, consentId, redirectUrl string) (string, error) {
+func authorizeConsentId(ctx context.Context, cfg client.Config, aspspId, clientId, iss, aud, endpointAuthorize, scope, state, nonce, consentId, redirectUrl string, signingMethod jwt.SigningMethod) (string, error) {
 	acr := Acr{
 		Value:     "urn:openbanking:psd2:sca",
 		Essential: true,
@@ -52,7 +52,7 @@ func authorizeConsentId(clientId, iss, aud, endpointAuthorize, scope, state, non
 		return "", errors.WithMessage(err, errMessage)
 	}
 
-	signedJwt, err := security.GenerateJwtWithJsonString(bytes.NewBuffer(jsonData).String(), jwt.SigningMethodPS256)
+	signedJwt, err := security.GenerateJwtWithJsonString(bytes.NewBuffer(jsonData).String(), signingMethod)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
@@ -68,18 +68,18 @@ func authorizeConsentId(clientId, iss, aud, endpointAuthorize, scope, state, non
 
 	header := http.Header{}
 	header.Set(api.CacheControl, "no-cache")
-	httpClient, err := client.NewSecureHttpClient(endpointAuthorize, header)
+	httpClient, err := client.NewSecureHttpClient(cfg, aspspId, api.EndpointAuthorize, endpointAuthorize, header)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Get(parameters)
+	resp, err := httpClient.Get(ctx, parameters)
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
 
 	if resp.StatusCode != 302 || resp.Header.Get(api.Location) == "" {
-		return "", fmt.Errorf("unexpected error while calling authorizeConsentId(). resp: %v", *resp)
+		return "", errors.WithMessage(api.NewAspspError(resp.StatusCode, resp.Body), errMessage)
 	}
 
 	return resp.Header.Get(api.Location), nil
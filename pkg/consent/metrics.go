@@ -0,0 +1,14 @@
+package consent
+
+import "github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+
+//StatusNone is the from status of a newly created consent.
+const StatusNone = "None"
+
+var statusTransitions = metrics.NewCounterVec("consent_status_transitions_total",
+	"Consent status changes.", "from", "to")
+
+//RecordStatusTransition counts a consent status change, e.g. AwaitingAuthorisation to Authorised.
+func RecordStatusTransition(from, to string) {
+	statusTransitions.Inc(from, to)
+}
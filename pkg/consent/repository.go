@@ -1,25 +1,27 @@
 package consent
 
 import (
+	"context"
 	"database/sql"
 	"github.com/jmoiron/sqlx"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"github.com/pkg/errors"
 	"time"
 )
 
 type RepositoryRead interface {
-	findByTrackingId(trackingId string) (*Consent, error)
-	findConsentByCidAndStatus(cid, status string) (*Consent, error)
-	findConsentByUserIdAndTppIdAndStatus(userId, tppId, status string) ([]Consent, error)
-	findByCid(cid string) (*Consent, error)
+	findByTrackingId(ctx context.Context, trackingId string) (*Consent, error)
+	findConsentByCidAndStatus(ctx context.Context, cid, status string) (*Consent, error)
+	findConsentByUserIdAndTppIdAndStatus(ctx context.Context, userId, tppId, status string) ([]Consent, error)
+	findByCid(ctx context.Context, cid string) (*Consent, error)
 }
 
 type RepositoryWrite interface {
-	saveConsent(consent *Consent) error
-	saveToken(token *Token) error
-	invalidateAuthorisedTokenByConsentTid(tid int64, status string) error
-	changeConsentStateByCid(cid, status string) error
+	saveConsent(ctx context.Context, consent *Consent) error
+	saveToken(ctx context.Context, token *Token) error
+	invalidateAuthorisedTokenByConsentTid(ctx context.Context, tid int64, status string) error
+	changeConsentStateByCid(ctx context.Context, cid, status string) error
 }
 
 type repositoryRead struct {
@@ -38,10 +40,13 @@ func NewRepositoryWrite(db *sqlx.DB) RepositoryWrite {
 	return &repositoryWrite{db: db}
 }
 
-func (r repositoryRead) findByTrackingId(trackingId string) (*Consent, error) {
+func (r repositoryRead) findByTrackingId(ctx context.Context, trackingId string) (*Consent, error) {
+	ctx, span := store.StartSpan(ctx, "findByTrackingId")
+	defer span.End()
+
 	var consentToken []TokensInConsent
 
-	err := r.db.Select(&consentToken, `SELECT ctt.id as token_tid, ctt.*, ct.* from consent_table ct INNER JOIN consent_token_table ctt on ct.id = ctt.consent_tid WHERE ct.tracking_id = $1`, trackingId)
+	err := r.db.SelectContext(ctx, &consentToken, `SELECT ctt.id as token_tid, ctt.*, ct.* from consent_table ct INNER JOIN consent_token_table ctt on ct.id = ctt.consent_tid WHERE ct.tracking_id = $1`, trackingId)
 	if err == sql.ErrNoRows {
 		return nil, err
 	}
@@ -62,9 +67,12 @@ func (r repositoryRead) findByTrackingId(trackingId string) (*Consent, error) {
 	return &consent, nil
 }
 
-func (r repositoryRead) findByCid(cid string) (*Consent, error) {
+func (r repositoryRead) findByCid(ctx context.Context, cid string) (*Consent, error) {
+	ctx, span := store.StartSpan(ctx, "findByCid")
+	defer span.End()
+
 	var consent Consent
-	err := r.db.Get(&consent, `SELECT ct.* FROM consent_table ct WHERE ct.id = $1`, cid)
+	err := r.db.GetContext(ctx, &consent, `SELECT ct.* FROM consent_table ct WHERE ct.id = $1`, cid)
 	if err != nil {
 		return nil, err
 	}
@@ -72,9 +80,12 @@ func (r repositoryRead) findByCid(cid string) (*Consent, error) {
 	return &consent, nil
 }
 
-func (r repositoryRead) findConsentByCidAndStatus(cid, status string) (*Consent, error) {
+func (r repositoryRead) findConsentByCidAndStatus(ctx context.Context, cid, status string) (*Consent, error) {
+	ctx, span := store.StartSpan(ctx, "findConsentByCidAndStatus")
+	defer span.End()
+
 	var consentToken []TokensInConsent
-	err := r.db.Select(&consentToken, `SELECT ct.*, ctt.id as token_tid, ctt.* from consent_table ct LEFT JOIN consent_token_table ctt on ct.id = ctt.consent_tid AND ctt.token_status = 'Authorised' WHERE ct.consent_status = $1 AND ct.id = $2`, status, cid)
+	err := r.db.SelectContext(ctx, &consentToken, `SELECT ct.*, ctt.id as token_tid, ctt.* from consent_table ct LEFT JOIN consent_token_table ctt on ct.id = ctt.consent_tid AND ctt.token_status = 'Authorised' WHERE ct.consent_status = $1 AND ct.id = $2`, status, cid)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in findConsentByCidAndStatus()")
 	}
@@ -96,9 +107,12 @@ func (r repositoryRead) findConsentByCidAndStatus(cid, status string) (*Consent,
 	return &consent, nil
 }
 
-func (r repositoryRead) findConsentByUserIdAndTppIdAndStatus(userId, tppId, status string) ([]Consent, error) {
+func (r repositoryRead) findConsentByUserIdAndTppIdAndStatus(ctx context.Context, userId, tppId, status string) ([]Consent, error) {
+	ctx, span := store.StartSpan(ctx, "findConsentByUserIdAndTppIdAndStatus")
+	defer span.End()
+
 	var consents []Consent
-	err := r.db.Select(&consents, `SELECT ct.id as id, ct.* FROM consent_table ct INNER JOIN session_table st on ct.session_reference_id = st.reference_id
+	err := r.db.SelectContext(ctx, &consents, `SELECT ct.id as id, ct.* FROM consent_table ct INNER JOIN session_table st on ct.session_reference_id = st.reference_id
 													WHERE st.user_id = $1 AND st.tpp_id = $2 AND ct.consent_status = $3`, userId, tppId, status)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in findConsentByUserIdAndTppIdAndStatus()")
@@ -107,10 +121,13 @@ func (r repositoryRead) findConsentByUserIdAndTppIdAndStatus(userId, tppId, stat
 	return consents, nil
 }
 
-func (r repositoryWrite) saveConsent(consent *Consent) error {
-	tx := r.db.MustBegin()
+func (r repositoryWrite) saveConsent(ctx context.Context, consent *Consent) error {
+	ctx, span := store.StartSpan(ctx, "saveConsent")
+	defer span.End()
 
-	stmt, err := tx.PrepareNamed(`INSERT INTO consent_table(aspsp_id, consent_expiration_date_time, consent_id, consent_status, consent_status_update_date_time, 
+	tx := r.db.MustBeginTx(ctx, nil)
+
+	stmt, err := tx.PrepareNamedContext(ctx, `INSERT INTO consent_table(aspsp_id, consent_expiration_date_time, consent_id, consent_status, consent_status_update_date_time, 
                           consent_transaction_from_date_time, consent_transaction_to_date_time, consent_type, create_date_time, session_reference_id, tracking_id, update_date_time) 
                           VALUES (:aspsp_id, :consent_expiration_date_time, :consent_id, :consent_status, :consent_status_update_date_time, :consent_transaction_from_date_time, 
                                   :consent_transaction_to_date_time, :consent_type, :create_date_time, :session_reference_id, :tracking_id, :update_date_time) RETURNING id`)
@@ -120,7 +137,7 @@ func (r repositoryWrite) saveConsent(consent *Consent) error {
 	}
 
 	var lastInsertedId int64
-	err = stmt.Get(&lastInsertedId, consent)
+	err = stmt.GetContext(ctx, &lastInsertedId, consent)
 	if err != nil {
 		tx.Rollback()
 		return errors.WithMessage(err, "error in saveConsent() while trying to retrieve lastInsertedId")
@@ -130,7 +147,7 @@ func (r repositoryWrite) saveConsent(consent *Consent) error {
 
 	for _, token := range tokens {
 		token.ConsentTid = &lastInsertedId
-		_, err = tx.NamedExec(`INSERT INTO consent_token_table(access_token, create_date_time, expires_in, resource_access_token, resource_refresh_token, token_expiration_date_time, 
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO consent_token_table(access_token, create_date_time, expires_in, resource_access_token, resource_refresh_token, token_expiration_date_time, 
                                 token_status, update_date_time, consent_tid) VALUES (:access_token, :create_date_time, :expires_in, :resource_access_token, 
                                                                                      :resource_refresh_token, :token_expiration_date_time, :token_status, :update_date_time, :consent_tid)`, token)
 
@@ -148,8 +165,11 @@ func (r repositoryWrite) saveConsent(consent *Consent) error {
 	return nil
 }
 
-func (r repositoryWrite) saveToken(token *Token) error {
-	_, err := r.db.NamedExec("INSERT INTO consent_token_table(access_token, create_date_time, expires_in, resource_access_token, resource_refresh_token, "+
+func (r repositoryWrite) saveToken(ctx context.Context, token *Token) error {
+	ctx, span := store.StartSpan(ctx, "saveToken")
+	defer span.End()
+
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO consent_token_table(access_token, create_date_time, expires_in, resource_access_token, resource_refresh_token, "+
 		"token_expiration_date_time, token_status, update_date_time, consent_tid) "+
 		"VALUES (:access_token, :create_date_time, :expires_in, :resource_access_token, :resource_refresh_token, "+
 		":token_expiration_date_time, :token_status, :update_date_time, :consent_tid)", token)
@@ -160,8 +180,11 @@ func (r repositoryWrite) saveToken(token *Token) error {
 	return nil
 }
 
-func (r repositoryWrite) invalidateAuthorisedTokenByConsentTid(tid int64, status string) error {
-	_, err := r.db.NamedExec(`UPDATE consent_token_table SET token_status=:status WHERE token_status = 'Authorised' AND consent_tid=:tid`, map[string]interface{}{"status": status, "tid": tid})
+func (r repositoryWrite) invalidateAuthorisedTokenByConsentTid(ctx context.Context, tid int64, status string) error {
+	ctx, span := store.StartSpan(ctx, "invalidateAuthorisedTokenByConsentTid")
+	defer span.End()
+
+	_, err := r.db.NamedExecContext(ctx, `UPDATE consent_token_table SET token_status=:status WHERE token_status = 'Authorised' AND consent_tid=:tid`, map[string]interface{}{"status": status, "tid": tid})
 	if err != nil {
 		return errors.WithMessage(err, "error in invalidateAuthorisedTokenByConsentTid()")
 	}
@@ -169,9 +192,12 @@ func (r repositoryWrite) invalidateAuthorisedTokenByConsentTid(tid int64, status
 	return nil
 }
 
-func (r repositoryWrite) changeConsentStateByCid(cid, status string) error {
+func (r repositoryWrite) changeConsentStateByCid(ctx context.Context, cid, status string) error {
+	ctx, span := store.StartSpan(ctx, "changeConsentStateByCid")
+	defer span.End()
+
 	updateTime := api.ObTime(time.Now())
-	_, err := r.db.NamedExec(`UPDATE consent_table SET consent_status=:status, update_date_time=:updateTime WHERE id=:cid`,
+	_, err := r.db.NamedExecContext(ctx, `UPDATE consent_table SET consent_status=:status, update_date_time=:updateTime WHERE id=:cid`,
 		map[string]interface{}{"status": status, "cid": cid, "updateTime": updateTime})
 
 	if err != nil {
@@ -1,6 +1,7 @@
 package consent
 
 import (
+	"context"
 	"github.com/jmoiron/sqlx"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
@@ -92,7 +93,7 @@ func Test_repository_SaveConsent(t *testing.T) {
 			r := repositoryWrite{
 				db: tt.fields.db,
 			}
-			if err := r.saveConsent(tt.args.consent); (err != nil) != tt.wantErr {
+			if err := r.saveConsent(context.Background(), tt.args.consent); (err != nil) != tt.wantErr {
 				t.Errorf("saveConsent() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -126,7 +127,7 @@ func Test_repository_findByTrackingId(t *testing.T) {
 			r := repositoryRead{
 				db: tt.fields.db,
 			}
-			got, err := r.findByTrackingId(tt.args.trackingId)
+			got, err := r.findByTrackingId(context.Background(), tt.args.trackingId)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findByTrackingId() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -167,7 +168,7 @@ func Test_repository_findConsentByCidAndStatus(t *testing.T) {
 			r := repositoryRead{
 				db: tt.fields.db,
 			}
-			got, err := r.findConsentByCidAndStatus(tt.args.cid, tt.args.status)
+			got, err := r.findConsentByCidAndStatus(context.Background(), tt.args.cid, tt.args.status)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findConsentByCidAndStatus() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -213,7 +214,7 @@ func Test_repository_findConsentByUserIdAndTppIdAndStatus(t *testing.T) {
 			r := repositoryRead{
 				db: tt.fields.db,
 			}
-			got, err := r.findConsentByUserIdAndTppIdAndStatus(tt.args.userId, tt.args.tppId, tt.args.status)
+			got, err := r.findConsentByUserIdAndTppIdAndStatus(context.Background(), tt.args.userId, tt.args.tppId, tt.args.status)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findConsentByUserIdAndTppIdAndStatus() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -252,7 +253,7 @@ func Test_repository_findByCid(t *testing.T) {
 			r := repositoryRead{
 				db: tt.fields.db,
 			}
-			got, err := r.findByCid(tt.args.cid)
+			got, err := r.findByCid(context.Background(), tt.args.cid)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findByCid() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,22 +1,32 @@
 package consent
 
 import (
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
 	"github.com/pkg/errors"
+	"net/http"
 	"time"
 )
 
+var (
+	//ErrConsentNotFound is returned for the cids and references which don't have a consent.
+	ErrConsentNotFound = api.NewError(http.StatusNotFound, api.ResourceNotFound, "couldn't find the consent")
+	//ErrReferenceUsed is returned when a consent has already been created with the reference.
+	ErrReferenceUsed = api.NewError(http.StatusConflict, api.RulesDuplicateReference, "reference has already been used. please try a new one")
+)
+
 type ServiceRead interface {
-	FindAuthorisedConsentByUserIdAndTppId(userId, tppId string) ([]ActiveConsent, error)
-	FindByCid(consentId string) (*Consent, error)
-	FindConsentByCidAndStatus(cid, status string) (*Consent, error)
-	FindByTrackingId(trackingId string) (*Consent, error)
+	FindAuthorisedConsentByUserIdAndTppId(ctx context.Context, userId, tppId string) ([]ActiveConsent, error)
+	FindByCid(ctx context.Context, consentId string) (*Consent, error)
+	FindConsentByCidAndStatus(ctx context.Context, cid, status string) (*Consent, error)
+	FindByTrackingId(ctx context.Context, trackingId string) (*Consent, error)
 }
 type ServiceWrite interface {
-	ChangeConsentStateByCid(cid, status string) error
-	InvalidateAuthorisedTokenByConsentTid(tid int64, status string) error
-	SaveToken(token *Token) error
-	SaveConsent(consent *Consent) error
+	ChangeConsentStateByCid(ctx context.Context, cid, status string) error
+	InvalidateAuthorisedTokenByConsentTid(ctx context.Context, tid int64, status string) error
+	SaveToken(ctx context.Context, token *Token) error
+	SaveConsent(ctx context.Context, consent *Consent) error
 }
 
 type serviceRead struct {
@@ -39,36 +49,65 @@ func NewServiceWrite(repo RepositoryWrite) ServiceWrite {
 	}
 }
 
-func (sw serviceWrite) SaveConsent(consent *Consent) error {
-	return sw.repo.saveConsent(consent)
+func (sw serviceWrite) SaveConsent(ctx context.Context, consent *Consent) error {
+	ctx, span := tracing.Start(ctx, "consent.SaveConsent", tracing.KindInternal)
+	defer span.End()
+
+	if err := sw.repo.saveConsent(ctx, consent); err != nil {
+		return err
+	}
+
+	RecordStatusTransition(StatusNone, consent.ConsentStatus)
+	return nil
 }
 
-func (sw serviceWrite) SaveToken(token *Token) error {
-	return sw.repo.saveToken(token)
+func (sw serviceWrite) SaveToken(ctx context.Context, token *Token) error {
+	ctx, span := tracing.Start(ctx, "consent.SaveToken", tracing.KindInternal)
+	defer span.End()
+
+	return sw.repo.saveToken(ctx, token)
 }
 
-func (sw serviceWrite) InvalidateAuthorisedTokenByConsentTid(tid int64, status string) error {
-	return sw.repo.invalidateAuthorisedTokenByConsentTid(tid, status)
+func (sw serviceWrite) InvalidateAuthorisedTokenByConsentTid(ctx context.Context, tid int64, status string) error {
+	ctx, span := tracing.Start(ctx, "consent.InvalidateAuthorisedTokenByConsentTid", tracing.KindInternal)
+	defer span.End()
+
+	return sw.repo.invalidateAuthorisedTokenByConsentTid(ctx, tid, status)
 }
 
-func (sw serviceWrite) ChangeConsentStateByCid(cid, status string) error {
-	return sw.repo.changeConsentStateByCid(cid, status)
+func (sw serviceWrite) ChangeConsentStateByCid(ctx context.Context, cid, status string) error {
+	ctx, span := tracing.Start(ctx, "consent.ChangeConsentStateByCid", tracing.KindInternal)
+	defer span.End()
+
+	return sw.repo.changeConsentStateByCid(ctx, cid, status)
 }
 
-func (sr serviceRead) FindConsentByCidAndStatus(cid, status string) (*Consent, error) {
-	return sr.repo.findConsentByCidAndStatus(cid, status)
+func (sr serviceRead) FindConsentByCidAndStatus(ctx context.Context, cid, status string) (*Consent, error) {
+	ctx, span := tracing.Start(ctx, "consent.FindConsentByCidAndStatus", tracing.KindInternal)
+	defer span.End()
+
+	return sr.repo.findConsentByCidAndStatus(ctx, cid, status)
 }
 
-func (sr serviceRead) FindByCid(cid string) (*Consent, error) {
-	return sr.repo.findByCid(cid)
+func (sr serviceRead) FindByCid(ctx context.Context, cid string) (*Consent, error) {
+	ctx, span := tracing.Start(ctx, "consent.FindByCid", tracing.KindInternal)
+	defer span.End()
+
+	return sr.repo.findByCid(ctx, cid)
 }
 
-func (sr serviceRead) FindByTrackingId(trackingId string) (*Consent, error) {
-	return sr.repo.findByTrackingId(trackingId)
+func (sr serviceRead) FindByTrackingId(ctx context.Context, trackingId string) (*Consent, error) {
+	ctx, span := tracing.Start(ctx, "consent.FindByTrackingId", tracing.KindInternal)
+	defer span.End()
+
+	return sr.repo.findByTrackingId(ctx, trackingId)
 }
 
-func (sr serviceRead) FindAuthorisedConsentByUserIdAndTppId(userId, tppId string) ([]ActiveConsent, error) {
-	consents, err := sr.repo.findConsentByUserIdAndTppIdAndStatus(userId, tppId, api.Authorised)
+func (sr serviceRead) FindAuthorisedConsentByUserIdAndTppId(ctx context.Context, userId, tppId string) ([]ActiveConsent, error) {
+	ctx, span := tracing.Start(ctx, "consent.FindAuthorisedConsentByUserIdAndTppId", tracing.KindInternal)
+	defer span.End()
+
+	consents, err := sr.repo.findConsentByUserIdAndTppIdAndStatus(ctx, userId, tppId, api.Authorised)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package consent
 
 import (
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
@@ -35,8 +36,8 @@ func Test_service_CreateConsent(t *testing.T) {
 			fields{
 				serviceRead:  NewServiceRead(NewRepositoryRead(store.LoadDBConnection())),
 				serviceWrite: NewServiceWrite(NewRepositoryWrite(store.LoadDBConnection())),
-				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())),
-				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory()),
+				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)),
+				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration),
 			},
 			args{
 				sessionReferenceId: "",
@@ -59,7 +60,7 @@ func Test_service_CreateConsent(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := NewFacade(tt.fields.serviceRead, tt.fields.serviceWrite, tt.fields.tokenService, tt.fields.cfg)
 
-			got, err := s.CreateConsent(tt.args.sessionReferenceId, tt.args.trackingId, tt.args.aspspId, tt.args.consent)
+			got, err := s.CreateConsent(context.Background(), tt.args.sessionReferenceId, tt.args.trackingId, tt.args.aspspId, tt.args.consent)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateConsent() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -116,7 +117,7 @@ func Test_service_FindConsentByUserIdAndTppIdAndStatus(t *testing.T) {
 			s := serviceRead{
 				repo: tt.fields.repo,
 			}
-			got, err := s.FindAuthorisedConsentByUserIdAndTppId(tt.args.userId, tt.args.tppId)
+			got, err := s.FindAuthorisedConsentByUserIdAndTppId(context.Background(), tt.args.userId, tt.args.tppId)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FindConsentByUserIdAndTppIdAndStatus() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -151,8 +152,8 @@ func Test_facade_GetConsent(t *testing.T) {
 			fields{
 				serviceRead:  NewServiceRead(NewRepositoryRead(store.LoadDBConnection())),
 				serviceWrite: NewServiceWrite(NewRepositoryWrite(store.LoadDBConnection())),
-				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())),
-				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory()),
+				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)),
+				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration),
 			},
 			args{
 				cid:     "1",
@@ -166,8 +167,8 @@ func Test_facade_GetConsent(t *testing.T) {
 			fields{
 				serviceRead:  NewServiceRead(NewRepositoryRead(store.LoadDBConnection())),
 				serviceWrite: NewServiceWrite(NewRepositoryWrite(store.LoadDBConnection())),
-				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())),
-				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory()),
+				tokenService: token.NewService(cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)),
+				cfg:          cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration),
 			},
 			args{
 				cid:     "-1",
@@ -185,7 +186,7 @@ func Test_facade_GetConsent(t *testing.T) {
 				tokenService: tt.fields.tokenService,
 				cfg:          tt.fields.cfg,
 			}
-			got, err := p.GetConsent(tt.args.cid, tt.args.aspspId)
+			got, err := p.GetConsent(context.Background(), tt.args.cid, tt.args.aspspId)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetConsent() error = %v, wantErr %v", err, tt.wantErr)
 				return
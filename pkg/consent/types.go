@@ -109,6 +109,11 @@ type AuthorisedConsent struct {
 	Claims       Claims `json:"claims"`
 }
 
+//AuthorisationRedirect is the v2 response of a created consent. The PSU is redirected to RedirectUrl to authorise it.
+type AuthorisationRedirect struct {
+	RedirectUrl string `json:"redirectUrl"`
+}
+
 type ActiveConsent struct {
 	ConsentTid int64  `json:"consentTid"`
 	AspspId    string `json:"aspspId"`
@@ -0,0 +1,167 @@
+package consent
+
+import (
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//permissions are the codes of the v3.1 account access consents.
+var permissions = map[string]bool{
+	"ReadAccountsBasic":           true,
+	"ReadAccountsDetail":          true,
+	"ReadBalances":                true,
+	"ReadBeneficiariesBasic":      true,
+	"ReadBeneficiariesDetail":     true,
+	"ReadDirectDebits":            true,
+	"ReadOffers":                  true,
+	"ReadPAN":                     true,
+	"ReadParty":                   true,
+	"ReadPartyPSU":                true,
+	"ReadProducts":                true,
+	"ReadScheduledPaymentsBasic":  true,
+	"ReadScheduledPaymentsDetail": true,
+	"ReadStandingOrdersBasic":     true,
+	"ReadStandingOrdersDetail":    true,
+	"ReadStatementsBasic":         true,
+	"ReadStatementsDetail":        true,
+	"ReadTransactionsBasic":       true,
+	"ReadTransactionsCredits":     true,
+	"ReadTransactionsDebits":      true,
+	"ReadTransactionsDetail":      true,
+}
+
+//dependencies are the permissions which need at least one of the listed permissions.
+//Transactions are only returned if both their detail level and their direction are requested.
+var dependencies = map[string][]string{
+	"ReadTransactionsBasic":   {"ReadTransactionsCredits", "ReadTransactionsDebits"},
+	"ReadTransactionsDetail":  {"ReadTransactionsCredits", "ReadTransactionsDebits"},
+	"ReadTransactionsCredits": {"ReadTransactionsBasic", "ReadTransactionsDetail"},
+	"ReadTransactionsDebits":  {"ReadTransactionsBasic", "ReadTransactionsDetail"},
+}
+
+//FieldError is an invalid field of the request. Field is the json path, e.g. Data.Permissions[1]
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	code    api.ErrorCode
+}
+
+//ValidationError lists every invalid field of a consent request, so they can be fixed at once.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, 0, len(v.Errors))
+	for _, e := range v.Errors {
+		messages = append(messages, e.Field+" "+e.Message)
+	}
+
+	return fmt.Sprintf("invalid consent: %v", strings.Join(messages, ", "))
+}
+
+func (v *ValidationError) ApiError() *api.Error {
+	details := make([]api.ErrorDetail, 0, len(v.Errors))
+	for _, e := range v.Errors {
+		details = append(details, api.ErrorDetail{ErrorCode: e.code, Message: e.Message, Path: e.Field})
+	}
+
+	return api.NewError(http.StatusBadRequest, api.FieldInvalid, v.Error()).WithDetails(details...)
+}
+
+func (v *ValidationError) add(code api.ErrorCode, field, message string) {
+	v.Errors = append(v.Errors, FieldError{Field: field, Message: message, code: code})
+}
+
+//Validate checks the consent before it's sent to the aspsp. now is the time ExpirationDateTime is compared with.
+func (c *ObReadConsent) Validate(now time.Time) error {
+	verr := &ValidationError{}
+	if c.Data == nil {
+		verr.add(api.FieldMissing, "Data", "is required")
+		return verr
+	}
+
+	validatePermissions(verr, c.Data.Permissions)
+
+	if expiration, ok := parseDateTime(verr, "Data.ExpirationDateTime", c.Data.ExpirationDateTime); ok && !expiration.After(now) {
+		verr.add(api.FieldInvalidDate, "Data.ExpirationDateTime", "must be in the future")
+	}
+	from, fromOk := parseDateTime(verr, "Data.TransactionFromDateTime", c.Data.TransactionFromDateTime)
+	to, toOk := parseDateTime(verr, "Data.TransactionToDateTime", c.Data.TransactionToDateTime)
+	if fromOk && toOk && from.After(to) {
+		verr.add(api.FieldInvalidDate, "Data.TransactionToDateTime", "can't be before TransactionFromDateTime")
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func validatePermissions(verr *ValidationError, requested []string) {
+	if len(requested) == 0 {
+		verr.add(api.FieldMissing, "Data.Permissions", "can't be empty")
+		return
+	}
+
+	seen := make(map[string]bool, len(requested))
+	for i, permission := range requested {
+		field := fmt.Sprintf("Data.Permissions[%d]", i)
+		switch {
+		case !permissions[permission]:
+			verr.add(api.FieldInvalid, field, fmt.Sprintf("%q isn't a valid permission", permission))
+		case seen[permission]:
+			verr.add(api.FieldInvalid, field, fmt.Sprintf("%q is duplicated", permission))
+		}
+		seen[permission] = true
+	}
+
+	for i, permission := range requested {
+		required, found := dependencies[permission]
+		if !found || indexOf(requested, permission) != i {
+			continue
+		}
+		if !hasAny(seen, required) {
+			verr.add(api.FieldInvalid, "Data.Permissions", fmt.Sprintf("%v requires %v", permission, strings.Join(required, " or ")))
+		}
+	}
+}
+
+func hasAny(seen map[string]bool, permissions []string) bool {
+	for _, permission := range permissions {
+		if seen[permission] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//parseDateTime accepts an empty value, as the dates are optional. ok is false if the value is empty or invalid.
+func parseDateTime(verr *ValidationError, field, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		verr.add(api.FieldInvalidDate, field, fmt.Sprintf("%q isn't a RFC3339 date-time, e.g. 2017-04-05T10:43:07+00:00", value))
+		return time.Time{}, false
+	}
+
+	return t, true
+}
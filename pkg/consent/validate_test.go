@@ -0,0 +1,72 @@
+package consent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestObReadConsent_Validate(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		data       *ObReadConsentData
+		wantFields []string
+	}{
+		{"valid", &ObReadConsentData{
+			Permissions:             []string{"ReadAccountsBasic", "ReadTransactionsDetail", "ReadTransactionsCredits"},
+			ExpirationDateTime:      "2022-12-31T21:35:00Z",
+			TransactionFromDateTime: "2018-06-13T19:39:00+01:00",
+			TransactionToDateTime:   "2019-06-13T19:39:00Z",
+		}, nil},
+		{"valid_open_ended", &ObReadConsentData{Permissions: []string{"ReadAccountsBasic"}}, nil},
+		{"missing_data", nil, []string{"Data"}},
+		{"empty_permissions", &ObReadConsentData{}, []string{"Data.Permissions"}},
+		{"unknown_and_duplicated_permissions", &ObReadConsentData{
+			Permissions: []string{"ReadAccountsBasic", "ReadEverything", "ReadAccountsBasic"},
+		}, []string{"Data.Permissions[1]", "Data.Permissions[2]"}},
+		{"transactions_without_direction", &ObReadConsentData{
+			Permissions: []string{"ReadTransactionsBasic"},
+		}, []string{"Data.Permissions"}},
+		{"direction_without_transactions", &ObReadConsentData{
+			Permissions: []string{"ReadTransactionsDebits", "ReadTransactionsCredits"},
+		}, []string{"Data.Permissions", "Data.Permissions"}},
+		{"expired", &ObReadConsentData{
+			Permissions:        []string{"ReadAccountsBasic"},
+			ExpirationDateTime: "2020-12-31T21:35:00Z",
+		}, []string{"Data.ExpirationDateTime"}},
+		{"not_rfc3339", &ObReadConsentData{
+			Permissions:             []string{"ReadAccountsBasic"},
+			ExpirationDateTime:      "2022-12-31",
+			TransactionFromDateTime: "2018-06-13T19:39:00",
+		}, []string{"Data.ExpirationDateTime", "Data.TransactionFromDateTime"}},
+		{"from_after_to", &ObReadConsentData{
+			Permissions:             []string{"ReadAccountsBasic"},
+			TransactionFromDateTime: "2019-06-13T19:39:00Z",
+			TransactionToDateTime:   "2018-06-13T19:39:00Z",
+		}, []string{"Data.TransactionToDateTime"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&ObReadConsent{Data: tt.data, Risk: &ObRisk{}}).Validate(now)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			var fields []string
+			for _, e := range verr.Errors {
+				fields = append(fields, e.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
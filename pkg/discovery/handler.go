@@ -0,0 +1,42 @@
+package discovery
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func RegisterHandler(e *echo.Echo, service Service) {
+	g := e.Group("/admin", configmanager.RequireAdmin())
+	g.GET("/discovery", findResults(service))
+	g.GET("/aspsps/:aspspId/discovery", check(service))
+	g.POST("/aspsps/:aspspId/discovery", apply(service))
+}
+
+func findResults(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, service.Results())
+	}
+}
+
+func check(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		result, err := service.Check(c.Request().Context(), c.Param("aspspId"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func apply(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		result, err := service.Apply(c.Request().Context(), c.Param("aspspId"), configmanager.ChangedBy(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
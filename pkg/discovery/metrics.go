@@ -0,0 +1,6 @@
+package discovery
+
+import "github.com/kaanaktas/openbanking-accountinformation/internal/metrics"
+
+var checks = metrics.NewCounterVec("aspsp_discovery_checks_total",
+	"Scheduled discovery checks by result; ok, drift or error.", "aspsp_id", "result")
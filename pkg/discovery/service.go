@@ -0,0 +1,232 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/pkg/errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const wellKnownPath = "/.well-known/openid-configuration"
+
+var (
+	//ErrNotConfigured is returned for the aspsps which don't have WELL_KNOWN_URL.
+	ErrNotConfigured = api.NewFieldError(api.FieldMissing, api.WellKnownUrl, api.WellKnownUrl+" isn't configured")
+	//ErrFetch is returned when the discovery document can't be fetched or is invalid. The cause is wrapped.
+	ErrFetch = api.NewError(http.StatusBadGateway, api.UnexpectedError, "couldn't fetch the discovery document")
+)
+
+//Service discovers the endpoints of the aspsps from their OpenID Connect discovery document.
+type Service interface {
+	//Check fetches the document and compares it with the stored configs.
+	Check(ctx context.Context, aspspId string) (*Result, error)
+	//Apply fetches the document and stores the discovered configs.
+	Apply(ctx context.Context, aspspId, changedBy string) (*Result, error)
+	//Results returns the last result of each aspsp checked by Watch.
+	Results() []Result
+	//Watch checks every aspsp with WELL_KNOWN_URL every interval until ctx is done, and flags the drifts.
+	Watch(ctx context.Context, interval time.Duration)
+}
+
+type service struct {
+	cfg   configmanager.Service
+	admin configmanager.AdminService
+	mu    *sync.RWMutex
+	last  map[string]Result
+}
+
+func NewService(cfg configmanager.Service, admin configmanager.AdminService) Service {
+	return &service{
+		cfg:   cfg,
+		admin: admin,
+		mu:    &sync.RWMutex{},
+		last:  map[string]Result{},
+	}
+}
+
+func (s service) Check(ctx context.Context, aspspId string) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "discovery.Check", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	result, err := s.check(ctx, aspspId)
+	span.RecordError(err)
+
+	return result, err
+}
+
+func (s service) Apply(ctx context.Context, aspspId, changedBy string) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "discovery.Apply", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	result, err := s.check(ctx, aspspId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := s.admin.SetConfigs(ctx, aspspId, result.Discovered, changedBy); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	result.Drifts = []Drift{}
+	s.store(*result)
+
+	return result, nil
+}
+
+func (s service) check(ctx context.Context, aspspId string) (*Result, error) {
+	aspsp, err := s.admin.FindAspsp(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	location := aspsp.Configs[api.WellKnownUrl]
+	if location == "" {
+		return nil, ErrNotConfigured
+	}
+
+	wellKnown, err := s.fetch(ctx, aspspId, location)
+	if err != nil {
+		return nil, ErrFetch.Wrap(err)
+	}
+
+	discovered := wellKnown.configs()
+	return &Result{
+		AspspId:      aspspId,
+		WellKnownUrl: location,
+		Discovered:   discovered,
+		Drifts:       drifts(aspsp.Configs, discovered),
+		CheckedAt:    time.Now().UTC(),
+	}, nil
+}
+
+//fetch gets the discovery document over the aspsp's TLS transport. location is either the issuer or the
+//url of the document. The issuer of the document has to match the configured issuer.
+func (s service) fetch(ctx context.Context, aspspId, location string) (*WellKnown, error) {
+	location = strings.TrimSuffix(location, "/")
+	issuer := ""
+	if !strings.HasSuffix(location, wellKnownPath) {
+		issuer = location
+		location += wellKnownPath
+	}
+
+	header := http.Header{}
+	header.Set(api.Accept, api.ApplicationJson)
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.WellKnownUrl, location, header)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in fetch()")
+	}
+
+	resp, err := httpClient.Get(ctx, nil)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in fetch()")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error in fetch(). %v returned %v", location, resp.StatusCode)
+	}
+
+	var wellKnown WellKnown
+	if err := json.Unmarshal([]byte(resp.Body), &wellKnown); err != nil {
+		return nil, errors.WithMessage(err, "error in fetch(). invalid discovery document")
+	}
+	if wellKnown.Issuer == "" || wellKnown.TokenEndpoint == "" || wellKnown.AuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("error in fetch(). issuer, token_endpoint and authorization_endpoint are required")
+	}
+	if issuer != "" && strings.TrimSuffix(wellKnown.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("error in fetch(). issuer %v doesn't match %v", wellKnown.Issuer, issuer)
+	}
+
+	return &wellKnown, nil
+}
+
+//drifts returns the discovered configs which aren't stored with the same value, sorted by config name.
+func drifts(stored, discovered map[string]string) []Drift {
+	drifts := []Drift{}
+	for configName, value := range discovered {
+		if stored[configName] != value {
+			drifts = append(drifts, Drift{ConfigName: configName, Stored: stored[configName], Discovered: value})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].ConfigName < drifts[j].ConfigName })
+
+	return drifts
+}
+
+func (s service) Results() []Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]Result, 0, len(s.last))
+	for _, result := range s.last {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].AspspId < results[j].AspspId })
+
+	return results
+}
+
+func (s service) store(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[result.AspspId] = result
+}
+
+func (s service) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		logger.Info("discovery of the aspsp endpoints is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s service) checkAll(ctx context.Context) {
+	aspspIds, err := s.admin.FindAspspIds(ctx)
+	if err != nil {
+		logger.Error("couldn't find the aspsps to discover", logger.Err(err))
+		return
+	}
+
+	for _, aspspId := range aspspIds {
+		if ctx.Err() != nil {
+			return
+		}
+
+		result, err := s.Check(ctx, aspspId)
+		switch {
+		case err == ErrNotConfigured:
+			continue
+		case err != nil:
+			checks.Inc(aspspId, "error")
+			logger.Warn("couldn't discover the aspsp endpoints", logger.String("aspspId", aspspId), logger.Err(err))
+			result = &Result{AspspId: aspspId, Drifts: []Drift{}, Error: err.Error(), CheckedAt: time.Now().UTC()}
+		case len(result.Drifts) > 0:
+			checks.Inc(aspspId, "drift")
+			logger.Warn("stored configs drifted from the discovery document", logger.String("aspspId", aspspId),
+				logger.Any("drifts", result.Drifts))
+		default:
+			checks.Inc(aspspId, "ok")
+		}
+		s.store(*result)
+	}
+}
@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type configStub map[string]string
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if value, found := c[configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+type adminStub struct {
+	configmanager.AdminService
+	aspsps  map[string]map[string]string
+	applied map[string]string
+}
+
+func (a *adminStub) FindAspspIds(ctx context.Context) ([]string, error) {
+	var aspspIds []string
+	for aspspId := range a.aspsps {
+		aspspIds = append(aspspIds, aspspId)
+	}
+
+	return aspspIds, nil
+}
+
+func (a *adminStub) FindAspsp(ctx context.Context, aspspId string) (*configmanager.Aspsp, error) {
+	configs, found := a.aspsps[aspspId]
+	if !found {
+		return nil, configmanager.ErrAspspNotFound
+	}
+
+	return &configmanager.Aspsp{AspspId: aspspId, Configs: configs}, nil
+}
+
+func (a *adminStub) SetConfigs(ctx context.Context, aspspId string, configs map[string]string, changedBy string) error {
+	a.applied = configs
+	return nil
+}
+
+//newTestServer serves the discovery document over TLS and returns the config of its aspsp.
+func newTestServer(t *testing.T) (*httptest.Server, configStub) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wellKnownPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(WellKnown{
+			Issuer:                                 server.URL,
+			AuthorizationEndpoint:                  server.URL + "/authorize",
+			TokenEndpoint:                          server.URL + "/token",
+			JwksUri:                                server.URL + "/jwks",
+			TokenEndpointAuthMethodsSupported:      []string{"tls_client_auth", "private_key_jwt"},
+			RequestObjectSigningAlgValuesSupported: []string{"PS256"},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	caCert := filepath.Join(t.TempDir(), "server.cer")
+	if err := ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("couldn't write the server cert. err: %v", err)
+	}
+
+	return server, configStub{
+		"TLS_CA_CERT_PEM":     caCert,
+		"TLS_CLIENT_CERT_PEM": "../../internal/client/testdata/test_cert.pem",
+		"TLS_CLIENT_KEY_PEM":  "../../internal/client/testdata/test_key.pem",
+	}
+}
+
+func Test_service_Check(t *testing.T) {
+	server, cfg := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		configs    map[string]string
+		wantDrifts []string
+		wantErr    bool
+	}{
+		{"drift_from_stored", map[string]string{
+			"WELL_KNOWN_URL":  server.URL,
+			"ENDPOINT_OAUTH2": server.URL + "/token",
+			"AUD":             server.URL,
+		}, []string{"ENDPOINT_AUTHORIZE", "ENDPOINT_JWKS", "REQUEST_OBJECT_SIGNING_ALG", "TOKEN_ENDPOINT_AUTH_METHODS_SUPPORTED"}, false},
+		{"no_drift", map[string]string{
+			"WELL_KNOWN_URL":                        server.URL + wellKnownPath,
+			"ENDPOINT_OAUTH2":                       server.URL + "/token",
+			"ENDPOINT_AUTHORIZE":                    server.URL + "/authorize",
+			"ENDPOINT_JWKS":                         server.URL + "/jwks",
+			"AUD":                                   server.URL,
+			"TOKEN_ENDPOINT_AUTH_METHODS_SUPPORTED": "tls_client_auth,private_key_jwt",
+			"REQUEST_OBJECT_SIGNING_ALG":            "PS256",
+		}, []string{}, false},
+		{"issuer_mismatch", map[string]string{"WELL_KNOWN_URL": server.URL + "/other"}, nil, true},
+		{"not_configured", map[string]string{"CLIENT_ID": "client"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aspspId := "discovery_" + tt.name
+			s := NewService(cfg, &adminStub{aspsps: map[string]map[string]string{aspspId: tt.configs}})
+
+			got, err := s.Check(context.Background(), aspspId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gotDrifts := []string{}
+			for _, drift := range got.Drifts {
+				gotDrifts = append(gotDrifts, drift.ConfigName)
+			}
+			if !reflect.DeepEqual(gotDrifts, tt.wantDrifts) {
+				t.Errorf("Check() drifts = %v, want %v", gotDrifts, tt.wantDrifts)
+			}
+		})
+	}
+}
+
+func Test_service_Apply(t *testing.T) {
+	server, cfg := newTestServer(t)
+	admin := &adminStub{aspsps: map[string]map[string]string{"discovery_apply": {"WELL_KNOWN_URL": server.URL}}}
+	s := NewService(cfg, admin)
+
+	got, err := s.Apply(context.Background(), "discovery_apply", "operator")
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if admin.applied["ENDPOINT_OAUTH2"] != server.URL+"/token" || admin.applied["AUD"] != server.URL {
+		t.Errorf("Apply() applied = %v", admin.applied)
+	}
+	if _, found := admin.applied["ENDPOINT_REGISTER"]; found {
+		t.Errorf("Apply() shouldn't apply the endpoints which aren't advertised")
+	}
+	if len(got.Drifts) != 0 || len(s.Results()) != 1 {
+		t.Errorf("Apply() result = %v, results = %v", got, s.Results())
+	}
+}
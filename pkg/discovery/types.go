@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"strings"
+	"time"
+)
+
+//WellKnown is the part of the OpenID Connect discovery document which is kept in config_table.
+type WellKnown struct {
+	Issuer                                 string   `json:"issuer"`
+	AuthorizationEndpoint                  string   `json:"authorization_endpoint"`
+	TokenEndpoint                          string   `json:"token_endpoint"`
+	JwksUri                                string   `json:"jwks_uri"`
+	RegistrationEndpoint                   string   `json:"registration_endpoint"`
+	TokenEndpointAuthMethodsSupported      []string `json:"token_endpoint_auth_methods_supported"`
+	RequestObjectSigningAlgValuesSupported []string `json:"request_object_signing_alg_values_supported"`
+}
+
+//configs maps the discovered values to their config names. Values the aspsp doesn't advertise are left out.
+func (w WellKnown) configs() map[string]string {
+	configs := map[string]string{}
+	add := func(configName, value string) {
+		if value != "" {
+			configs[configName] = value
+		}
+	}
+
+	add(api.EndpointOauth2, w.TokenEndpoint)
+	add(api.EndpointAuthorize, w.AuthorizationEndpoint)
+	add(api.EndpointJwks, w.JwksUri)
+	add(api.EndpointRegister, w.RegistrationEndpoint)
+	add(api.Aud, w.Issuer)
+	add(api.TokenEndpointAuthMethods, strings.Join(w.TokenEndpointAuthMethodsSupported, ","))
+	add(api.RequestObjectSigningAlg, strings.Join(w.RequestObjectSigningAlgValuesSupported, ","))
+
+	return configs
+}
+
+//Drift is a config whose stored value differs from the discovered one.
+type Drift struct {
+	ConfigName string `json:"configName"`
+	Stored     string `json:"stored"`
+	Discovered string `json:"discovered"`
+}
+
+//Result is the outcome of the last discovery of an aspsp.
+type Result struct {
+	AspspId      string            `json:"aspspId"`
+	WellKnownUrl string            `json:"wellKnownUrl"`
+	Discovered   map[string]string `json:"discovered,omitempty"`
+	Drifts       []Drift           `json:"drifts"`
+	Error        string            `json:"error,omitempty"`
+	CheckedAt    time.Time         `json:"checkedAt"`
+}
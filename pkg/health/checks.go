@@ -0,0 +1,208 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+//DefaultCheckers returns the checks of the db, redis, the transport certificates and the signing keys.
+//Certificates which expire within HEALTH_CERT_EXPIRY_DAYS are reported as degraded.
+func DefaultCheckers(db *sqlx.DB, s *settings.Settings) map[string]Checker {
+	expiryWarning := time.Duration(s.Health.CertExpiryDays) * 24 * time.Hour
+
+	return map[string]Checker{
+		"database":              DbChecker(db),
+		"redis":                 RedisChecker(cache.PingRedis),
+		"transport_certificate": CertificateChecker(s.Transport.CertPem, s.Transport.KeyPem, expiryWarning),
+		"ca_certificate":        CaCertificateChecker(s.Transport.CaCertPem, expiryWarning),
+		"signing_key":           errorChecker(security.CheckSigner),
+		"internal_sign_key": errorChecker(func() error {
+			_, err := security.GetInternalSignKey()
+			return err
+		}),
+	}
+}
+
+//Aspsps lists the configured aspsps. configmanager.AdminService satisfies it.
+type Aspsps interface {
+	FindAspspIds(ctx context.Context) ([]string, error)
+}
+
+//AspspCertificateCheckers returns the certificate checks of each aspsp which has its own TLS_CLIENT_CERT_PEM or
+//TLS_CA_CERT_PEM. The ones which aren't configured fall back to the transport certificates of DefaultCheckers.
+//The aspsps are listed by each check, so the ones added later are checked too. An expired certificate of an aspsp
+//only affects that aspsp, so it is reported as degraded rather than taking the service out of rotation.
+func AspspCertificateCheckers(aspsps Aspsps, cfg client.Config, s *settings.Settings) CheckerSource {
+	expiryWarning := time.Duration(s.Health.CertExpiryDays) * 24 * time.Hour
+
+	return func(ctx context.Context) map[string]Checker {
+		aspspIds, err := aspsps.FindAspspIds(ctx)
+		if err != nil {
+			return map[string]Checker{"aspsp_certificates": degradedChecker(errorChecker(func() error { return err }))}
+		}
+
+		checkers := map[string]Checker{}
+		for _, aspspId := range aspspIds {
+			for name, checker := range aspspCertificateCheckers(cfg, aspspId, s.Transport.KeyPem, expiryWarning) {
+				checkers["aspsp_"+aspspId+"_"+name] = degradedChecker(checker)
+			}
+		}
+
+		return checkers
+	}
+}
+
+func aspspCertificateCheckers(cfg client.Config, aspspId, defaultKeyPem string, expiryWarning time.Duration) map[string]Checker {
+	checkers := map[string]Checker{}
+	certPem, err := client.FindConfig(cfg, aspspId, api.TlsClientCertPem, "")
+	if err != nil {
+		checkers["transport_certificate"] = errorChecker(func() error { return err })
+	} else if certPem != "" {
+		keyPem, err := client.FindConfig(cfg, aspspId, api.TlsClientKeyPem, defaultKeyPem)
+		if err != nil {
+			checkers["transport_certificate"] = errorChecker(func() error { return err })
+		} else {
+			checkers["transport_certificate"] = CertificateChecker(certPem, keyPem, expiryWarning)
+		}
+	}
+
+	caCertPem, err := client.FindConfig(cfg, aspspId, api.TlsCaCertPem, "")
+	if err != nil {
+		checkers["ca_certificate"] = errorChecker(func() error { return err })
+	} else if caCertPem != "" {
+		checkers["ca_certificate"] = CaCertificateChecker(caCertPem, expiryWarning)
+	}
+
+	return checkers
+}
+
+func DbChecker(db *sqlx.DB) Checker {
+	return func(ctx context.Context) Check {
+		return checkError(db.PingContext(ctx))
+	}
+}
+
+func RedisChecker(ping func() error) Checker {
+	return errorChecker(ping)
+}
+
+//CertificateChecker loads the key pair and checks the expiry of the certificate.
+func CertificateChecker(certFile, keyFile string, expiryWarning time.Duration) Checker {
+	return func(ctx context.Context) Check {
+		pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return checkError(err)
+		}
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return checkError(err)
+		}
+
+		return checkExpiry([]*x509.Certificate{cert}, expiryWarning)
+	}
+}
+
+//CaCertificateChecker checks the comma separated CA certificate files. The earliest expiry is reported.
+func CaCertificateChecker(certFiles string, expiryWarning time.Duration) Checker {
+	return func(ctx context.Context) Check {
+		var certs []*x509.Certificate
+		for _, certFile := range strings.Split(certFiles, ",") {
+			data, err := ioutil.ReadFile(strings.TrimSpace(certFile))
+			if err != nil {
+				return checkError(err)
+			}
+			parsed, err := parseCertificates(data)
+			if err != nil {
+				return checkError(fmt.Errorf("%v: %v", certFile, err))
+			}
+			certs = append(certs, parsed...)
+		}
+
+		return checkExpiry(certs, expiryWarning)
+	}
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+
+	return certs, nil
+}
+
+func checkExpiry(certs []*x509.Certificate, expiryWarning time.Duration) Check {
+	earliest := certs[0]
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(earliest.NotAfter) {
+			earliest = cert
+		}
+	}
+
+	expiresAt := earliest.NotAfter.UTC()
+	remaining := time.Until(expiresAt)
+	switch {
+	case remaining <= 0:
+		return Check{Status: StatusDown, Message: "certificate has expired", ExpiresAt: &expiresAt,
+			detail: fmt.Sprintf("certificate %v has expired", earliest.Subject.CommonName)}
+	case remaining <= expiryWarning:
+		days := int(remaining.Hours() / 24)
+		return Check{Status: StatusDegraded, Message: fmt.Sprintf("certificate expires in %d days", days), ExpiresAt: &expiresAt,
+			detail: fmt.Sprintf("certificate %v expires in %d days", earliest.Subject.CommonName, days)}
+	default:
+		return Check{Status: StatusUp, ExpiresAt: &expiresAt}
+	}
+}
+
+//degradedChecker reports the checker as degraded when it is down.
+func degradedChecker(checker Checker) Checker {
+	return func(ctx context.Context) Check {
+		check := checker(ctx)
+		if check.Status == StatusDown {
+			check.Status = StatusDegraded
+		}
+
+		return check
+	}
+}
+
+func errorChecker(check func() error) Checker {
+	return func(ctx context.Context) Check {
+		return checkError(check())
+	}
+}
+
+func checkError(err error) Check {
+	if err != nil {
+		return Check{Status: StatusDown, Message: "check failed", detail: err.Error()}
+	}
+
+	return Check{Status: StatusUp}
+}
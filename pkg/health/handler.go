@@ -0,0 +1,30 @@
+package health
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func RegisterHandler(e *echo.Echo, service Service) {
+	e.GET("/health/live", live())
+	e.GET("/health/ready", ready(service))
+}
+
+//live only reports that the process serves requests. The dependencies are checked by ready.
+func live() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": StatusUp})
+	}
+}
+
+//ready returns 503 if any dependency is down. A degraded dependency doesn't take the service out of rotation.
+func ready(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		report := service.Ready(c.Request().Context())
+		if report.Status == StatusDown {
+			return c.JSON(http.StatusServiceUnavailable, report)
+		}
+
+		return c.JSON(http.StatusOK, report)
+	}
+}
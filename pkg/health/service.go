@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"sync"
+	"time"
+)
+
+const checkTimeout = 3 * time.Second
+
+//Checker checks a dependency. It should return once ctx is done.
+type Checker func(ctx context.Context) Check
+
+//CheckerSource returns the checkers which are only known when the checks run, e.g. the ones of each configured aspsp.
+type CheckerSource func(ctx context.Context) map[string]Checker
+
+type Service interface {
+	Ready(ctx context.Context) Report
+}
+
+type service struct {
+	checkers map[string]Checker
+	sources  []CheckerSource
+}
+
+func NewService(checkers map[string]Checker, sources ...CheckerSource) Service {
+	return &service{checkers: checkers, sources: sources}
+}
+
+//Ready runs the checks concurrently, each one with its own timeout.
+func (s service) Ready(ctx context.Context) Report {
+	checkers := s.allCheckers(ctx)
+	report := Report{Status: StatusUp, Checks: make(map[string]Check, len(checkers))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			check := runCheck(ctx, checker)
+			if check.detail != "" {
+				logger.Warn("health check isn't up", logger.String("check", name), logger.String("status", check.Status),
+					logger.String("detail", check.detail))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = check
+		}(name, checker)
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		switch {
+		case check.Status == StatusDown:
+			report.Status = StatusDown
+		case check.Status == StatusDegraded && report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+//allCheckers returns the checkers with the ones of the sources, which are listed within the timeout of a check.
+func (s service) allCheckers(ctx context.Context) map[string]Checker {
+	if len(s.sources) == 0 {
+		return s.checkers
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	checkers := make(map[string]Checker, len(s.checkers))
+	for name, checker := range s.checkers {
+		checkers[name] = checker
+	}
+	for _, source := range s.sources {
+		for name, checker := range source(ctx) {
+			checkers[name] = checker
+		}
+	}
+
+	return checkers
+}
+
+func runCheck(ctx context.Context, checker Checker) Check {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	result := make(chan Check, 1)
+	go func() {
+		result <- checker(ctx)
+	}()
+
+	select {
+	case check := <-result:
+		return check
+	case <-ctx.Done():
+		return Check{Status: StatusDown, Message: "check timed out"}
+	}
+}
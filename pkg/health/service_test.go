@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_service_Ready(t *testing.T) {
+	up := func(ctx context.Context) Check { return Check{Status: StatusUp} }
+	degraded := func(ctx context.Context) Check { return Check{Status: StatusDegraded} }
+	down := errorChecker(func() error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name     string
+		checkers map[string]Checker
+		want     string
+	}{
+		{"all_up", map[string]Checker{"database": up, "redis": up}, StatusUp},
+		{"degraded", map[string]Checker{"database": up, "transport_certificate": degraded}, StatusDegraded},
+		{"down_wins_over_degraded", map[string]Checker{"redis": down, "transport_certificate": degraded}, StatusDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewService(tt.checkers).Ready(context.Background())
+			if got.Status != tt.want {
+				t.Errorf("Ready() = %v, want %v", got.Status, tt.want)
+			}
+			if len(got.Checks) != len(tt.checkers) {
+				t.Errorf("Ready() checks = %v, want %v", got.Checks, len(tt.checkers))
+			}
+		})
+	}
+}
+
+func Test_CertificateChecker(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		notAfter time.Duration
+		want     string
+	}{
+		{"valid", 90 * 24 * time.Hour, StatusUp},
+		{"expires_soon", 10 * 24 * time.Hour, StatusDegraded},
+		{"expired", -time.Hour, StatusDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certFile, keyFile := writeCertificate(t, dir, tt.name, time.Now().Add(tt.notAfter))
+
+			got := CertificateChecker(certFile, keyFile, 30*24*time.Hour)(context.Background())
+			if got.Status != tt.want {
+				t.Errorf("CertificateChecker() = %v, want %v", got, tt.want)
+			}
+
+			got = CaCertificateChecker(certFile, 30*24*time.Hour)(context.Background())
+			if got.Status != tt.want {
+				t.Errorf("CaCertificateChecker() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing_key", func(t *testing.T) {
+		certFile, _ := writeCertificate(t, dir, "missing_key", time.Now().Add(time.Hour))
+		if got := CertificateChecker(certFile, filepath.Join(dir, "none.key"), 0)(context.Background()); got.Status != StatusDown {
+			t.Errorf("CertificateChecker() = %v, want %v", got, StatusDown)
+		}
+	})
+}
+
+type aspspsStub struct {
+	ids []string
+	err error
+}
+
+func (a aspspsStub) FindAspspIds(ctx context.Context) ([]string, error) {
+	return a.ids, a.err
+}
+
+type configStub map[string]string
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if value, found := c[aspspId+"_"+configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+func Test_AspspCertificateCheckers(t *testing.T) {
+	dir := t.TempDir()
+	validCert, validKey := writeCertificate(t, dir, "valid", time.Now().Add(90*24*time.Hour))
+	expiredCert, _ := writeCertificate(t, dir, "expired", time.Now().Add(-time.Hour))
+	soonCert, _ := writeCertificate(t, dir, "soon", time.Now().Add(10*24*time.Hour))
+
+	cfg := configStub{
+		"health_own_TLS_CLIENT_CERT_PEM": validCert,
+		"health_own_TLS_CLIENT_KEY_PEM":  validKey,
+		"health_own_TLS_CA_CERT_PEM":     validCert + ", " + soonCert,
+		"health_expired_TLS_CA_CERT_PEM": expiredCert,
+	}
+	s := &settings.Settings{Health: settings.Health{CertExpiryDays: 30}}
+	source := AspspCertificateCheckers(aspspsStub{ids: []string{"health_own", "health_expired", "health_default"}}, cfg, s)
+
+	report := NewService(map[string]Checker{}, source).Ready(context.Background())
+	want := map[string]string{
+		"aspsp_health_own_transport_certificate": StatusUp,
+		"aspsp_health_own_ca_certificate":        StatusDegraded,
+		"aspsp_health_expired_ca_certificate":    StatusDegraded,
+	}
+	if len(report.Checks) != len(want) || report.Status != StatusDegraded {
+		t.Errorf("Ready() = %+v, want the checks of the configured certificates only", report)
+	}
+	for name, status := range want {
+		if report.Checks[name].Status != status {
+			t.Errorf("Ready() check %v = %+v, want %v", name, report.Checks[name], status)
+		}
+	}
+
+	failed := AspspCertificateCheckers(aspspsStub{err: errors.New("connection refused")}, cfg, s)
+	if got := NewService(map[string]Checker{}, failed).Ready(context.Background()); got.Checks["aspsp_certificates"].Status != StatusDegraded {
+		t.Errorf("Ready() = %+v, want aspsp_certificates degraded", got)
+	}
+}
+
+func writeCertificate(t *testing.T, dir, name string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	_ = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func Test_service_Ready_hidesDetails(t *testing.T) {
+	dir := t.TempDir()
+	expiredCert, expiredKey := writeCertificate(t, dir, "secret-cn", time.Now().Add(-time.Hour))
+
+	report := NewService(map[string]Checker{
+		"database":              errorChecker(func() error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }),
+		"ca_certificate":        CaCertificateChecker(filepath.Join(dir, "missing.cer"), 0),
+		"transport_certificate": CertificateChecker(expiredCert, expiredKey, 0),
+	}).Ready(context.Background())
+
+	for name, check := range report.Checks {
+		if check.Status != StatusDown || strings.Contains(check.Message, "10.0.0.5") || strings.Contains(check.Message, dir) ||
+			strings.Contains(check.Message, "secret-cn") {
+			t.Errorf("Ready() check %v = %+v, want down with a generic message", name, check)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package health
+
+import "time"
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+//Check is the status of a single dependency. /health/ready doesn't require a token, so Message is generic and the
+//detail, e.g. the error of the db driver or the file and the subject of a certificate, is only logged.
+type Check struct {
+	Status    string     `json:"status"`
+	Message   string     `json:"message,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	detail    string
+}
+
+//Report is the readiness of the service. It is down if any dependency is down, degraded if any is degraded.
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
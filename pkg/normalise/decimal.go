@@ -0,0 +1,118 @@
+package normalise
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//obAmountRegexp is the format of the OB amounts, e.g. 10.5 or 1230.00000
+var obAmountRegexp = regexp.MustCompile(`^-?\d{1,13}(\.\d{1,5})?$`)
+
+//Decimal is an exact decimal number, as the amounts can't be kept in a float. Unscaled is the number without its point
+//and Scale is the number of the fraction digits, e.g. 12.30 is 1230 with the scale of 2.
+type Decimal struct {
+	Unscaled int64
+	Scale    int
+}
+
+//ParseDecimal parses an OB amount. The fraction digits are kept, so 10.50 stays 10.50.
+func ParseDecimal(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	if !obAmountRegexp.MatchString(value) {
+		return Decimal{}, fmt.Errorf("%q isn't a valid amount", value)
+	}
+
+	digits, scale := value, 0
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		scale = len(value) - i - 1
+		digits = value[:i] + value[i+1:]
+	}
+	unscaled, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%q isn't a valid amount", value)
+	}
+
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{Unscaled: -d.Unscaled, Scale: d.Scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.Unscaled < 0 {
+		return d.Neg()
+	}
+
+	return d
+}
+
+//Add returns d + o with the larger scale of them, e.g. 10.5 + 0.25 is 10.75.
+func (d Decimal) Add(o Decimal) Decimal {
+	for d.Scale < o.Scale {
+		d.Unscaled, d.Scale = d.Unscaled*10, d.Scale+1
+	}
+	for o.Scale < d.Scale {
+		o.Unscaled, o.Scale = o.Unscaled*10, o.Scale+1
+	}
+
+	return Decimal{Unscaled: d.Unscaled + o.Unscaled, Scale: d.Scale}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Unscaled == 0
+}
+
+func (d Decimal) String() string {
+	sign, digits := "", strconv.FormatInt(d.Unscaled, 10)
+	if d.Unscaled < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if d.Scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+}
+
+//MarshalJSON writes the decimal as a string like the OB amounts, since the JSON numbers of the most clients are floats.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value, err := strconv.Unquote(string(data))
+	if err != nil {
+		value = string(data)
+	}
+
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+
+	return nil
+}
+
+//Value stores the decimal as a string, so its fraction digits are kept.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case string:
+		return d.UnmarshalJSON([]byte(value))
+	case []byte:
+		return d.UnmarshalJSON(value)
+	default:
+		return fmt.Errorf("can't scan %T into a decimal", src)
+	}
+}
@@ -0,0 +1,100 @@
+package normalise
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Decimal
+		wantErr bool
+	}{
+		{"integer", "10", Decimal{Unscaled: 10}, false},
+		{"fraction_kept", "10.50", Decimal{Unscaled: 1050, Scale: 2}, false},
+		{"negative", "-0.01", Decimal{Unscaled: -1, Scale: 2}, false},
+		{"max_fraction", "1230.00001", Decimal{Unscaled: 123000001, Scale: 5}, false},
+		{"too_many_fraction_digits", "1.000001", Decimal{}, true},
+		{"comma", "1,50", Decimal{}, true},
+		{"empty", "", Decimal{}, true},
+		{"float_exponent", "1e3", Decimal{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDecimal(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecimal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDecimal() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimal_String(t *testing.T) {
+	tests := []struct {
+		value Decimal
+		want  string
+	}{
+		{Decimal{Unscaled: 1050, Scale: 2}, "10.50"},
+		{Decimal{Unscaled: -1, Scale: 2}, "-0.01"},
+		{Decimal{Unscaled: 5, Scale: 0}, "5"},
+		{Decimal{Unscaled: 0, Scale: 3}, "0.000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.value.String(); got != tt.want {
+				t.Errorf("String() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimal_Add(t *testing.T) {
+	tests := []struct {
+		d, o Decimal
+		want string
+	}{
+		{Decimal{Unscaled: 105, Scale: 1}, Decimal{Unscaled: 25, Scale: 2}, "10.75"},
+		{Decimal{Unscaled: 1050, Scale: 2}, Decimal{Unscaled: -2000, Scale: 2}, "-9.50"},
+		{Decimal{}, Decimal{Unscaled: 5}, "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.d.Add(tt.o).String(); got != tt.want {
+				t.Errorf("Add() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	b, err := json.Marshal(Decimal{Unscaled: -1050, Scale: 2})
+	if err != nil || string(b) != `"-10.50"` {
+		t.Fatalf("Marshal() got = %s, %v", b, err)
+	}
+
+	var d Decimal
+	for _, data := range []string{`"-10.50"`, `-10.50`} {
+		if err := json.Unmarshal([]byte(data), &d); err != nil || d != (Decimal{Unscaled: -1050, Scale: 2}) {
+			t.Errorf("Unmarshal(%v) got = %v, %v", data, d, err)
+		}
+	}
+}
+
+func TestDecimal_Scan(t *testing.T) {
+	for _, src := range []interface{}{"-10.50", []byte("-10.50")} {
+		var d Decimal
+		if err := d.Scan(src); err != nil || d != (Decimal{Unscaled: -1050, Scale: 2}) {
+			t.Errorf("Scan(%v) got = %v, %v", src, d, err)
+		}
+	}
+
+	var d Decimal
+	if err := d.Scan(10.5); err == nil {
+		t.Errorf("Scan(float64) error = nil, want an error")
+	}
+}
@@ -0,0 +1,82 @@
+package normalise
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	schemePrefix          = "UK.OBIE."
+	SortCodeAccountNumber = "SortCodeAccountNumber"
+	IBAN                  = "IBAN"
+)
+
+var (
+	sortCodeAccountNumberRegexp = regexp.MustCompile(`^\d{14}$`)
+	ibanRegexp                  = regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$`)
+	//gbIbanRegexp is a UK IBAN, which has the bank code, the sort code and the account number, e.g. GB29NWBK60161331926819
+	gbIbanRegexp   = regexp.MustCompile(`^GB\d{2}[A-Z]{4}(\d{6})(\d{8})$`)
+	currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+	separators     = strings.NewReplacer(" ", "", "-", "")
+)
+
+//NewIdentifier normalises an OB account identifier. The scheme names are sent with and without the UK.OBIE. prefix.
+func NewIdentifier(schemeName, identification, name, secondaryIdentification string) Identifier {
+	id := Identifier{
+		Scheme:                  strings.TrimPrefix(strings.TrimSpace(schemeName), schemePrefix),
+		Identification:          strings.TrimSpace(identification),
+		Name:                    strings.TrimSpace(name),
+		SecondaryIdentification: strings.TrimSpace(secondaryIdentification),
+		Valid:                   true,
+	}
+
+	switch id.Scheme {
+	case SortCodeAccountNumber:
+		value := separators.Replace(id.Identification)
+		if !sortCodeAccountNumberRegexp.MatchString(value) {
+			id.Valid = false
+			return id
+		}
+		id.Identification, id.SortCode, id.AccountNumber = value, value[:6], value[6:]
+	case IBAN:
+		value := strings.ToUpper(separators.Replace(id.Identification))
+		if !validIban(value) {
+			id.Valid = false
+			return id
+		}
+		id.Identification = value
+		if m := gbIbanRegexp.FindStringSubmatch(value); m != nil {
+			id.SortCode, id.AccountNumber = m[1], m[2]
+		}
+	}
+
+	return id
+}
+
+//validIban checks the format and the ISO 13616 check digits of iban.
+func validIban(iban string) bool {
+	if !ibanRegexp.MatchString(iban) {
+		return false
+	}
+
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+//normaliseCurrency returns the ISO 4217 code of currency. ok is false if it isn't a currency code.
+func normaliseCurrency(currency string) (string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+
+	return currency, currencyRegexp.MatchString(currency)
+}
@@ -0,0 +1,179 @@
+package normalise
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"strings"
+	"time"
+)
+
+//dateTimeLayouts are the formats of the OB dates. RFC3339 is the standard, the others are sent by some aspsps
+//and are read as UTC.
+var dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+//Accounts maps an OBReadAccount payload of the aspsp to the canonical accounts.
+func Accounts(source Source, payload string) ([]Account, error) {
+	var ob obReadAccount
+	if err := json.Unmarshal([]byte(payload), &ob); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	accounts := make([]Account, 0, len(ob.Data.Account))
+	for i, a := range ob.Data.Account {
+		path := fmt.Sprintf("Data.Account[%d]", i)
+		currency, ok := normaliseCurrency(a.Currency)
+		if !ok {
+			return nil, invalidField(path+".Currency", a.Currency)
+		}
+
+		account := Account{
+			Source:      source,
+			AccountId:   a.AccountId,
+			Currency:    currency,
+			Type:        a.AccountType,
+			SubType:     a.AccountSubType,
+			Nickname:    a.Nickname,
+			Identifiers: make([]Identifier, 0, len(a.Account)),
+		}
+		for _, item := range a.Account {
+			account.Identifiers = append(account.Identifiers, NewIdentifier(item.SchemeName, item.Identification, item.Name, item.SecondaryIdentification))
+		}
+		if a.Servicer != nil {
+			servicer := NewIdentifier(a.Servicer.SchemeName, a.Servicer.Identification, a.Servicer.Name, a.Servicer.SecondaryIdentification)
+			account.Servicer = &servicer
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+//Balances maps an OBReadBalance payload of the aspsp to the canonical balances.
+func Balances(source Source, payload string) ([]Balance, error) {
+	var ob obReadBalance
+	if err := json.Unmarshal([]byte(payload), &ob); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	balances := make([]Balance, 0, len(ob.Data.Balance))
+	for i, b := range ob.Data.Balance {
+		path := fmt.Sprintf("Data.Balance[%d]", i)
+		amount, err := newAmount(path, b.Amount, b.CreditDebitIndicator)
+		if err != nil {
+			return nil, err
+		}
+		dateTime, ok := parseDateTime(b.DateTime)
+		if !ok {
+			return nil, invalidField(path+".DateTime", b.DateTime)
+		}
+
+		balance := Balance{
+			Source:    source,
+			AccountId: b.AccountId,
+			Type:      b.Type,
+			Amount:    amount,
+			DateTime:  dateTime,
+		}
+		for _, line := range b.CreditLine {
+			balance.CreditLineIncluded = balance.CreditLineIncluded || line.Included
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
+
+//Transactions maps an OBReadTransaction payload of the aspsp to the canonical transactions.
+func Transactions(source Source, payload string) ([]Transaction, error) {
+	var ob obReadTransaction
+	if err := json.Unmarshal([]byte(payload), &ob); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	transactions := make([]Transaction, 0, len(ob.Data.Transaction))
+	for i, t := range ob.Data.Transaction {
+		path := fmt.Sprintf("Data.Transaction[%d]", i)
+		amount, err := newAmount(path, t.Amount, t.CreditDebitIndicator)
+		if err != nil {
+			return nil, err
+		}
+		bookingDateTime, ok := parseDateTime(t.BookingDateTime)
+		if !ok {
+			return nil, invalidField(path+".BookingDateTime", t.BookingDateTime)
+		}
+
+		transaction := Transaction{
+			Source:          source,
+			AccountId:       t.AccountId,
+			TransactionId:   t.TransactionId,
+			Reference:       t.TransactionReference,
+			Status:          t.Status,
+			BookingDateTime: bookingDateTime,
+			Amount:          amount,
+			Description:     strings.TrimSpace(t.TransactionInformation),
+		}
+		if t.ValueDateTime != "" {
+			valueDateTime, ok := parseDateTime(t.ValueDateTime)
+			if !ok {
+				return nil, invalidField(path+".ValueDateTime", t.ValueDateTime)
+			}
+			transaction.ValueDateTime = &valueDateTime
+		}
+		if t.MerchantDetails != nil {
+			transaction.MerchantName = strings.TrimSpace(t.MerchantDetails.MerchantName)
+		}
+		if t.Balance != nil {
+			balance, err := newAmount(path+".Balance", t.Balance.Amount, t.Balance.CreditDebitIndicator)
+			if err != nil {
+				return nil, err
+			}
+			transaction.Balance = &balance
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+//newAmount returns the signed amount. path is the json path of the object which has the Amount and the CreditDebitIndicator.
+func newAmount(path string, amount obAmount, creditDebitIndicator string) (Amount, error) {
+	value, err := ParseDecimal(amount.Amount)
+	if err != nil {
+		return Amount{}, invalidField(path+".Amount.Amount", amount.Amount)
+	}
+	currency, ok := normaliseCurrency(amount.Currency)
+	if !ok {
+		return Amount{}, invalidField(path+".Amount.Currency", amount.Currency)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(creditDebitIndicator)) {
+	case "credit":
+	case "debit":
+		value = value.Neg()
+	default:
+		return Amount{}, invalidField(path+".CreditDebitIndicator", creditDebitIndicator)
+	}
+
+	return Amount{Value: value, Currency: currency}, nil
+}
+
+func parseDateTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+//The payloads which can't be normalised are the aspsp's fault, so they're sent as rejected by the aspsp.
+func invalidPayload(err error) error {
+	return api.ErrAspspRejected.Wrap(fmt.Errorf("can't normalise the payload: %v", err))
+}
+
+func invalidField(path, value string) error {
+	return api.ErrAspspRejected.Wrap(fmt.Errorf("can't normalise %v: %q isn't valid", path, value))
+}
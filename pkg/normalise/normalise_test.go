@@ -0,0 +1,165 @@
+package normalise
+
+import (
+	"errors"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var source = Source{AspspId: "aspsp-1", Cid: "cid-1"}
+
+func TestNewIdentifier(t *testing.T) {
+	tests := []struct {
+		name                   string
+		scheme, identification string
+		want                   Identifier
+	}{
+		{"sort_code_account_number", "UK.OBIE.SortCodeAccountNumber", "60-16-13 31926819",
+			Identifier{Scheme: SortCodeAccountNumber, Identification: "60161331926819", SortCode: "601613", AccountNumber: "31926819", Valid: true}},
+		{"unprefixed_scheme", "SortCodeAccountNumber", "60161331926819",
+			Identifier{Scheme: SortCodeAccountNumber, Identification: "60161331926819", SortCode: "601613", AccountNumber: "31926819", Valid: true}},
+		{"short_account_number", "UK.OBIE.SortCodeAccountNumber", "6016133192681",
+			Identifier{Scheme: SortCodeAccountNumber, Identification: "6016133192681"}},
+		{"gb_iban", "UK.OBIE.IBAN", "gb29 nwbk 6016 1331 9268 19",
+			Identifier{Scheme: IBAN, Identification: "GB29NWBK60161331926819", SortCode: "601613", AccountNumber: "31926819", Valid: true}},
+		{"de_iban", "UK.OBIE.IBAN", "DE89370400440532013000",
+			Identifier{Scheme: IBAN, Identification: "DE89370400440532013000", Valid: true}},
+		{"iban_wrong_check_digits", "UK.OBIE.IBAN", "GB28NWBK60161331926819",
+			Identifier{Scheme: IBAN, Identification: "GB28NWBK60161331926819"}},
+		{"other_scheme", "UK.OBIE.PAN", "5409050000000000",
+			Identifier{Scheme: "PAN", Identification: "5409050000000000", Valid: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewIdentifier(tt.scheme, tt.identification, "", ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewIdentifier() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	//the first aspsp sends Account as an array, the second as an object as before v3.0
+	payload := `{"Data":{"Account":[
+		{"AccountId":"22289","Currency":"gbp","AccountType":"Personal","AccountSubType":"CurrentAccount","Nickname":"Bills",
+		 "Account":[{"SchemeName":"UK.OBIE.SortCodeAccountNumber","Identification":"80200110203345","Name":"Mr Kevin"}]},
+		{"AccountId":"31820","Currency":"EUR",
+		 "Account":{"SchemeName":"IBAN","Identification":"DE89 3704 0044 0532 0130 00"},
+		 "Servicer":{"SchemeName":"UK.OBIE.BICFI","Identification":"COBADEFFXXX"}}]}}`
+
+	got, err := Accounts(source, payload)
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+	want := []Account{
+		{Source: source, AccountId: "22289", Currency: "GBP", Type: "Personal", SubType: "CurrentAccount", Nickname: "Bills",
+			Identifiers: []Identifier{{Scheme: SortCodeAccountNumber, Identification: "80200110203345", SortCode: "802001", AccountNumber: "10203345", Name: "Mr Kevin", Valid: true}}},
+		{Source: source, AccountId: "31820", Currency: "EUR",
+			Identifiers: []Identifier{{Scheme: IBAN, Identification: "DE89370400440532013000", Valid: true}},
+			Servicer:    &Identifier{Scheme: "BICFI", Identification: "COBADEFFXXX", Valid: true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Accounts() got = %+v, want %+v", got, want)
+	}
+
+	if _, err := Accounts(source, `{"Data":{"Account":[{"AccountId":"1","Currency":"pound"}]}}`); !errors.Is(err, api.ErrAspspRejected) {
+		t.Errorf("Accounts() error = %v, want %v", err, api.ErrAspspRejected)
+	}
+}
+
+func TestBalances(t *testing.T) {
+	payload := `{"Data":{"Balance":[
+		{"AccountId":"22289","Amount":{"Amount":"1230.00","Currency":"GBP"},"CreditDebitIndicator":"Credit","Type":"InterimAvailable",
+		 "DateTime":"2017-04-05T10:43:07+00:00","CreditLine":[{"Included":false},{"Included":true}]},
+		{"AccountId":"22289","Amount":{"Amount":"57.36","Currency":"GBP"},"CreditDebitIndicator":"Debit","Type":"InterimBooked",
+		 "DateTime":"2017-04-05"}]}}`
+
+	got, err := Balances(source, payload)
+	if err != nil {
+		t.Fatalf("Balances() error = %v", err)
+	}
+	want := []Balance{
+		{Source: source, AccountId: "22289", Type: "InterimAvailable", Amount: Amount{Value: Decimal{Unscaled: 123000, Scale: 2}, Currency: "GBP"},
+			DateTime: time.Date(2017, 4, 5, 10, 43, 7, 0, time.UTC), CreditLineIncluded: true},
+		{Source: source, AccountId: "22289", Type: "InterimBooked", Amount: Amount{Value: Decimal{Unscaled: -5736, Scale: 2}, Currency: "GBP"},
+			DateTime: time.Date(2017, 4, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Balances() got = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].DateTime.Equal(want[i].DateTime) {
+			t.Errorf("Balances()[%d].DateTime got = %v, want %v", i, got[i].DateTime, want[i].DateTime)
+		}
+		got[i].DateTime = want[i].DateTime
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Balances() got = %+v, want %+v", got, want)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Amount
+		wantErr bool
+	}{
+		{"debit", `{"Data":{"Transaction":[{"AccountId":"22289","TransactionId":"123","CreditDebitIndicator":"Debit","Status":"Booked",
+			"BookingDateTime":"2017-04-05T10:43:07+00:00","Amount":{"Amount":"10.00","Currency":"GBP"},
+			"TransactionInformation":" Cash from Aubrey ","MerchantDetails":{"MerchantName":"Aubrey"},
+			"Balance":{"CreditDebitIndicator":"Credit","Type":"InterimBooked","Amount":{"Amount":"230.00","Currency":"GBP"}}}]}}`,
+			Amount{Value: Decimal{Unscaled: -1000, Scale: 2}, Currency: "GBP"}, false},
+		{"invalid_amount", `{"Data":{"Transaction":[{"CreditDebitIndicator":"Debit","BookingDateTime":"2017-04-05",
+			"Amount":{"Amount":"10,00","Currency":"GBP"}}]}}`, Amount{}, true},
+		{"missing_indicator", `{"Data":{"Transaction":[{"BookingDateTime":"2017-04-05",
+			"Amount":{"Amount":"10.00","Currency":"GBP"}}]}}`, Amount{}, true},
+		{"invalid_booking_date", `{"Data":{"Transaction":[{"CreditDebitIndicator":"Credit","BookingDateTime":"05/04/2017",
+			"Amount":{"Amount":"10.00","Currency":"GBP"}}]}}`, Amount{}, true},
+		{"not_json", `Service Unavailable`, Amount{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transactions(source, tt.payload)
+			if tt.wantErr {
+				if !errors.Is(err, api.ErrAspspRejected) {
+					t.Errorf("Transactions() error = %v, want %v", err, api.ErrAspspRejected)
+				}
+				return
+			}
+			if err != nil || len(got) != 1 {
+				t.Fatalf("Transactions() got = %+v, error = %v", got, err)
+			}
+			if got[0].Amount != tt.want {
+				t.Errorf("Transactions() amount = %v, want %v", got[0].Amount, tt.want)
+			}
+			if got[0].Source != source || got[0].Description != "Cash from Aubrey" || got[0].MerchantName != "Aubrey" ||
+				got[0].Balance == nil || got[0].Balance.Value.String() != "230.00" {
+				t.Errorf("Transactions() got = %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestOccurrences_Number(t *testing.T) {
+	purchase := Transaction{BookingDateTime: time.Date(2021, 1, 8, 10, 0, 0, 0, time.UTC),
+		Amount: Amount{Value: Decimal{Unscaled: -250, Scale: 2}, Currency: "GBP"}, Description: "Coffee"}
+	withId := purchase
+	withId.TransactionId = "t1"
+	transactions := []Transaction{purchase, purchase, withId, withId, purchase}
+
+	occurrences := Occurrences{}
+	for i := range transactions {
+		occurrences.Number(&transactions[i])
+	}
+
+	first := purchase.Key()
+	want := []string{first, first + "#1", "t1", "t1", first + "#2"}
+	for i, tr := range transactions {
+		if got := tr.Key(); got != want[i] {
+			t.Errorf("Key() of transaction %v = %v, want %v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package normalise
+
+import (
+	"encoding/json"
+)
+
+//The OB v3.1 payloads of the account, balance and transaction resources. Only the fields which are normalised are read,
+//and the fields the aspsps send differently are accepted in each of their forms.
+
+type obReadAccount struct {
+	Data struct {
+		Account []obAccount `json:"Account"`
+	} `json:"Data"`
+}
+
+type obAccount struct {
+	AccountId      string             `json:"AccountId"`
+	Currency       string             `json:"Currency"`
+	AccountType    string             `json:"AccountType"`
+	AccountSubType string             `json:"AccountSubType"`
+	Nickname       string             `json:"Nickname"`
+	Account        obCashAccounts     `json:"Account"`
+	Servicer       *obCashAccountItem `json:"Servicer"`
+}
+
+type obCashAccountItem struct {
+	SchemeName              string `json:"SchemeName"`
+	Identification          string `json:"Identification"`
+	Name                    string `json:"Name"`
+	SecondaryIdentification string `json:"SecondaryIdentification"`
+}
+
+//obCashAccounts is the Account of an account, which is an array since v3.0 and a single object before.
+type obCashAccounts []obCashAccountItem
+
+func (o *obCashAccounts) UnmarshalJSON(data []byte) error {
+	var items []obCashAccountItem
+	if err := json.Unmarshal(data, &items); err == nil {
+		*o = items
+		return nil
+	}
+
+	var item obCashAccountItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+	*o = obCashAccounts{item}
+
+	return nil
+}
+
+type obAmount struct {
+	Amount   string `json:"Amount"`
+	Currency string `json:"Currency"`
+}
+
+type obReadBalance struct {
+	Data struct {
+		Balance []obBalance `json:"Balance"`
+	} `json:"Data"`
+}
+
+type obBalance struct {
+	AccountId            string         `json:"AccountId"`
+	CreditDebitIndicator string         `json:"CreditDebitIndicator"`
+	Type                 string         `json:"Type"`
+	DateTime             string         `json:"DateTime"`
+	Amount               obAmount       `json:"Amount"`
+	CreditLine           []obCreditLine `json:"CreditLine"`
+}
+
+type obCreditLine struct {
+	Included bool `json:"Included"`
+}
+
+type obReadTransaction struct {
+	Data struct {
+		Transaction []obTransaction `json:"Transaction"`
+	} `json:"Data"`
+}
+
+type obTransaction struct {
+	AccountId              string                `json:"AccountId"`
+	TransactionId          string                `json:"TransactionId"`
+	TransactionReference   string                `json:"TransactionReference"`
+	CreditDebitIndicator   string                `json:"CreditDebitIndicator"`
+	Status                 string                `json:"Status"`
+	BookingDateTime        string                `json:"BookingDateTime"`
+	ValueDateTime          string                `json:"ValueDateTime"`
+	TransactionInformation string                `json:"TransactionInformation"`
+	Amount                 obAmount              `json:"Amount"`
+	Balance                *obTransactionBalance `json:"Balance"`
+	MerchantDetails        *struct {
+		MerchantName string `json:"MerchantName"`
+	} `json:"MerchantDetails"`
+}
+
+type obTransactionBalance struct {
+	CreditDebitIndicator string   `json:"CreditDebitIndicator"`
+	Type                 string   `json:"Type"`
+	Amount               obAmount `json:"Amount"`
+}
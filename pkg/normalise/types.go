@@ -0,0 +1,113 @@
+package normalise
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Source is where a resource is read from, so the resources of the same account in different aspsps can be told apart.
+type Source struct {
+	AspspId string `json:"aspspId"`
+	Cid     string `json:"cid"`
+}
+
+type Account struct {
+	Source
+	AccountId   string       `json:"accountId"`
+	Currency    string       `json:"currency"`
+	Type        string       `json:"type,omitempty"`
+	SubType     string       `json:"subType,omitempty"`
+	Nickname    string       `json:"nickname,omitempty"`
+	Identifiers []Identifier `json:"identifiers"`
+	Servicer    *Identifier  `json:"servicer,omitempty"`
+}
+
+//Identifier is an account identifier. Scheme is the OB scheme name without the UK.OBIE. prefix, e.g. IBAN.
+//SortCode and AccountNumber are set for the SortCodeAccountNumber identifiers and the UK IBANs.
+//Valid is false if the identification doesn't match its scheme, in which case it's kept as sent by the aspsp.
+type Identifier struct {
+	Scheme                  string `json:"scheme"`
+	Identification          string `json:"identification"`
+	SortCode                string `json:"sortCode,omitempty"`
+	AccountNumber           string `json:"accountNumber,omitempty"`
+	Name                    string `json:"name,omitempty"`
+	SecondaryIdentification string `json:"secondaryIdentification,omitempty"`
+	Valid                   bool   `json:"valid"`
+}
+
+//Amount is a signed amount. The debits are negative, so the amounts can be summed without the CreditDebitIndicator.
+type Amount struct {
+	Value    Decimal `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+type Balance struct {
+	Source
+	AccountId          string    `json:"accountId"`
+	Type               string    `json:"type"`
+	Amount             Amount    `json:"amount"`
+	DateTime           time.Time `json:"dateTime"`
+	CreditLineIncluded bool      `json:"creditLineIncluded"`
+}
+
+//The statuses of the transactions.
+const (
+	StatusBooked  = "Booked"
+	StatusPending = "Pending"
+)
+
+type Transaction struct {
+	Source
+	AccountId       string     `json:"accountId"`
+	TransactionId   string     `json:"transactionId,omitempty"`
+	Reference       string     `json:"reference,omitempty"`
+	Status          string     `json:"status"`
+	BookingDateTime time.Time  `json:"bookingDateTime"`
+	ValueDateTime   *time.Time `json:"valueDateTime,omitempty"`
+	Amount          Amount     `json:"amount"`
+	Description     string     `json:"description,omitempty"`
+	MerchantName    string     `json:"merchantName,omitempty"`
+	//Balance is the balance of the account after the transaction, if the aspsp sends it.
+	Balance *Amount `json:"balance,omitempty"`
+	//Occurrence tells apart the identical transactions without an id, which is set by Occurrences.
+	Occurrence int `json:"-"`
+}
+
+//Key is the TransactionId, or a hash of the details of the transaction if the aspsp doesn't send an id.
+//The status isn't in the hash, as the pending transactions are replaced by each sync and only the booked ones need a stable key.
+//The identical transactions, e.g. two purchases of the same amount in the same second, have their Occurrence appended.
+func (t Transaction) Key() string {
+	if t.TransactionId != "" {
+		return t.TransactionId
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		t.BookingDateTime.UTC().Format(time.RFC3339), t.Amount.Value.String(), t.Amount.Currency, t.Reference, t.Description,
+	}, "|")))
+	key := "sha256:" + hex.EncodeToString(hash[:])
+	if t.Occurrence > 0 {
+		key += "#" + strconv.Itoa(t.Occurrence)
+	}
+
+	return key
+}
+
+//Occurrences counts the identical transactions without an id in the order the aspsp returns them, so the first one
+//keeps the key of the hash and the next ones have their occurrence appended. It's used for all pages of a request,
+//as the aspsp returns the same transactions in the same order.
+type Occurrences map[string]int
+
+//Number sets the Occurrence of the transaction.
+func (o Occurrences) Number(t *Transaction) {
+	if t.TransactionId != "" {
+		return
+	}
+
+	t.Occurrence = 0
+	key := t.Key()
+	t.Occurrence = o[key]
+	o[key]++
+}
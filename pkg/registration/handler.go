@@ -0,0 +1,58 @@
+package registration
+
+import (
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func RegisterHandler(e *echo.Echo, service Service) {
+	g := e.Group("/admin/aspsps/:aspspId/registration", configmanager.RequireAdmin())
+	g.POST("", register(service))
+	g.GET("", getRegistration(service))
+	g.PUT("", updateRegistration(service))
+	g.DELETE("", deleteRegistration(service))
+}
+
+func register(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		registration, err := service.Register(c.Request().Context(), c.Param("aspspId"), configmanager.ChangedBy(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusCreated, registration.Public())
+	}
+}
+
+func getRegistration(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		registration, err := service.Get(c.Request().Context(), c.Param("aspspId"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, registration.Public())
+	}
+}
+
+func updateRegistration(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		registration, err := service.Update(c.Request().Context(), c.Param("aspspId"), configmanager.ChangedBy(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, registration.Public())
+	}
+}
+
+func deleteRegistration(service Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := service.Delete(c.Request().Context(), c.Param("aspspId"), configmanager.ChangedBy(c)); err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,371 @@
+package registration
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/logger"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/clientauth"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/token"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//ErrNotRegistered is returned for the aspsps which don't have a CLIENT_ID.
+var ErrNotRegistered = api.NewError(http.StatusNotFound, api.ResourceNotFound, "aspsp isn't registered")
+
+//managementScope is the scope of the client credentials token which manages the registration, when the aspsp
+//doesn't return a registration_access_token.
+const managementScope = "accounts"
+
+//Service registers the TPP with the aspsps which support the Open Banking dynamic client registration.
+type Service interface {
+	//Register posts the registration request to ENDPOINT_REGISTER and stores the returned client in config_table.
+	Register(ctx context.Context, aspspId, changedBy string) (*Registration, error)
+	Get(ctx context.Context, aspspId string) (*Registration, error)
+	//Update sends the current registration request, e.g. after REDIRECT_URL or the SSA has changed.
+	Update(ctx context.Context, aspspId, changedBy string) (*Registration, error)
+	//Delete deletes the registration at the aspsp and the stored client secret and registration access token.
+	Delete(ctx context.Context, aspspId, changedBy string) error
+}
+
+type service struct {
+	cfg          configmanager.Service
+	admin        configmanager.AdminService
+	tokenService token.Service
+	settings     settings.Registration
+}
+
+func NewService(cfg configmanager.Service, admin configmanager.AdminService, tokenService token.Service, settings settings.Registration) Service {
+	return &service{
+		cfg:          cfg,
+		admin:        admin,
+		tokenService: tokenService,
+		settings:     settings,
+	}
+}
+
+func (s service) Register(ctx context.Context, aspspId, changedBy string) (*Registration, error) {
+	ctx, span := tracing.Start(ctx, "registration.Register", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	aspsp, err := s.admin.FindAspsp(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	endpointRegister := aspsp.Configs[api.EndpointRegister]
+	if endpointRegister == "" {
+		return nil, &ConfigError{ConfigName: api.EndpointRegister}
+	}
+
+	request, err := s.registrationRequest(aspspId, aspsp.Configs)
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := s.send(ctx, aspspId, http.MethodPost, endpointRegister, "", request)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := s.store(ctx, aspspId, registration, changedBy); err != nil {
+		span.RecordError(err)
+		s.rollback(ctx, aspspId, endpointRegister, registration)
+		return nil, err
+	}
+
+	return registration, nil
+}
+
+func (s service) Get(ctx context.Context, aspspId string) (*Registration, error) {
+	ctx, span := tracing.Start(ctx, "registration.Get", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	aspsp, err := s.admin.FindAspsp(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, bearer, err := s.management(ctx, aspspId, aspsp.Configs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(ctx, aspspId, http.MethodGet, endpoint, bearer, "")
+}
+
+func (s service) Update(ctx context.Context, aspspId, changedBy string) (*Registration, error) {
+	ctx, span := tracing.Start(ctx, "registration.Update", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	aspsp, err := s.admin.FindAspsp(ctx, aspspId)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, bearer, err := s.management(ctx, aspspId, aspsp.Configs)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.registrationRequest(aspspId, aspsp.Configs)
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := s.send(ctx, aspspId, http.MethodPut, endpoint, bearer, request)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := s.store(ctx, aspspId, registration, changedBy); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return registration, nil
+}
+
+func (s service) Delete(ctx context.Context, aspspId, changedBy string) error {
+	ctx, span := tracing.Start(ctx, "registration.Delete", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("aspsp.id", aspspId)
+
+	aspsp, err := s.admin.FindAspsp(ctx, aspspId)
+	if err != nil {
+		return err
+	}
+	endpoint, bearer, err := s.management(ctx, aspspId, aspsp.Configs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.send(ctx, aspspId, http.MethodDelete, endpoint, bearer, ""); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	//CLIENT_ID is kept, as it's required once the aspsp is set up. The credentials of the deleted client are removed.
+	for _, configName := range []string{api.ClientSecret, api.RegistrationAccessToken, api.RegistrationClientUri} {
+		if _, found := aspsp.Configs[configName]; !found {
+			continue
+		}
+		if err := s.admin.DeleteConfig(ctx, aspspId, configName, changedBy); err != nil {
+			return errors.WithMessage(err, "error in Delete()")
+		}
+	}
+
+	return nil
+}
+
+//management returns the endpoint of the registration and the token to access it. The registration_access_token
+//is used if the aspsp returned one, otherwise a client credentials token.
+func (s service) management(ctx context.Context, aspspId string, configs map[string]string) (string, string, error) {
+	clientId := configs[api.ClientId]
+	if clientId == "" {
+		return "", "", ErrNotRegistered
+	}
+
+	endpoint := configs[api.RegistrationClientUri]
+	if endpoint == "" {
+		if configs[api.EndpointRegister] == "" {
+			return "", "", &ConfigError{ConfigName: api.EndpointRegister}
+		}
+		endpoint = strings.TrimSuffix(configs[api.EndpointRegister], "/") + "/" + clientId
+	}
+
+	if bearer := configs[api.RegistrationAccessToken]; bearer != "" {
+		return endpoint, bearer, nil
+	}
+	bearer, err := s.tokenService.GetAccessToken(ctx, aspspId, managementScope)
+	if err != nil {
+		return "", "", errors.WithMessage(err, "error in management()")
+	}
+
+	return endpoint, bearer, nil
+}
+
+//registrationRequest returns the signed registration request, which carries the software statement of the TPP.
+func (s service) registrationRequest(aspspId string, configs map[string]string) (string, error) {
+	if s.settings.SoftwareStatement == "" {
+		return "", &ConfigError{ConfigName: "DCR_SOFTWARE_STATEMENT"}
+	}
+	data, err := ioutil.ReadFile(s.settings.SoftwareStatement)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in registrationRequest(). couldn't read the software statement")
+	}
+	ssa := strings.TrimSpace(string(data))
+
+	softwareId, err := softwareId(ssa)
+	if err != nil {
+		return "", err
+	}
+	for _, configName := range []string{api.Aud, api.RedirectUrl} {
+		if configs[configName] == "" {
+			return "", &ConfigError{ConfigName: configName}
+		}
+	}
+
+	signingMethod, err := clientauth.SigningMethod(s.cfg, aspspId)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in registrationRequest()")
+	}
+
+	authMethod := configs[api.TokenEndpointAuthMethod]
+	if authMethod == "" {
+		authMethod = api.TlsClientAuth
+	}
+	applicationType := configs[api.ApplicationType]
+	if applicationType == "" {
+		applicationType = "web"
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                          softwareId,
+		"aud":                          configs[api.Aud],
+		"jti":                          uuid.New().String(),
+		"iat":                          security.CreateTokenTime(0),
+		"exp":                          security.CreateTokenTime(5),
+		"software_id":                  softwareId,
+		"software_statement":           ssa,
+		"redirect_uris":                []string{configs[api.RedirectUrl]},
+		"token_endpoint_auth_method":   authMethod,
+		"grant_types":                  []string{"authorization_code", "refresh_token", "client_credentials"},
+		"response_types":               []string{api.CodeIdToken},
+		"application_type":             applicationType,
+		"id_token_signed_response_alg": signingMethod.Alg(),
+		"request_object_signing_alg":   signingMethod.Alg(),
+		"scope":                        api.ScopeAccounts,
+	}
+	switch authMethod {
+	case api.PrivateKeyJwt:
+		claims["token_endpoint_auth_signing_alg"] = signingMethod.Alg()
+	case api.TlsClientAuth:
+		if subjectDn := configs[api.TlsClientAuthSubjectDn]; subjectDn != "" {
+			claims["tls_client_auth_subject_dn"] = subjectDn
+		}
+	}
+
+	signed, err := security.GenerateJwtWithClaims(claims, signingMethod)
+	if err != nil {
+		return "", errors.WithMessage(err, "error in registrationRequest()")
+	}
+
+	return signed, nil
+}
+
+//softwareId reads the software_id claim of the SSA. The SSA is signed by the directory, so it isn't verified here.
+func softwareId(ssa string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(ssa, claims); err != nil {
+		return "", errors.WithMessage(err, "error in softwareId(). invalid software statement")
+	}
+
+	softwareId, _ := claims["software_id"].(string)
+	if softwareId == "" {
+		return "", errors.New("error in softwareId(). software statement doesn't have software_id")
+	}
+
+	return softwareId, nil
+}
+
+func (s service) send(ctx context.Context, aspspId, method, endpoint, bearer, request string) (*Registration, error) {
+	financialId, _ := s.cfg.FindByConfigName(aspspId, api.FapiFinancialId)
+	header := client.NewFapiHeader(ctx, financialId)
+	header.Set(api.Accept, api.ApplicationJson)
+	if request != "" {
+		header.Set(api.ContentType, api.ApplicationJwt)
+	}
+	if bearer != "" {
+		header.Set(api.Authorization, "Bearer "+bearer)
+	}
+
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.EndpointRegister, endpoint, header)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in send()")
+	}
+
+	var resp *client.HttpResponse
+	switch method {
+	case http.MethodPost:
+		resp, err = httpClient.Post(ctx, strings.NewReader(request))
+	case http.MethodPut:
+		resp, err = httpClient.Put(ctx, strings.NewReader(request))
+	case http.MethodDelete:
+		resp, err = httpClient.Delete(ctx)
+	default:
+		resp, err = httpClient.Get(ctx, nil)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error in send()")
+	}
+	client.RecordInteraction(ctx, resp)
+
+	switch {
+	case method == http.MethodDelete && resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		var registration Registration
+		if err := json.Unmarshal([]byte(resp.Body), &registration); err != nil {
+			return nil, errors.WithMessage(err, "error in send(). invalid registration response")
+		}
+		if registration.ClientId == "" {
+			return nil, errors.New("error in send(). registration response doesn't have client_id")
+		}
+		return &registration, nil
+	default:
+		return nil, api.NewAspspError(resp.StatusCode, resp.Body)
+	}
+}
+
+//rollback deletes the client which the aspsp has registered but couldn't be stored, so it isn't left at the aspsp
+//without its credentials. It needs the registration_access_token, as the client credentials token needs the stored client.
+func (s service) rollback(ctx context.Context, aspspId, endpointRegister string, registration *Registration) {
+	fields := []logger.Field{logger.String("aspspId", aspspId), logger.String("clientId", registration.ClientId)}
+	if registration.RegistrationAccessToken == "" {
+		logger.Error("the registered client couldn't be stored and has to be deleted at the aspsp", fields...)
+		return
+	}
+
+	endpoint := registration.RegistrationClientUri
+	if endpoint == "" {
+		endpoint = strings.TrimSuffix(endpointRegister, "/") + "/" + registration.ClientId
+	}
+	if _, err := s.send(ctx, aspspId, http.MethodDelete, endpoint, registration.RegistrationAccessToken, ""); err != nil {
+		logger.Error("the registered client couldn't be stored and has to be deleted at the aspsp", append(fields, logger.Err(err))...)
+		return
+	}
+	logger.Info("the registered client is deleted at the aspsp, as it couldn't be stored", fields...)
+}
+
+//store keeps the client in config_table. The values the aspsp doesn't return are left as they are.
+func (s service) store(ctx context.Context, aspspId string, registration *Registration, changedBy string) error {
+	configs := map[string]string{api.ClientId: registration.ClientId}
+	if registration.ClientSecret != "" {
+		configs[api.ClientSecret] = registration.ClientSecret
+	}
+	if registration.RegistrationAccessToken != "" {
+		configs[api.RegistrationAccessToken] = registration.RegistrationAccessToken
+	}
+	if registration.RegistrationClientUri != "" {
+		configs[api.RegistrationClientUri] = registration.RegistrationClientUri
+	}
+
+	if err := s.admin.SetConfigs(ctx, aspspId, configs, changedBy); err != nil {
+		return errors.WithMessage(err, "error in store(). the aspsp has registered the client "+registration.ClientId)
+	}
+
+	return nil
+}
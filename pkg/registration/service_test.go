@@ -0,0 +1,192 @@
+package registration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/settings"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+type configStub map[string]string
+
+func (c configStub) FindByConfigName(aspspId, configName string) (string, error) {
+	if value, found := c[configName]; found {
+		return value, nil
+	}
+
+	return "", sql.ErrNoRows
+}
+
+type adminStub struct {
+	configmanager.AdminService
+	configs map[string]string
+	setErr  error
+}
+
+func (a *adminStub) FindAspsp(ctx context.Context, aspspId string) (*configmanager.Aspsp, error) {
+	return &configmanager.Aspsp{AspspId: aspspId, Configs: a.configs}, nil
+}
+
+func (a *adminStub) SetConfigs(ctx context.Context, aspspId string, configs map[string]string, changedBy string) error {
+	if a.setErr != nil {
+		return a.setErr
+	}
+	for configName, configValue := range configs {
+		a.configs[configName] = configValue
+	}
+	return nil
+}
+
+func (a *adminStub) DeleteConfig(ctx context.Context, aspspId, configName, changedBy string) error {
+	delete(a.configs, configName)
+	return nil
+}
+
+//newAspsp serves the registration endpoint over TLS. The registration request is checked for the software statement.
+//The deleted clients are counted in deleted.
+func newAspsp(t *testing.T, deleted *int32) (*httptest.Server, configStub) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/register":
+			body, _ := ioutil.ReadAll(r.Body)
+			claims := jwt.MapClaims{}
+			if _, _, err := new(jwt.Parser).ParseUnverified(string(body), claims); err != nil ||
+				r.Header.Get("Content-Type") != "application/jwt" || claims["software_id"] != "software-1" || claims["software_statement"] == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"client_id":"client-1","client_secret":"secret-1","registration_access_token":"rat-1"}`))
+		case r.URL.Path == "/register/client-1" && r.Header.Get("Authorization") == "Bearer rat-1":
+			if r.Method == http.MethodDelete {
+				atomic.AddInt32(deleted, 1)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write([]byte(`{"client_id":"client-1","redirect_uris":["https://tpp/callback"]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	caCert := filepath.Join(t.TempDir(), "server.cer")
+	if err := ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("couldn't write the server cert. err: %v", err)
+	}
+
+	return server, configStub{
+		"TLS_CA_CERT_PEM":     caCert,
+		"TLS_CLIENT_CERT_PEM": "../../internal/client/testdata/test_cert.pem",
+		"TLS_CLIENT_KEY_PEM":  "../../internal/client/testdata/test_key.pem",
+	}
+}
+
+func writeSoftwareStatement(t *testing.T) string {
+	encode := func(v map[string]interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	ssa := encode(map[string]interface{}{"alg": "PS256"}) + "." + encode(map[string]interface{}{"software_id": "software-1"}) + ".signature"
+
+	file := filepath.Join(t.TempDir(), "ssa.jwt")
+	if err := ioutil.WriteFile(file, []byte(ssa), 0600); err != nil {
+		t.Fatalf("couldn't write the software statement. err: %v", err)
+	}
+
+	return file
+}
+
+func Test_service_lifecycle(t *testing.T) {
+	security.SetSigner(security.NewFileSigner("../../internal/security/testdata/test_key.pem", "test_kid"))
+	defer security.SetSigner(nil)
+
+	var deleted int32
+	server, cfg := newAspsp(t, &deleted)
+	admin := &adminStub{configs: map[string]string{
+		"ENDPOINT_REGISTER": server.URL + "/register",
+		"AUD":               server.URL,
+		"REDIRECT_URL":      "https://tpp/callback",
+	}}
+	s := NewService(cfg, admin, nil, settings.Registration{SoftwareStatement: writeSoftwareStatement(t)})
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "test"); err != ErrNotRegistered {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotRegistered)
+	}
+
+	registration, err := s.Register(ctx, "test", "operator")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if registration.Public().ClientSecret != "" || admin.configs["CLIENT_ID"] != "client-1" ||
+		admin.configs["CLIENT_SECRET"] != "secret-1" || admin.configs["REGISTRATION_ACCESS_TOKEN"] != "rat-1" {
+		t.Errorf("Register() configs = %v", admin.configs)
+	}
+
+	registration, err = s.Get(ctx, "test")
+	if err != nil || registration.RedirectUris[0] != "https://tpp/callback" {
+		t.Fatalf("Get() = %v, error = %v", registration, err)
+	}
+
+	if err := s.Delete(ctx, "test", "operator"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, found := admin.configs["CLIENT_SECRET"]; found || admin.configs["CLIENT_ID"] != "client-1" {
+		t.Errorf("Delete() configs = %v", admin.configs)
+	}
+}
+
+func Test_service_Register_rollback(t *testing.T) {
+	security.SetSigner(security.NewFileSigner("../../internal/security/testdata/test_key.pem", "test_kid"))
+	defer security.SetSigner(nil)
+
+	var deleted int32
+	server, cfg := newAspsp(t, &deleted)
+	admin := &adminStub{configs: map[string]string{
+		"ENDPOINT_REGISTER": server.URL + "/register",
+		"AUD":               server.URL,
+		"REDIRECT_URL":      "https://tpp/callback",
+	}, setErr: errors.New("database is down")}
+	s := NewService(cfg, admin, nil, settings.Registration{SoftwareStatement: writeSoftwareStatement(t)})
+
+	if _, err := s.Register(context.Background(), "test", "operator"); err == nil {
+		t.Fatal("Register() error = nil, want the store error")
+	}
+	if got := atomic.LoadInt32(&deleted); got != 1 {
+		t.Errorf("Register() deleted %v clients at the aspsp, want 1", got)
+	}
+}
+
+func Test_service_Register_missingConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		configs  map[string]string
+		settings settings.Registration
+		want     string
+	}{
+		{"missing_endpoint", map[string]string{}, settings.Registration{SoftwareStatement: "ssa.jwt"}, "ENDPOINT_REGISTER"},
+		{"missing_software_statement", map[string]string{"ENDPOINT_REGISTER": "https://aspsp/register"}, settings.Registration{}, "DCR_SOFTWARE_STATEMENT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(configStub{}, &adminStub{configs: tt.configs}, nil, tt.settings)
+			_, err := s.Register(context.Background(), "test", "operator")
+			if cerr, ok := err.(*ConfigError); !ok || cerr.ConfigName != tt.want {
+				t.Errorf("Register() error = %v, want ConfigError of %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package registration
+
+import (
+	"fmt"
+	"github.com/kaanaktas/openbanking-accountinformation/api"
+)
+
+//Registration is the client registration returned by the aspsp.
+type Registration struct {
+	ClientId                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIdIssuedAt        int64    `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken string   `json:"registration_access_token,omitempty"`
+	RegistrationClientUri   string   `json:"registration_client_uri,omitempty"`
+	RedirectUris            []string `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	SoftwareId              string   `json:"software_id,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	ApplicationType         string   `json:"application_type,omitempty"`
+}
+
+//Public returns the registration without the client secret and the registration access token.
+func (r Registration) Public() Registration {
+	r.ClientSecret = ""
+	r.RegistrationAccessToken = ""
+
+	return r
+}
+
+//ConfigError is returned when a config the registration needs isn't set for the aspsp.
+type ConfigError struct {
+	ConfigName string
+}
+
+func (c *ConfigError) Error() string {
+	return fmt.Sprintf("%v isn't configured", c.ConfigName)
+}
+
+func (c *ConfigError) ApiError() *api.Error {
+	return api.NewFieldError(api.FieldMissing, c.ConfigName, c.Error())
+}
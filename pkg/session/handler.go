@@ -12,25 +12,24 @@ func RegisterHandler(e *echo.Echo, service Service) {
 
 func initiateSession(service Service) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		rid := c.Response().Header().Get(echo.HeaderXRequestID)
 		userId := c.Param("userId")
 		if userId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "userId can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "userId", "userId can't be empty")
 		}
 
 		tppId := c.Param("tppId")
 		if tppId == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "tppId can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "tppId", "tppId can't be empty")
 		}
 
 		tid := c.Param("tid")
 		if tid == "" {
-			return c.JSON(http.StatusBadRequest, api.JsonResponse(rid, "tid can't be empty"))
+			return api.NewFieldError(api.FieldMissing, "tid", "tid can't be empty")
 		}
 
-		response, err := service.InitiateSession(userId, tppId, tid)
+		response, err := service.InitiateSession(c.Request().Context(), userId, tppId, tid)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, api.JsonResponse(rid, err.Error()))
+			return err
 		}
 
 		return c.JSON(http.StatusOK, response)
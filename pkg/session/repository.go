@@ -1,14 +1,16 @@
 package session
 
 import (
+	"context"
 	"github.com/jmoiron/sqlx"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"github.com/pkg/errors"
 )
 
 type Repository interface {
-	findSessionByTidAndTppId(tid string, tppId string) (*Session, error)
-	findByInternalAccessToken(accessToken string) (*Session, error)
-	saveSession(session *Session) error
+	findSessionByTidAndTppId(ctx context.Context, tid string, tppId string) (*Session, error)
+	findByInternalAccessToken(ctx context.Context, accessToken string) (*Session, error)
+	saveSession(ctx context.Context, session *Session) error
 }
 
 type repository struct {
@@ -19,9 +21,12 @@ func NewRepository(db *sqlx.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r repository) findSessionByTidAndTppId(tid string, tppId string) (*Session, error) {
+func (r repository) findSessionByTidAndTppId(ctx context.Context, tid string, tppId string) (*Session, error) {
+	ctx, span := store.StartSpan(ctx, "findSessionByTidAndTppId")
+	defer span.End()
+
 	var session Session
-	err := r.db.Get(&session, `SELECT reference_id, internal_access_token from session_table where tid=$1 AND tpp_id=$2`, tid, tppId)
+	err := r.db.GetContext(ctx, &session, `SELECT reference_id, internal_access_token from session_table where tid=$1 AND tpp_id=$2`, tid, tppId)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in findSessionByTidAndTppId()")
 	}
@@ -29,9 +34,12 @@ func (r repository) findSessionByTidAndTppId(tid string, tppId string) (*Session
 	return &session, nil
 }
 
-func (r repository) findByInternalAccessToken(accessToken string) (*Session, error) {
+func (r repository) findByInternalAccessToken(ctx context.Context, accessToken string) (*Session, error) {
+	ctx, span := store.StartSpan(ctx, "findByInternalAccessToken")
+	defer span.End()
+
 	var session Session
-	err := r.db.Get(&session, `SELECT * from session_table where internal_access_token=$1`, accessToken)
+	err := r.db.GetContext(ctx, &session, `SELECT * from session_table where internal_access_token=$1`, accessToken)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in findByInternalAccessToken()")
 	}
@@ -39,8 +47,11 @@ func (r repository) findByInternalAccessToken(accessToken string) (*Session, err
 	return &session, nil
 }
 
-func (r repository) saveSession(session *Session) error {
-	_, err := r.db.NamedExec(`INSERT INTO session_table(tid, tpp_id, user_id, aspsp_id, internal_access_token, reference_id, create_date_time, update_date_time) 
+func (r repository) saveSession(ctx context.Context, session *Session) error {
+	ctx, span := store.StartSpan(ctx, "saveSession")
+	defer span.End()
+
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO session_table(tid, tpp_id, user_id, aspsp_id, internal_access_token, reference_id, create_date_time, update_date_time) 
 												VALUES (:tid, :tpp_id, :user_id, :aspsp_id, :internal_access_token, :reference_id, :create_date_time, :update_date_time)`, session)
 	if err != nil {
 		return errors.WithMessage(err, "error in saveSession()")
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"testing"
 )
@@ -27,7 +28,7 @@ func Test_repository_FindByInternalAccessToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.repo.findByInternalAccessToken(tt.args.accessToken)
+			got, err := tt.repo.findByInternalAccessToken(context.Background(), tt.args.accessToken)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findByInternalAccessToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"crypto/sha1"
 	"database/sql"
 	"encoding/hex"
@@ -8,13 +9,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/security"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
 	"github.com/pkg/errors"
+	"net/http"
 	"time"
 )
 
+//ErrSessionNotFound is returned for the internal access tokens which don't have a session.
+var ErrSessionNotFound = api.NewError(http.StatusNotFound, api.ResourceNotFound, "couldn't find the session")
+
 type Service interface {
-	InitiateSession(userId, tppId, tid string) (map[string]interface{}, error)
-	FindByInternalAccessToken(accessToken string) (*Session, error)
+	InitiateSession(ctx context.Context, userId, tppId, tid string) (map[string]interface{}, error)
+	FindByInternalAccessToken(ctx context.Context, accessToken string) (*Session, error)
 }
 
 type service struct {
@@ -25,8 +31,11 @@ func NewService(r Repository) Service {
 	return &service{repo: r}
 }
 
-func (s service) InitiateSession(userId, tppId, tid string) (map[string]interface{}, error) {
-	referenceId, internalAccessToken, err := s.retrieveSession(userId, tppId, tid)
+func (s service) InitiateSession(ctx context.Context, userId, tppId, tid string) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "session.InitiateSession", tracing.KindInternal)
+	defer span.End()
+
+	referenceId, internalAccessToken, err := s.retrieveSession(ctx, userId, tppId, tid)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error in InitiateSession()")
 	}
@@ -39,10 +48,10 @@ func (s service) InitiateSession(userId, tppId, tid string) (map[string]interfac
 	return response, nil
 }
 
-func (s service) retrieveSession(userId, tppId, tid string) (string, string, error) {
-	session, err := s.repo.findSessionByTidAndTppId(tid, tppId)
+func (s service) retrieveSession(ctx context.Context, userId, tppId, tid string) (string, string, error) {
+	session, err := s.repo.findSessionByTidAndTppId(ctx, tid, tppId)
 	if errors.Is(err, sql.ErrNoRows) {
-		return s.createNewSession(userId, tppId, tid)
+		return s.createNewSession(ctx, userId, tppId, tid)
 	} else {
 		switch err {
 		case nil:
@@ -53,7 +62,7 @@ func (s service) retrieveSession(userId, tppId, tid string) (string, string, err
 	}
 }
 
-func (s service) createNewSession(userId, tppId, tid string) (string, string, error) {
+func (s service) createNewSession(ctx context.Context, userId, tppId, tid string) (string, string, error) {
 	claims := map[string]interface{}{
 		"tppId": tppId,
 		"tid":   tid,
@@ -81,7 +90,7 @@ func (s service) createNewSession(userId, tppId, tid string) (string, string, er
 		UpdateDateTime:      api.ObTime(time.Now()),
 	}
 
-	err = s.repo.saveSession(session)
+	err = s.repo.saveSession(ctx, session)
 	if err != nil {
 		return "", "", errors.WithMessage(err, "error in createNewSession()")
 	}
@@ -89,6 +98,14 @@ func (s service) createNewSession(userId, tppId, tid string) (string, string, er
 	return referenceId, internalAccessToken, nil
 }
 
-func (s service) FindByInternalAccessToken(accessToken string) (*Session, error) {
-	return s.repo.findByInternalAccessToken(accessToken)
+func (s service) FindByInternalAccessToken(ctx context.Context, accessToken string) (*Session, error) {
+	ctx, span := tracing.Start(ctx, "session.FindByInternalAccessToken", tracing.KindInternal)
+	defer span.End()
+
+	session, err := s.repo.findByInternalAccessToken(ctx, accessToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound.Wrap(err)
+	}
+
+	return session, err
 }
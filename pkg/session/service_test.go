@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
 	"reflect"
@@ -54,7 +55,7 @@ func Test_service_InitiateSession(t *testing.T) {
 			s := service{
 				repo: tt.fields.repo,
 			}
-			got, err := s.InitiateSession(tt.args.userId, tt.args.tppId, tt.args.tid)
+			got, err := s.InitiateSession(context.Background(), tt.args.userId, tt.args.tppId, tt.args.tid)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InitiateSession() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,10 +1,12 @@
 package token
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/client"
+	"github.com/kaanaktas/openbanking-accountinformation/internal/tracing"
+	"github.com/kaanaktas/openbanking-accountinformation/pkg/clientauth"
 	cfg "github.com/kaanaktas/openbanking-accountinformation/pkg/configmanager"
 	"github.com/pkg/errors"
 	"net/http"
@@ -13,9 +15,9 @@ import (
 )
 
 type Service interface {
-	GetAccessToken(aspspId, scopeType string) (string, error)
-	RefreshAccessToken(aspspId, scopeType, refreshTokenData string) (*AccessToken, error)
-	GetResourceAccessRefreshToken(aspspId, code string) (*AccessToken, error)
+	GetAccessToken(ctx context.Context, aspspId, scopeType string) (string, error)
+	RefreshAccessToken(ctx context.Context, aspspId, scopeType, refreshTokenData string) (*AccessToken, error)
+	GetResourceAccessRefreshToken(ctx context.Context, aspspId, code string) (*AccessToken, error)
 }
 
 type service struct {
@@ -28,14 +30,16 @@ func NewService(cfg cfg.Service) Service {
 
 const (
 	grantType    = "grant_type"
-	clientId     = "client_id"
 	scope        = "scope"
 	refreshToken = "refresh_token"
 	code         = "code"
 	redirectUri  = "redirect_uri"
 )
 
-func (s service) GetAccessToken(aspspId, scopeType string) (string, error) {
+func (s service) GetAccessToken(ctx context.Context, aspspId, scopeType string) (string, error) {
+	ctx, span := tracing.Start(ctx, "token.GetAccessToken", tracing.KindInternal)
+	defer span.End()
+
 	var errMessage = "error in GetAccessToken()"
 	endpointOauth2, err := s.cfg.FindByConfigName(aspspId, api.EndpointOauth2)
 	if err != nil {
@@ -54,18 +58,21 @@ func (s service) GetAccessToken(aspspId, scopeType string) (string, error) {
 
 	parameters := url.Values{}
 	parameters.Set(grantType, "client_credentials")
-	parameters.Set(clientId, clientIdValue)
 	parameters.Set(scope, scopeType)
+	if err = clientauth.SetParameters(s.cfg, aspspId, clientIdValue, endpointOauth2, parameters); err != nil {
+		return "", errors.WithMessage(err, errMessage)
+	}
 
-	httpClient, err := client.NewSecureHttpClient(endpointOauth2, s.setHeader(financialId))
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.EndpointOauth2, endpointOauth2, s.setHeader(ctx, financialId))
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Post(strings.NewReader(parameters.Encode()))
+	resp, err := httpClient.Post(ctx, strings.NewReader(parameters.Encode()))
 	if err != nil {
 		return "", errors.WithMessage(err, errMessage)
 	}
+	client.RecordInteraction(ctx, resp)
 
 	if (resp.StatusCode == 200 || resp.StatusCode == 201) && resp.Body != "" {
 		var accessToken *AccessToken
@@ -77,11 +84,14 @@ func (s service) GetAccessToken(aspspId, scopeType string) (string, error) {
 
 		return accessToken.AccessToken, nil
 	} else {
-		return "", fmt.Errorf("unexpected result from the token service. resp: %v", *resp)
+		return "", errors.WithMessage(api.ErrAspspRejected.Wrap(&api.AspspError{StatusCode: resp.StatusCode}), errMessage)
 	}
 }
 
-func (s service) RefreshAccessToken(aspspId, scopeType, refreshTokenData string) (*AccessToken, error) {
+func (s service) RefreshAccessToken(ctx context.Context, aspspId, scopeType, refreshTokenData string) (*AccessToken, error) {
+	ctx, span := tracing.Start(ctx, "token.RefreshAccessToken", tracing.KindInternal)
+	defer span.End()
+
 	var errMessage = "error in RefreshAccessToken()"
 	endpointOauth2, err := s.cfg.FindByConfigName(aspspId, api.EndpointOauth2)
 	if err != nil {
@@ -101,18 +111,21 @@ func (s service) RefreshAccessToken(aspspId, scopeType, refreshTokenData string)
 	parameters := url.Values{}
 	parameters.Set(grantType, "refresh_token")
 	parameters.Set(refreshToken, refreshTokenData)
-	parameters.Set(clientId, clientIdValue)
 	parameters.Set(scope, scopeType)
+	if err = clientauth.SetParameters(s.cfg, aspspId, clientIdValue, endpointOauth2, parameters); err != nil {
+		return nil, errors.WithMessage(err, errMessage)
+	}
 
-	httpClient, err := client.NewSecureHttpClient(endpointOauth2, s.setHeader(financialId))
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.EndpointOauth2, endpointOauth2, s.setHeader(ctx, financialId))
 	if err != nil {
 		return nil, errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Post(strings.NewReader(parameters.Encode()))
+	resp, err := httpClient.Post(ctx, strings.NewReader(parameters.Encode()))
 	if err != nil {
 		return nil, errors.WithMessage(err, errMessage)
 	}
+	client.RecordInteraction(ctx, resp)
 
 	var accessToken *AccessToken
 	if resp.StatusCode == 200 {
@@ -123,11 +136,14 @@ func (s service) RefreshAccessToken(aspspId, scopeType, refreshTokenData string)
 
 		return accessToken, nil
 	} else {
-		return nil, fmt.Errorf("unexpected result from the token service. resp: %v", *resp)
+		return nil, errors.WithMessage(api.ErrAspspRejected.Wrap(&api.AspspError{StatusCode: resp.StatusCode}), errMessage)
 	}
 }
 
-func (s service) GetResourceAccessRefreshToken(aspspId, authCode string) (*AccessToken, error) {
+func (s service) GetResourceAccessRefreshToken(ctx context.Context, aspspId, authCode string) (*AccessToken, error) {
+	ctx, span := tracing.Start(ctx, "token.GetResourceAccessRefreshToken", tracing.KindInternal)
+	defer span.End()
+
 	var errMessage = "error in GetResourceAccessRefreshToken()"
 	endpointOauth2, err := s.cfg.FindByConfigName(aspspId, api.EndpointOauth2)
 	if err != nil {
@@ -151,19 +167,22 @@ func (s service) GetResourceAccessRefreshToken(aspspId, authCode string) (*Acces
 
 	parameters := url.Values{}
 	parameters.Set(grantType, "authorization_code")
-	parameters.Set(clientId, clientIdParam)
 	parameters.Set(redirectUri, appRedirectUrl)
 	parameters.Set(code, authCode)
+	if err = clientauth.SetParameters(s.cfg, aspspId, clientIdParam, endpointOauth2, parameters); err != nil {
+		return nil, errors.WithMessage(err, errMessage)
+	}
 
-	httpClient, err := client.NewSecureHttpClient(endpointOauth2, s.setHeader(financialId))
+	httpClient, err := client.NewSecureHttpClient(s.cfg, aspspId, api.EndpointOauth2, endpointOauth2, s.setHeader(ctx, financialId))
 	if err != nil {
 		return nil, errors.WithMessage(err, errMessage)
 	}
 
-	resp, err := httpClient.Post(strings.NewReader(parameters.Encode()))
+	resp, err := httpClient.Post(ctx, strings.NewReader(parameters.Encode()))
 	if err != nil {
 		return nil, errors.WithMessage(err, errMessage)
 	}
+	client.RecordInteraction(ctx, resp)
 
 	if (resp.StatusCode == 200 || resp.StatusCode == 201) && resp.Body != "" {
 		var accessToken *AccessToken
@@ -174,16 +193,13 @@ func (s service) GetResourceAccessRefreshToken(aspspId, authCode string) (*Acces
 
 		return accessToken, nil
 	} else {
-		return nil, fmt.Errorf("unexpected result from the token service. resp: %v", *resp)
+		return nil, errors.WithMessage(api.ErrAspspRejected.Wrap(&api.AspspError{StatusCode: resp.StatusCode}), errMessage)
 	}
 }
 
-func (s service) setHeader(financialId string) http.Header {
-	header := http.Header{}
-	header.Set(api.Accept, api.ApplicationJson)
+func (s service) setHeader(ctx context.Context, financialId string) http.Header {
+	header := client.NewFapiHeader(ctx, financialId)
 	header.Set(api.ContentType, api.ApplicationFormUrlencodedValue)
-	header.Set(api.CacheControl, "no-cache")
-	header.Set(api.XFapiFinancialId, financialId)
 
 	return header
 }
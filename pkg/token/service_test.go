@@ -1,6 +1,7 @@
 package token
 
 import (
+	"context"
 	"github.com/kaanaktas/openbanking-accountinformation/api"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/cache"
 	"github.com/kaanaktas/openbanking-accountinformation/internal/store"
@@ -23,12 +24,12 @@ func Test_service_GetAccessToken(t *testing.T) {
 		wantErr bool
 	}{
 		{"get_access_token_success",
-			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())},
+			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)},
 			args{aspspId: "ozone", scopeType: api.ScopeAccounts},
 			false,
 		},
 		{"get_access_token_wrong_aspsp_id",
-			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())},
+			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)},
 			args{aspspId: "hsbc", scopeType: api.ScopeAccounts},
 			true,
 		},
@@ -38,7 +39,7 @@ func Test_service_GetAccessToken(t *testing.T) {
 			s := service{
 				cfg: tt.fields.cfg,
 			}
-			got, err := s.GetAccessToken(tt.args.aspspId, tt.args.scopeType)
+			got, err := s.GetAccessToken(context.Background(), tt.args.aspspId, tt.args.scopeType)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetAccessToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -66,7 +67,7 @@ func Test_service_RefreshAccessToken(t *testing.T) {
 		wantErr bool
 	}{
 		{"refresh_access_token_expect_invalid_grant",
-			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())},
+			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)},
 			args{aspspId: "danske", scopeType: api.ScopeAccounts, refreshTokenData: "dummy_token"},
 			true,
 		},
@@ -76,7 +77,7 @@ func Test_service_RefreshAccessToken(t *testing.T) {
 			s := service{
 				cfg: tt.fields.cfg,
 			}
-			got, err := s.RefreshAccessToken(tt.args.aspspId, tt.args.scopeType, tt.args.refreshTokenData)
+			got, err := s.RefreshAccessToken(context.Background(), tt.args.aspspId, tt.args.scopeType, tt.args.refreshTokenData)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RefreshAccessToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -105,7 +106,7 @@ func Test_service_GetResourceAccessRefreshToken(t *testing.T) {
 	}{
 		{
 			"get_resource_access_refresh_token_success",
-			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection()), cache.LoadInMemory())},
+			fields{cfg: cfg.NewService(cfg.NewRepository(store.LoadDBConnection(), nil), cache.LoadInMemory(), cache.NoExpiration)},
 			args{
 				aspspId:  "danske",
 				authCode: "dummy_auth_code",
@@ -118,7 +119,7 @@ func Test_service_GetResourceAccessRefreshToken(t *testing.T) {
 			s := service{
 				cfg: tt.fields.cfg,
 			}
-			got, err := s.GetResourceAccessRefreshToken(tt.args.aspspId, tt.args.authCode)
+			got, err := s.GetResourceAccessRefreshToken(context.Background(), tt.args.aspspId, tt.args.authCode)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetResourceAccessRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,29 @@
+-- Table: public.audit_table
+
+CREATE TABLE IF NOT EXISTS public.audit_table
+(
+    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1 ),
+    aspsp_id character varying(50) COLLATE pg_catalog."default",
+    cid character varying(255) COLLATE pg_catalog."default",
+    interaction_id character varying(255) COLLATE pg_catalog."default",
+    method character varying(10) COLLATE pg_catalog."default",
+    url character varying(2000) COLLATE pg_catalog."default",
+    request_header text COLLATE pg_catalog."default",
+    request_body text COLLATE pg_catalog."default",
+    status_code integer,
+    response_body text COLLATE pg_catalog."default",
+    latency_ms bigint,
+    error text COLLATE pg_catalog."default",
+    create_date_time timestamp without time zone,
+    CONSTRAINT audit_table_pkey PRIMARY KEY (id)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.audit_table OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS audit_table_create_date_time_idx ON public.audit_table (create_date_time);
+CREATE INDEX IF NOT EXISTS audit_table_aspsp_id_cid_idx ON public.audit_table (aspsp_id, cid);
+CREATE INDEX IF NOT EXISTS audit_table_interaction_id_idx ON public.audit_table (interaction_id);
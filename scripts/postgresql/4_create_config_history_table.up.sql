@@ -0,0 +1,22 @@
+-- Table: public.config_history_table
+
+CREATE TABLE IF NOT EXISTS public.config_history_table
+(
+    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1 ),
+    aspsp_id character varying(50) COLLATE pg_catalog."default",
+    config_name character varying(255) COLLATE pg_catalog."default",
+    action character varying(10) COLLATE pg_catalog."default",
+    old_value character varying(255) COLLATE pg_catalog."default",
+    new_value character varying(255) COLLATE pg_catalog."default",
+    changed_by character varying(255) COLLATE pg_catalog."default",
+    create_date_time timestamp without time zone,
+    CONSTRAINT config_history_table_pkey PRIMARY KEY (id)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.config_history_table OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS config_history_table_aspsp_id_idx ON public.config_history_table (aspsp_id, create_date_time);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transaction_table;
+DROP TABLE IF EXISTS balance_table;
+DROP TABLE IF EXISTS account_table;
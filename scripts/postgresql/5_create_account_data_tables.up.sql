@@ -0,0 +1,81 @@
+-- Table: public.account_table
+
+CREATE TABLE IF NOT EXISTS public.account_table
+(
+    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1 ),
+    aspsp_id character varying(50) COLLATE pg_catalog."default",
+    cid character varying(255) COLLATE pg_catalog."default",
+    account_id character varying(40) COLLATE pg_catalog."default",
+    currency character varying(3) COLLATE pg_catalog."default",
+    account_type character varying(40) COLLATE pg_catalog."default",
+    account_sub_type character varying(40) COLLATE pg_catalog."default",
+    nickname character varying(70) COLLATE pg_catalog."default",
+    identifiers text COLLATE pg_catalog."default",
+    servicer text COLLATE pg_catalog."default",
+    sync_date_time timestamp without time zone,
+    CONSTRAINT account_table_pkey PRIMARY KEY (id),
+    CONSTRAINT account_table_cid_account_id_key UNIQUE (cid, account_id)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.account_table OWNER to postgres;
+
+-- Table: public.balance_table
+
+CREATE TABLE IF NOT EXISTS public.balance_table
+(
+    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1 ),
+    aspsp_id character varying(50) COLLATE pg_catalog."default",
+    cid character varying(255) COLLATE pg_catalog."default",
+    account_id character varying(40) COLLATE pg_catalog."default",
+    balance_type character varying(40) COLLATE pg_catalog."default",
+    amount character varying(20) COLLATE pg_catalog."default",
+    currency character varying(3) COLLATE pg_catalog."default",
+    date_time timestamp without time zone,
+    credit_line_included boolean,
+    CONSTRAINT balance_table_pkey PRIMARY KEY (id)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.balance_table OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS balance_table_cid_account_id_idx ON public.balance_table (cid, account_id);
+
+-- Table: public.transaction_table
+
+CREATE TABLE IF NOT EXISTS public.transaction_table
+(
+    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1 ),
+    aspsp_id character varying(50) COLLATE pg_catalog."default",
+    cid character varying(255) COLLATE pg_catalog."default",
+    account_id character varying(40) COLLATE pg_catalog."default",
+    transaction_key character varying(210) COLLATE pg_catalog."default",
+    transaction_id character varying(210) COLLATE pg_catalog."default",
+    reference character varying(210) COLLATE pg_catalog."default",
+    status character varying(10) COLLATE pg_catalog."default",
+    booking_date_time timestamp without time zone,
+    value_date_time timestamp without time zone,
+    amount character varying(20) COLLATE pg_catalog."default",
+    currency character varying(3) COLLATE pg_catalog."default",
+    description character varying(500) COLLATE pg_catalog."default",
+    merchant_name character varying(350) COLLATE pg_catalog."default",
+    balance_amount character varying(20) COLLATE pg_catalog."default",
+    balance_currency character varying(3) COLLATE pg_catalog."default",
+    update_date_time timestamp without time zone,
+    CONSTRAINT transaction_table_pkey PRIMARY KEY (id),
+    CONSTRAINT transaction_table_cid_account_id_transaction_key_key UNIQUE (cid, account_id, transaction_key)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.transaction_table OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS transaction_table_booking_date_time_idx ON public.transaction_table (cid, account_id, booking_date_time);
@@ -0,0 +1,16 @@
+-- Table: public.access_count_table
+
+CREATE TABLE IF NOT EXISTS public.access_count_table
+(
+    cid character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    resource character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    access_date character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    access_count integer NOT NULL,
+    CONSTRAINT access_count_table_pkey PRIMARY KEY (cid, resource, access_date)
+)
+    WITH (
+        OIDS = FALSE
+    )
+    TABLESPACE pg_default;
+
+ALTER TABLE public.access_count_table OWNER to postgres;
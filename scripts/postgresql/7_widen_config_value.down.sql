@@ -0,0 +1,3 @@
+ALTER TABLE public.config_history_table ALTER COLUMN new_value TYPE character varying(255) USING left(new_value, 255);
+ALTER TABLE public.config_history_table ALTER COLUMN old_value TYPE character varying(255) USING left(old_value, 255);
+ALTER TABLE public.config_table ALTER COLUMN config_value TYPE character varying(255);
@@ -0,0 +1,4 @@
+-- The registration access tokens and the encrypted secrets are longer than 255 characters.
+ALTER TABLE public.config_table ALTER COLUMN config_value TYPE character varying(4096);
+ALTER TABLE public.config_history_table ALTER COLUMN old_value TYPE character varying(4096);
+ALTER TABLE public.config_history_table ALTER COLUMN new_value TYPE character varying(4096);